@@ -0,0 +1,140 @@
+// Package cli implements the headless subcommands of gen-magic, which work
+// with projects without a display.
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/qbradq/gen-magic/project"
+	"github.com/qbradq/gen-magic/secrets"
+)
+
+// Command is a headless subcommand.
+type Command struct {
+	Name string
+	Usage string
+	Description string
+	// Run runs the command with its arguments and returns the exit code.
+	Run func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = []*Command{}
+
+// register registers a command.
+func register(c *Command) {
+	commands = append(commands, c)
+}
+
+// IsCommand returns true if name is the name of a subcommand.
+func IsCommand(name string) bool {
+	return name == "help" || slices.ContainsFunc(commands, func(c *Command) bool {
+		return c.Name == name
+	})
+}
+
+// Run runs the subcommand named by the first argument and returns the exit
+// code.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+	if args[0] == "help" {
+		printUsage(stdout)
+		return 0
+	}
+	for _, c := range commands {
+		if c.Name == args[0] {
+			return c.Run(args[1:], stdin, stdout, stderr)
+		}
+	}
+	fmt.Fprintf(stderr, "unknown command \"%s\"\n", args[0])
+	printUsage(stderr)
+	return 2
+}
+
+// printUsage prints the list of commands.
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gen-magic [command] [flags]")
+	fmt.Fprintln(w, "Without a command the gen-magic window is opened.")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.Name, c.Description)
+	}
+	fmt.Fprintln(w, "\nRun \"gen-magic [command] -h\" for the flags of a command.")
+}
+
+// newFlagSet returns a new flag set for the named command with the -project
+// flag.
+func newFlagSet(name string, stderr io.Writer) (*flag.FlagSet, *string) {
+	c := commands[slices.IndexFunc(commands, func(c *Command) bool {
+		return c.Name == name
+	})]
+	fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: gen-magic %s %s\n%s\n\nFlags:\n", c.Name, c.Usage, c.Description)
+		fs.PrintDefaults()
+	}
+	path := fs.String("project", DefaultProject(), "project file")
+	return fs, path
+}
+
+// DefaultProject returns the path of the project used when none is given,
+// which is $GEN_MAGIC_PROJECT or default.gen-magic in the home directory.
+func DefaultProject() string {
+	if p := os.Getenv("GEN_MAGIC_PROJECT"); p != "" {
+		return p
+	}
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "default.gen-magic"
+	}
+	return filepath.Join(dir, "default.gen-magic")
+}
+
+// interruptContext returns a context that is canceled on interrupt. Tests
+// replace it to stop long-running commands without signals.
+var interruptContext = func() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// openProject opens the project file, which must exist, and checks the
+// passphrase of encrypted API keys.
+func openProject(path string) (*project.Project, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	p := &project.Project{}
+	if err := p.Load("sqlite", path); err != nil {
+		return nil, err
+	}
+	if p.HasEncryptedKeys() {
+		if err := p.CheckPassphrase(); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("%w (set %s)", err, secrets.PassphraseEnv)
+		}
+	}
+	return p, nil
+}
+
+// readPrompt returns the arguments joined with spaces, or all of stdin if
+// there are no arguments or the only argument is "-".
+func readPrompt(args []string, stdin io.Reader) (string, error) {
+	if len(args) > 0 && !(len(args) == 1 && args[0] == "-") {
+		return strings.Join(args, " "), nil
+	}
+	b, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/qbradq/gen-magic/project"
+)
+
+// newTestProject creates a project in a temporary directory with the mock LLMs
+// "echo" and "broken", which always fails, and the agent "helper" using echo,
+// and returns the path of its database.
+func newTestProject(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.gen-magic")
+	p := &project.Project{}
+	if err := p.Load("sqlite", path); err != nil {
+		t.Fatalf("loading project: %v", err)
+	}
+	defer p.Close()
+	for _, def := range []struct {
+		name string
+		endpoint string
+	}{
+		{"echo", "mock://?chunk=3"},
+		{"broken", "mock://?error=boom"},
+	} {
+		l := p.NewLLM()
+		l.Name = def.name
+		l.API = "mock"
+		l.APIEndpoint = def.endpoint
+		l.Model = "echo"
+		if err := p.SetLLM(l); err != nil {
+			t.Fatalf("saving LLM: %v", err)
+		}
+	}
+	agent := p.NewAgent()
+	agent.Name = "helper"
+	agent.LLM, _ = p.FindLLM("echo")
+	agent.System.Content = "You help."
+	p.SetAgent(agent)
+	return path
+}
+
+// run runs the command line with stdin and returns the exit code, stdout and
+// stderr.
+func run(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	code, _, stderr := run(t, "", "frobnicate")
+	if code != 2 || !strings.Contains(stderr, "unknown command") {
+		t.Errorf("exit code %d, stderr %q", code, stderr)
+	}
+	if code, stdout, _ := run(t, "", "help"); code != 0 || !strings.Contains(stdout, "complete") {
+		t.Errorf("help: exit code %d, stdout %q", code, stdout)
+	}
+}
+
+func TestRunMissingProject(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.gen-magic")
+	code, _, stderr := run(t, "", "list", "-project", path, "llms")
+	if code != 1 || !strings.HasPrefix(stderr, "error: ") {
+		t.Errorf("exit code %d, stderr %q", code, stderr)
+	}
+}
+
+func TestList(t *testing.T) {
+	path := newTestProject(t)
+	code, stdout, stderr := run(t, "", "list", "-project", path, "llms")
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	for _, want := range []string{"echo\tmock echo\n", "broken\tmock echo\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("list llms = %q, want a line %q", stdout, want)
+		}
+	}
+	code, stdout, stderr = run(t, "", "list", "-project", path, "-json", "agents")
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	var entries []listEntry
+	if err := json.Unmarshal([]byte(stdout), &entries); err != nil {
+		t.Fatalf("decoding %q: %v", stdout, err)
+	}
+	if !slices.Contains(entries, listEntry{Name: "helper", Detail: "echo"}) {
+		t.Errorf("list agents = %+v", entries)
+	}
+	// Tools do not need a project
+	if code, stdout, _ = run(t, "", "list", "tools"); code != 0 || !strings.Contains(stdout, "calculate\t") {
+		t.Errorf("list tools: exit code %d, stdout %q", code, stdout)
+	}
+	if code, _, _ = run(t, "", "list", "-project", path, "prompts"); code != 2 {
+		t.Errorf("list prompts: exit code %d, want 2", code)
+	}
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/qbradq/gen-magic/llm"
+)
+
+func init() {
+	register(&Command{
+		Name: "complete",
+		Usage: "[flags] [prompt...]",
+		Description: "Completes a prompt read from the arguments or stdin and streams the answer to stdout.",
+		Run: complete,
+	})
+}
+
+// completeResult is the JSON output of the complete command.
+type completeResult struct {
+	LLM string `json:"llm"`
+	Agent string `json:"agent,omitempty"`
+	Model string `json:"model,omitempty"`
+	Response string `json:"response"`
+	Reasoning string `json:"reasoning,omitempty"`
+	ToolCalls []toolCallResult `json:"tool_calls,omitempty"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Usage *usageResult `json:"usage,omitempty"`
+	FirstTokenMS int64 `json:"first_token_ms,omitempty"`
+	LatencyMS int64 `json:"latency_ms,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// toolCallResult is a tool call and its result in the JSON output.
+type toolCallResult struct {
+	Name string `json:"name"`
+	Arguments string `json:"arguments"`
+	Result string `json:"result,omitempty"`
+}
+
+// usageResult is the token usage in the JSON output.
+type usageResult struct {
+	PromptTokens int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+	CachedTokens int `json:"cached_tokens,omitempty"`
+	Cost float64 `json:"cost,omitempty"`
+}
+
+// complete implements the complete command.
+func complete(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs, path := newFlagSet("complete", stderr)
+	llmName := fs.String("llm", "", "name of the LLM definition, overrides the LLM of the agent")
+	agentName := fs.String("agent", "", "name of the agent")
+	system := fs.String("system", "", "system prompt, overrides the system prompt of the agent")
+	asJSON := fs.Bool("json", false, "write the turn as a JSON object after it ends instead of streaming the answer")
+	reasoning := fs.Bool("reasoning", false, "stream reasoning to stderr")
+	toolNames := fs.String("tools", "", "comma-separated names of the tools the LLM may call, or \"all\"")
+	temperature := fs.String("temperature", "", "temperature parameter")
+	maxTokens := fs.String("max-tokens", "", "max tokens parameter")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *llmName == "" && *agentName == "" {
+		fmt.Fprintln(stderr, "either -llm or -agent is required")
+		return 2
+	}
+	fail := func(err error) int {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	// Resolve the LLM definition, system prompt and tools
+	p, err := openProject(*path)
+	if err != nil {
+		return fail(err)
+	}
+	defer p.Close()
+	result := &completeResult{}
+	var def *llm.LanguageModel
+	systemPrompt := "You are a helpful AI assistant."
+	if *agentName != "" {
+		agent, err := p.FindAgent(*agentName)
+		if err != nil {
+			return fail(err)
+		}
+		result.Agent = agent.Name
+		def = agent.LLM
+		systemPrompt = p.ResolveAgent(agent).System.Content
+	}
+	if *llmName != "" {
+		if def, err = p.FindLLM(*llmName); err != nil {
+			return fail(err)
+		}
+	}
+	if *system != "" {
+		systemPrompt = *system
+	}
+	var params llm.Parameters
+	if params.Temperature, err = llm.ParseFloatParameter(*temperature); err != nil {
+		return fail(fmt.Errorf("invalid temperature: %w", err))
+	}
+	if params.MaxTokens, err = llm.ParseIntParameter(*maxTokens); err != nil {
+		return fail(fmt.Errorf("invalid max tokens: %w", err))
+	}
+	def.Parameters = def.Parameters.Merge(params)
+	tools, err := selectTools(*toolNames)
+	if err != nil {
+		return fail(err)
+	}
+	prompt, err := readPrompt(fs.Args(), stdin)
+	if err != nil {
+		return fail(err)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return fail(errors.New("empty prompt"))
+	}
+	// Stream the completion, canceling it on interrupt
+	turn := &llm.Turn{
+		Definition: *def,
+		System: &llm.Message{
+			Role: "system",
+			Content: systemPrompt,
+		},
+		Prompt: &llm.Message{
+			Role: "user",
+			Content: prompt,
+		},
+		Started: time.Now(),
+	}
+	events, cancel, err := llm.RunTools(def, turn.System, turn.Prompt, nil, tools, 0)
+	if err != nil {
+		return fail(err)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	newline := true
+	for e := range events {
+		turn.Apply(e)
+		if *asJSON {
+			continue
+		}
+		switch e.Type {
+		case llm.EventContent:
+			fmt.Fprint(stdout, e.Text)
+			newline = strings.HasSuffix(e.Text, "\n")
+		case llm.EventReasoning:
+			if *reasoning {
+				fmt.Fprint(stderr, e.Text)
+			}
+		}
+	}
+	// Report the turn
+	if *asJSON {
+		fillResult(result, turn)
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return fail(err)
+		}
+	} else if !newline {
+		fmt.Fprintln(stdout)
+	}
+	if turn.Error != nil {
+		if !*asJSON {
+			fmt.Fprintf(stderr, "error: %v\n", turn.Error)
+		}
+		return 1
+	}
+	return 0
+}
+
+// selectTools returns the registered tools named in the comma-separated list,
+// or all tools for "all".
+func selectTools(names string) ([]*llm.Tool, error) {
+	if names == "" {
+		return nil, nil
+	}
+	if names == "all" {
+		return llm.Tools(), nil
+	}
+	ret := []*llm.Tool{}
+	for _, name := range strings.Split(names, ",") {
+		t := llm.GetTool(strings.TrimSpace(name))
+		if t == nil {
+			return nil, fmt.Errorf("unknown tool \"%s\"", name)
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
+// fillResult fills the JSON output with the turn.
+func fillResult(r *completeResult, turn *llm.Turn) {
+	r.LLM = turn.Definition.Name
+	r.Model = turn.Model
+	if r.Model == "" {
+		r.Model = turn.Definition.Model
+	}
+	results := map[string]string{}
+	for _, msg := range turn.Response {
+		if msg.Role == "tool" {
+			results[msg.ToolCallID] = msg.Content
+			continue
+		}
+		r.Response += msg.Content
+	}
+	for _, call := range turn.ToolCalls {
+		r.ToolCalls = append(r.ToolCalls, toolCallResult{
+			Name: call.Name,
+			Arguments: call.Arguments,
+			Result: results[call.ID],
+		})
+	}
+	r.Reasoning = turn.Reasoning
+	r.FinishReason = turn.FinishReason
+	r.RequestID = turn.RequestID
+	if u := turn.Usage; u != nil {
+		r.Usage = &usageResult{
+			PromptTokens: u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+			ReasoningTokens: u.ReasoningTokens,
+			CachedTokens: u.CachedTokens,
+			Cost: u.Cost,
+		}
+	}
+	r.FirstTokenMS = turn.FirstTokenLatency.Milliseconds()
+	r.LatencyMS = turn.Latency.Milliseconds()
+	if turn.Error != nil {
+		r.Error = turn.Error.Error()
+	}
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCompletePrompt(t *testing.T) {
+	path := newTestProject(t)
+	for _, tc := range []struct {
+		name string
+		stdin string
+		args []string
+		want string
+	}{
+		{"args", "ignored", []string{"hello", "world"}, "hello world\n"},
+		{"stdin", "from stdin", nil, "from stdin\n"},
+		{"dash", "from stdin\n", []string{"-"}, "from stdin\n"},
+		{"agent", "", []string{"-agent", "helper", "hi there"}, "hi there\n"},
+	} {
+		args := []string{"complete", "-project", path}
+		if tc.name != "agent" {
+			args = append(args, "-llm", "echo")
+		}
+		code, stdout, stderr := run(t, tc.stdin, append(args, tc.args...)...)
+		if code != 0 {
+			t.Errorf("%s: exit code %d: %s", tc.name, code, stderr)
+			continue
+		}
+		if stdout != tc.want {
+			t.Errorf("%s: stdout %q, want %q", tc.name, stdout, tc.want)
+		}
+	}
+}
+
+func TestCompleteJSON(t *testing.T) {
+	path := newTestProject(t)
+	code, stdout, stderr := run(t, "", "complete", "-project", path, "-agent", "helper", "-json", "hello json")
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	var r completeResult
+	if err := json.Unmarshal([]byte(stdout), &r); err != nil {
+		t.Fatalf("decoding %q: %v", stdout, err)
+	}
+	if r.LLM != "echo" || r.Agent != "helper" || r.Model != "mock/echo" {
+		t.Errorf("llm %q, agent %q, model %q", r.LLM, r.Agent, r.Model)
+	}
+	if r.Response != "hello json" || r.FinishReason != "stop" || r.Error != "" {
+		t.Errorf("response %q, finish reason %q, error %q", r.Response, r.FinishReason, r.Error)
+	}
+	if !strings.HasPrefix(r.RequestID, "mock-") {
+		t.Errorf("request ID %q", r.RequestID)
+	}
+	if r.Usage == nil || r.Usage.PromptTokens != 2 || r.Usage.CompletionTokens != 2 {
+		t.Errorf("usage %+v", r.Usage)
+	}
+	// Failed turns are reported in the output with a non-zero exit code
+	code, stdout, _ = run(t, "", "complete", "-project", path, "-llm", "broken", "-json", "hello")
+	if code != 1 {
+		t.Errorf("broken: exit code %d, want 1", code)
+	}
+	r = completeResult{}
+	if err := json.Unmarshal([]byte(stdout), &r); err != nil {
+		t.Fatalf("decoding %q: %v", stdout, err)
+	}
+	if !strings.Contains(r.Error, "boom") {
+		t.Errorf("broken: error %q", r.Error)
+	}
+}
+
+func TestCompleteErrors(t *testing.T) {
+	path := newTestProject(t)
+	for _, tc := range []struct {
+		name string
+		args []string
+		code int
+		stderr string
+	}{
+		{"no llm or agent", []string{"hello"}, 2, "either -llm or -agent is required"},
+		{"unknown llm", []string{"-llm", "nope", "hello"}, 1, "not found"},
+		{"unknown agent", []string{"-agent", "nope", "hello"}, 1, "not found"},
+		{"unknown tool", []string{"-llm", "echo", "-tools", "nope", "hello"}, 1, "unknown tool"},
+		{"invalid temperature", []string{"-llm", "echo", "-temperature", "warm", "hello"}, 1, "invalid temperature"},
+		{"empty prompt", []string{"-llm", "echo"}, 1, "empty prompt"},
+		{"failed turn", []string{"-llm", "broken", "hello"}, 1, "boom"},
+	} {
+		args := append([]string{"complete", "-project", path}, tc.args...)
+		code, _, stderr := run(t, "", args...)
+		if code != tc.code || !strings.Contains(stderr, tc.stderr) {
+			t.Errorf("%s: exit code %d, stderr %q, want %d and %q", tc.name, code, stderr, tc.code, tc.stderr)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/qbradq/gen-magic/llm"
+)
+
+func init() {
+	register(&Command{
+		Name: "list",
+		Usage: "[flags] llms|agents|tools",
+		Description: "Lists the LLM definitions or agents of the project, or the tools.",
+		Run: list,
+	})
+}
+
+// listEntry is one entry of the list command's output.
+type listEntry struct {
+	Name string `json:"name"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// list implements the list command.
+func list(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs, path := newFlagSet("list", stderr)
+	asJSON := fs.Bool("json", false, "write the list as a JSON array")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	entries := []listEntry{}
+	switch fs.Arg(0) {
+	case "tools":
+		for _, t := range llm.Tools() {
+			entries = append(entries, listEntry{
+				Name: t.Name,
+				Detail: t.Description,
+			})
+		}
+	case "llms", "agents":
+		p, err := openProject(*path)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		defer p.Close()
+		if fs.Arg(0) == "llms" {
+			for _, n := range p.ListLLMs() {
+				def := p.GetLLM(n.ID)
+				entries = append(entries, listEntry{
+					Name: def.Name,
+					Detail: def.API + " " + def.Model,
+				})
+			}
+		} else {
+			for _, n := range p.ListAgents() {
+				agent := p.GetAgent(n.ID)
+				entries = append(entries, listEntry{
+					Name: agent.Name,
+					Detail: agent.LLM.Name,
+				})
+			}
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	for _, e := range entries {
+		fmt.Fprintf(stdout, "%s\t%s\n", e.Name, e.Detail)
+	}
+	return 0
+}
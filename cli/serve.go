@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/qbradq/gen-magic/gateway"
+)
+
+func init() {
+	register(&Command{
+		Name: "serve",
+		Usage: "[flags]",
+		Description: "Serves the LLM definitions and agents of the project as an OpenAI-compatible API.",
+		Run: serve,
+	})
+}
+
+// serve implements the serve command.
+func serve(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs, path := newFlagSet("serve", stderr)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	token := fs.String("token", os.Getenv("GEN_MAGIC_GATEWAY_TOKEN"), "bearer token clients must send, defaults to $GEN_MAGIC_GATEWAY_TOKEN")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	p, err := openProject(*path)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	defer p.Close()
+	g := gateway.New(p)
+	g.Token = *token
+	server := &http.Server{
+		Addr: *addr,
+		Handler: g,
+	}
+	// Shut down gracefully on interrupt
+	ctx, stop := interruptContext()
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(stderr, "serving %s on http://%s/v1\n", *path, *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a local address that is free to listen on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// startServe runs the serve command with the arguments until the test ends and
+// returns the base URL of the gateway once it accepts connections.
+func startServe(t *testing.T, args ...string) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	old := interruptContext
+	interruptContext = func() (context.Context, context.CancelFunc) {
+		return ctx, cancel
+	}
+	addr := freeAddr(t)
+	done := make(chan int, 1)
+	var stderr bytes.Buffer
+	go func() {
+		done <- Run(append([]string{"serve", "-addr", addr}, args...), strings.NewReader(""), &bytes.Buffer{}, &stderr)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if code := <-done; code != 0 {
+			t.Errorf("serve: exit code %d: %s", code, stderr.String())
+		}
+		interruptContext = old
+	})
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			c.Close()
+			return "http://" + addr
+		}
+	}
+	t.Fatalf("serve did not listen on %s", addr)
+	return ""
+}
+
+// getModels requests the model list with the bearer token, if any, and
+// returns the status code.
+func getModels(t *testing.T, base, token string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, base+"/v1/models", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("requesting models: %v", err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestServeToken(t *testing.T) {
+	t.Setenv("GEN_MAGIC_GATEWAY_TOKEN", "")
+	base := startServe(t, "-project", newTestProject(t), "-token", "secret")
+	for _, tc := range []struct {
+		token string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"wrong", http.StatusUnauthorized},
+		{"secret", http.StatusOK},
+	} {
+		if got := getModels(t, base, tc.token); got != tc.want {
+			t.Errorf("token %q: status %d, want %d", tc.token, got, tc.want)
+		}
+	}
+}
+
+func TestServeTokenFromEnv(t *testing.T) {
+	t.Setenv("GEN_MAGIC_GATEWAY_TOKEN", "from-env")
+	base := startServe(t, "-project", newTestProject(t))
+	if got := getModels(t, base, ""); got != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got := getModels(t, base, "from-env"); got != http.StatusOK {
+		t.Errorf("token from environment: status %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestServeWithoutToken(t *testing.T) {
+	t.Setenv("GEN_MAGIC_GATEWAY_TOKEN", "")
+	base := startServe(t, "-project", newTestProject(t))
+	if got := getModels(t, base, ""); got != http.StatusOK {
+		t.Errorf("status %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	code, _, stderr := run(t, "", "serve", "-project", newTestProject(t), "-addr", "127.0.0.1:-1")
+	if code != 1 || !strings.HasPrefix(stderr, "serving ") || !strings.Contains(stderr, "error: ") {
+		t.Errorf("exit code %d, stderr %q", code, stderr)
+	}
+}
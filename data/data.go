@@ -2,7 +2,7 @@ package data
 
 import (
 	"bytes"
-	_ "embed"
+	"embed"
 	"image"
 
 	_ "image/jpeg"
@@ -14,8 +14,8 @@ var DefaultSystem string
 //go:embed background.jpeg
 var bgImgData []byte
 
-//go:embed schema.sql
-var SchemaSQL string
+//go:embed migrations/*.sql
+var Migrations embed.FS
 
 //go:embed static-data.sql
 var StaticDataSQL string
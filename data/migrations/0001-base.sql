@@ -0,0 +1,94 @@
+/*******************************************************************************
+* 0001-base.sql
+*
+* Base database schema for Gen Magic. Tables are created only if they do not
+* exist so project files from before schema versioning are adopted as-is.
+*******************************************************************************/
+
+-- User settings
+CREATE TABLE IF NOT EXISTS Settings (
+    id TEXT PRIMARY KEY NOT NULL,
+    val TEXT
+);
+
+-- API info, populated from the LLM provider registry
+CREATE TABLE IF NOT EXISTS APIs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_str VARCHAR(32) UNIQUE NOT NULL,
+    name_txt VARCHAR(64) NOT NULL
+);
+
+-- LLM definitions
+CREATE TABLE IF NOT EXISTS LLMs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name_txt VARCHAR(64),
+    api INTEGER,
+    uri VARCHAR(255),
+    api_key VARCHAR(255),
+    model VARCHAR(255),
+    FOREIGN KEY (api) REFERENCES APIs(id)
+);
+
+-- Custom HTTP headers sent with requests to an LLM
+CREATE TABLE IF NOT EXISTS LLMHeaders (
+    llm INTEGER NOT NULL,
+    name_txt VARCHAR(255) NOT NULL,
+    val TEXT,
+    PRIMARY KEY (llm, name_txt),
+    FOREIGN KEY (llm) REFERENCES LLMs(id)
+);
+
+-- Agent definitions
+CREATE TABLE IF NOT EXISTS Agents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name_txt VARCHAR(64),
+    llm INTEGER,
+    sys_prompt TEXT,
+    FOREIGN KEY (llm) REFERENCES LLMs(id)
+);
+
+-- Chat sessions
+CREATE TABLE IF NOT EXISTS Sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name_txt VARCHAR(64),
+    agent INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (agent) REFERENCES Agents(id)
+);
+
+-- Turns of chat sessions
+CREATE TABLE IF NOT EXISTS Turns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session INTEGER NOT NULL,
+    llm INTEGER,
+    llm_name VARCHAR(64),
+    api VARCHAR(32),
+    model VARCHAR(255),
+    sys_prompt TEXT,
+    reasoning TEXT,
+    tool_calls TEXT,
+    finish_reason VARCHAR(64),
+    response_model VARCHAR(255),
+    request_id VARCHAR(255),
+    prompt_tokens INTEGER,
+    completion_tokens INTEGER,
+    error_kind INTEGER,
+    error_status INTEGER,
+    error_body TEXT,
+    error_txt TEXT,
+    FOREIGN KEY (session) REFERENCES Sessions(id),
+    FOREIGN KEY (llm) REFERENCES LLMs(id)
+);
+
+-- Prompt and response messages of turns
+CREATE TABLE IF NOT EXISTS Messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    turn INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    role VARCHAR(32),
+    content TEXT,
+    images TEXT,
+    FOREIGN KEY (turn) REFERENCES Turns(id)
+);
@@ -0,0 +1,9 @@
+/*******************************************************************************
+* 0002-parameters.sql
+*
+* Sampling parameters of LLM definitions and of the request of each turn,
+* stored as JSON.
+*******************************************************************************/
+
+ALTER TABLE LLMs ADD COLUMN params TEXT;
+ALTER TABLE Turns ADD COLUMN params TEXT;
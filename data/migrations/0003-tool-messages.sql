@@ -0,0 +1,10 @@
+/*******************************************************************************
+* 0003-tool-messages.sql
+*
+* Tool calls requested by response messages and the tool call results sent
+* back to the model.
+*******************************************************************************/
+
+ALTER TABLE Messages ADD COLUMN tool_calls TEXT;
+ALTER TABLE Messages ADD COLUMN tool_call_id VARCHAR(64);
+ALTER TABLE Messages ADD COLUMN tool_name VARCHAR(64);
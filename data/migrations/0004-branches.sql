@@ -0,0 +1,17 @@
+/*******************************************************************************
+* 0004-branches.sql
+*
+* Chat sessions become trees of turns. Each turn records the turn it continues
+* and each session the last turn of the branch shown. Existing sessions are
+* linear, so every turn continues the one before it.
+*******************************************************************************/
+
+ALTER TABLE Turns ADD COLUMN parent INTEGER REFERENCES Turns(id);
+ALTER TABLE Sessions ADD COLUMN active_turn INTEGER REFERENCES Turns(id);
+
+UPDATE Turns
+SET parent = (
+    SELECT MAX(t.id)
+    FROM Turns t
+    WHERE t.session = Turns.session AND t.id < Turns.id
+);
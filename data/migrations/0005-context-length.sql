@@ -0,0 +1,8 @@
+/*******************************************************************************
+* 0005-context-length.sql
+*
+* Context window of LLM definitions in tokens, NULL or zero to use the known
+* context length of the model.
+*******************************************************************************/
+
+ALTER TABLE LLMs ADD COLUMN context_length INTEGER;
@@ -0,0 +1,24 @@
+/*******************************************************************************
+* 0006-usage.sql
+*
+* Detailed usage, cost and latency of each turn, the time each turn was
+* started for daily totals, and the prices of LLM definitions used to estimate
+* costs the API does not report.
+*******************************************************************************/
+
+ALTER TABLE Turns ADD COLUMN reasoning_tokens INTEGER;
+ALTER TABLE Turns ADD COLUMN cached_tokens INTEGER;
+ALTER TABLE Turns ADD COLUMN cost REAL;
+ALTER TABLE Turns ADD COLUMN first_token_ms INTEGER;
+ALTER TABLE Turns ADD COLUMN latency_ms INTEGER;
+ALTER TABLE Turns ADD COLUMN created_at DATETIME;
+ALTER TABLE LLMs ADD COLUMN input_price REAL;
+ALTER TABLE LLMs ADD COLUMN output_price REAL;
+
+-- Turns of existing sessions are dated with the session
+UPDATE Turns
+SET created_at = (
+    SELECT Sessions.created_at
+    FROM Sessions
+    WHERE Sessions.id = Turns.session
+);
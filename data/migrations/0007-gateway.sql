@@ -0,0 +1,26 @@
+/*******************************************************************************
+* 0007-gateway.sql
+*
+* Log of the requests served by the OpenAI-compatible gateway.
+*******************************************************************************/
+
+CREATE TABLE GatewayRequests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    remote_addr VARCHAR(255),
+    model VARCHAR(255),
+    llm INTEGER,
+    agent INTEGER,
+    stream INTEGER,
+    status INTEGER,
+    request TEXT,
+    response TEXT,
+    finish_reason VARCHAR(64),
+    prompt_tokens INTEGER,
+    completion_tokens INTEGER,
+    cost REAL,
+    latency_ms INTEGER,
+    error_txt TEXT,
+    FOREIGN KEY (llm) REFERENCES LLMs(id),
+    FOREIGN KEY (agent) REFERENCES Agents(id)
+);
@@ -0,0 +1,29 @@
+/*******************************************************************************
+* 0008-exchanges.sql
+*
+* Captured HTTP requests and responses of turns for the inspector. Headers and
+* chunks are stored as JSON.
+*******************************************************************************/
+
+CREATE TABLE Exchanges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    turn INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    method VARCHAR(16),
+    url TEXT,
+    request_headers TEXT,
+    request_body TEXT,
+    status INTEGER,
+    response_headers TEXT,
+    response_body TEXT,
+    chunks TEXT,
+    truncated INTEGER,
+    started_at DATETIME,
+    dns_us INTEGER,
+    connect_us INTEGER,
+    tls_us INTEGER,
+    first_byte_us INTEGER,
+    total_us INTEGER,
+    error_txt TEXT,
+    FOREIGN KEY (turn) REFERENCES Turns(id)
+);
@@ -0,0 +1,23 @@
+/*******************************************************************************
+* 0009-prompts.sql
+*
+* Prompt library and custom template variables of agents.
+*******************************************************************************/
+
+-- Reusable prompts with text/template placeholders
+CREATE TABLE Prompts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name_txt VARCHAR(255),
+    description TEXT,
+    tags TEXT,
+    body TEXT
+);
+
+-- Custom variables of agent system prompt templates
+CREATE TABLE AgentVariables (
+    agent INTEGER NOT NULL,
+    name_txt VARCHAR(255) NOT NULL,
+    val TEXT,
+    PRIMARY KEY (agent, name_txt),
+    FOREIGN KEY (agent) REFERENCES Agents(id)
+);
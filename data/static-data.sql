@@ -4,13 +4,6 @@
 * Database data that should always exist
 *******************************************************************************/
 
--- List of all supported APIs
-INSERT INTO APIs (id_str, name_txt)
-VALUES (
-    'openrouter',
-    'OpenRouter.ai'
-);
-
 -- Default LLM definition
 INSERT INTO LLMs (name_txt, uri, model, api)
 VALUES (
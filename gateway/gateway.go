@@ -0,0 +1,364 @@
+// Package gateway implements an OpenAI-compatible HTTP server that exposes the
+// LLM definitions and agents of a project as models.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/qbradq/gen-magic/llm"
+	"github.com/qbradq/gen-magic/project"
+)
+
+// AgentPrefix is the prefix of the model names of agents.
+const AgentPrefix string = "agent:"
+
+// maxRequestSize is the maximum size of a request body in bytes.
+const maxRequestSize int64 = 32 << 20
+
+// Server serves /v1/models and /v1/chat/completions. Model names are the names
+// of the LLM definitions of the project and the names of its agents prefixed
+// with AgentPrefix. Completions with agents use the agent's LLM and system
+// prompt. Every completion request is logged in the project.
+type Server struct {
+	p *project.Project
+	mux *http.ServeMux
+	// Token is the bearer token clients must authorize with, empty to allow
+	// all clients.
+	Token string
+}
+
+// New returns a new Server for the project.
+func New(p *project.Project) *Server {
+	ret := &Server{
+		p: p,
+		mux: http.NewServeMux(),
+	}
+	ret.mux.HandleFunc("GET /v1/models", ret.models)
+	ret.mux.HandleFunc("POST /v1/chat/completions", ret.chatCompletions)
+	return ret
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusUnauthorized, "invalid_api_key", "invalid API key")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// writeJSON writes the value as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("error writing gateway response: %v\n", err)
+	}
+}
+
+// newError returns an error response body.
+func newError(kind, message string) *errorResponse {
+	ret := &errorResponse{}
+	ret.Error.Type = kind
+	ret.Error.Message = message
+	return ret
+}
+
+// writeError writes an error response.
+func writeError(w http.ResponseWriter, status int, kind, message string) {
+	writeJSON(w, status, newError(kind, message))
+}
+
+// models lists the LLM definitions and agents of the project.
+func (s *Server) models(w http.ResponseWriter, r *http.Request) {
+	ret := &modelList{
+		Object: "list",
+		Data: []*model{},
+	}
+	for _, n := range s.p.ListLLMs() {
+		ret.Data = append(ret.Data, &model{
+			ID: n.Name,
+			Object: "model",
+			OwnedBy: "gen-magic",
+		})
+	}
+	for _, n := range s.p.ListAgents() {
+		ret.Data = append(ret.Data, &model{
+			ID: AgentPrefix + n.Name,
+			Object: "model",
+			OwnedBy: "gen-magic",
+		})
+	}
+	writeJSON(w, http.StatusOK, ret)
+}
+
+// resolve returns the LLM definition and agent system prompt of the model
+// name and records their IDs in the log entry.
+func (s *Server) resolve(name string, entry *project.GatewayRequest) (*llm.LanguageModel, string, error) {
+	if agentName, ok := strings.CutPrefix(name, AgentPrefix); ok {
+		agent, err := s.p.FindAgent(agentName)
+		if err != nil {
+			return nil, "", err
+		}
+		entry.AgentID = agent.ID
+		entry.LLMID = agent.LLM.ID
+		return agent.LLM, s.p.ResolveAgent(agent).System.Content, nil
+	}
+	def, err := s.p.FindLLM(name)
+	if err != nil {
+		return nil, "", err
+	}
+	entry.LLMID = def.ID
+	return def, "", nil
+}
+
+// chatCompletions proxies a chat completion to the LLM definition or agent
+// named by the model of the request.
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	entry := &project.GatewayRequest{
+		RemoteAddr: r.RemoteAddr,
+	}
+	defer func() {
+		entry.Latency = time.Since(started)
+		if err := s.p.LogGatewayRequest(entry); err != nil {
+			log.Printf("error logging gateway request: %v\n", err)
+		}
+	}()
+	fail := func(status int, kind string, err error) {
+		entry.Status = status
+		entry.Error = err.Error()
+		writeError(w, status, kind, err.Error())
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		fail(http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+	entry.Request = string(body)
+	req := &chatRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		fail(http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+	entry.Model = req.Model
+	entry.Stream = req.Stream
+	// Build the completion
+	def, agentSystem, err := s.resolve(req.Model, entry)
+	if errors.Is(err, project.ErrNotFound) {
+		fail(http.StatusNotFound, "model_not_found", err)
+		return
+	} else if err != nil {
+		fail(http.StatusInternalServerError, "api_error", err)
+		return
+	}
+	params, err := req.parameters()
+	if err != nil {
+		fail(http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+	def.Parameters = def.Parameters.Merge(params)
+	system, turns, prompt, err := req.conversation()
+	if err != nil {
+		fail(http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+	if agentSystem != "" && system != "" {
+		system = agentSystem + "\n\n" + system
+	} else if agentSystem != "" {
+		system = agentSystem
+	}
+	turn := &llm.Turn{
+		Definition: *def,
+		Prompt: prompt,
+		Started: started,
+	}
+	if system != "" {
+		turn.System = &llm.Message{
+			Role: "system",
+			Content: system,
+		}
+	}
+	events, cancel, err := llm.ChatCompletion(def, turn.System, prompt, turns, req.tools())
+	if err != nil {
+		fail(http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+	// Cancel the completion when the client goes away
+	stop := context.AfterFunc(r.Context(), cancel)
+	defer stop()
+	id := fmt.Sprintf("chatcmpl-%d", started.UnixNano())
+	if req.Stream {
+		s.stream(w, req, id, turn, events, entry)
+	} else {
+		s.respond(w, req, id, turn, events, entry)
+	}
+}
+
+// respond writes the completion as a single response.
+func (s *Server) respond(w http.ResponseWriter, req *chatRequest, id string, turn *llm.Turn, events chan *llm.Event, entry *project.GatewayRequest) {
+	for e := range events {
+		turn.Apply(e)
+	}
+	logTurn(entry, turn)
+	if turn.Error != nil {
+		entry.Status = errorStatus(turn.Error)
+		writeError(w, entry.Status, "api_error", turn.Error.Error())
+		return
+	}
+	content := entry.Response
+	msg := &chatResponseMessage{
+		Role: "assistant",
+		Content: &content,
+		ReasoningContent: turn.Reasoning,
+	}
+	for _, call := range turn.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, &chatToolCall{
+			ID: call.ID,
+			Type: "function",
+			Function: chatFunction{
+				Name: call.Name,
+				Arguments: call.Arguments,
+			},
+		})
+	}
+	reason := finishReason(turn)
+	entry.Status = http.StatusOK
+	writeJSON(w, entry.Status, &chatResponse{
+		ID: id,
+		Object: "chat.completion",
+		Created: turn.Started.Unix(),
+		Model: req.Model,
+		Choices: []*chatChoice{{
+			Message: msg,
+			FinishReason: &reason,
+		}},
+		Usage: usage(turn),
+	})
+}
+
+// stream writes the completion as a stream of server-sent events.
+func (s *Server) stream(w http.ResponseWriter, req *chatRequest, id string, turn *llm.Turn, events chan *llm.Event, entry *project.GatewayRequest) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	entry.Status = http.StatusOK
+	flusher, _ := w.(http.Flusher)
+	send := func(v any) {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			log.Printf("error encoding gateway event: %v\n", err)
+			return
+		}
+		fmt.Fprintf(w, "data: %s\n\n", buf)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	chunk := func(delta *chatResponseMessage, reason *string) *chatResponse {
+		return &chatResponse{
+			ID: id,
+			Object: "chat.completion.chunk",
+			Created: turn.Started.Unix(),
+			Model: req.Model,
+			Choices: []*chatChoice{{
+				Delta: delta,
+				FinishReason: reason,
+			}},
+		}
+	}
+	role := "assistant"
+	for e := range events {
+		turn.Apply(e)
+		delta := &chatResponseMessage{}
+		switch e.Type {
+		case llm.EventContent:
+			delta.Content = &e.Text
+		case llm.EventReasoning:
+			delta.ReasoningContent = e.Text
+		case llm.EventToolCall:
+			tc := &chatToolCall{
+				Index: &e.ToolCall.Index,
+				ID: e.ToolCall.ID,
+				Function: chatFunction{
+					Name: e.ToolCall.Name,
+					Arguments: e.ToolCall.Arguments,
+				},
+			}
+			if tc.ID != "" {
+				tc.Type = "function"
+			}
+			delta.ToolCalls = []*chatToolCall{tc}
+		default:
+			continue
+		}
+		delta.Role, role = role, ""
+		send(chunk(delta, nil))
+	}
+	logTurn(entry, turn)
+	if turn.Error != nil {
+		entry.Status = errorStatus(turn.Error)
+		send(newError("api_error", turn.Error.Error()))
+	} else {
+		reason := finishReason(turn)
+		send(chunk(&chatResponseMessage{}, &reason))
+		if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+			send(&chatResponse{
+				ID: id,
+				Object: "chat.completion.chunk",
+				Created: turn.Started.Unix(),
+				Model: req.Model,
+				Choices: []*chatChoice{},
+				Usage: usage(turn),
+			})
+		}
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// logTurn records the response of the turn in the log entry.
+func logTurn(entry *project.GatewayRequest, turn *llm.Turn) {
+	for _, msg := range turn.Response {
+		entry.Response += msg.Content
+	}
+	entry.FinishReason = turn.FinishReason
+	entry.Usage = turn.Usage
+	if turn.Error != nil {
+		entry.Error = turn.Error.Error()
+	}
+}
+
+// usage returns the token usage of the turn, or nil if not reported.
+func usage(turn *llm.Turn) *chatUsage {
+	if turn.Usage == nil {
+		return nil
+	}
+	return &chatUsage{
+		PromptTokens: turn.Usage.PromptTokens,
+		CompletionTokens: turn.Usage.CompletionTokens,
+		TotalTokens: turn.Usage.PromptTokens + turn.Usage.CompletionTokens,
+	}
+}
+
+// errorStatus returns the HTTP status code of the response to a failed
+// completion.
+func errorStatus(err *llm.Error) int {
+	switch {
+	case err.Kind == llm.ErrorKindHTTPStatus && err.StatusCode >= 400:
+		return err.StatusCode
+	case err.Kind == llm.ErrorKindTimeout:
+		return http.StatusGatewayTimeout
+	case err.Kind == llm.ErrorKindCanceled:
+		return 499
+	default:
+		return http.StatusBadGateway
+	}
+}
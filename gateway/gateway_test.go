@@ -0,0 +1,244 @@
+package gateway
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/qbradq/gen-magic/project"
+)
+
+// upstreamStream is the streamed response of the stand-in OpenAI-compatible
+// API, which reports usage after the finish reason.
+const upstreamStream string = `data: {"id":"u1","model":"up","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}
+
+data: {"id":"u1","model":"up","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: {"id":"u1","model":"up","choices":[],"usage":{"prompt_tokens":7,"completion_tokens":2}}
+
+data: [DONE]
+
+`
+
+// newUpstream returns a stand-in OpenAI-compatible API that streams
+// upstreamStream and sends the decoded request bodies on the returned channel.
+func newUpstream(t *testing.T) (*httptest.Server, chan map[string]any) {
+	t.Helper()
+	bodies := make(chan map[string]any, 1024)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		buf, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(buf, &body); err != nil {
+			t.Errorf("decoding upstream request: %v", err)
+		}
+		bodies <- body
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, upstreamStream)
+	}))
+	t.Cleanup(s.Close)
+	return s, bodies
+}
+
+// newTestProject returns a new project in a temporary directory with an LLM
+// named "up" using the upstream API, and the path of its database.
+func newTestProject(t *testing.T, upstream string) (*project.Project, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.gen-magic")
+	p := &project.Project{}
+	if err := p.Load("sqlite", path); err != nil {
+		t.Fatalf("loading project: %v", err)
+	}
+	t.Cleanup(func() {
+		p.Close()
+	})
+	def := p.NewLLM()
+	def.Name = "up"
+	def.API = "openai-compatible"
+	def.APIEndpoint = upstream
+	def.Model = "up-model"
+	if err := p.SetLLM(def); err != nil {
+		t.Fatalf("saving LLM: %v", err)
+	}
+	return p, path
+}
+
+// post sends a chat completion request to the gateway and returns the
+// response.
+func post(t *testing.T, gw *httptest.Server, body string) *http.Response {
+	t.Helper()
+	res, err := http.Post(gw.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("posting to gateway: %v", err)
+	}
+	return res
+}
+
+// events returns the decoded data of the server-sent events of a streamed
+// response, not including the final [DONE].
+func events(t *testing.T, res *http.Response) []*chatResponse {
+	t.Helper()
+	defer res.Body.Close()
+	buf, _ := io.ReadAll(res.Body)
+	ret := []*chatResponse{}
+	for _, e := range strings.Split(string(buf), "\n\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(e), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		r := &chatResponse{}
+		if err := json.Unmarshal([]byte(data), r); err != nil {
+			t.Fatalf("decoding event %q: %v", data, err)
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func TestStreamUsage(t *testing.T) {
+	upstream, bodies := newUpstream(t)
+	p, _ := newTestProject(t, upstream.URL)
+	gw := httptest.NewServer(New(p))
+	defer gw.Close()
+	for _, tc := range []struct {
+		name string
+		options string
+		wantUsage bool
+	}{
+		{"without stream options", ``, false},
+		{"without usage", `,"stream_options":{"include_usage":false}`, false},
+		{"with usage", `,"stream_options":{"include_usage":true}`, true},
+	} {
+		res := post(t, gw, `{"model":"up","stream":true,"messages":[{"role":"user","content":"Hello"}]`+tc.options+`}`)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status %d", tc.name, res.StatusCode)
+		}
+		evs := events(t, res)
+		// The upstream is always asked for usage so that it can be logged
+		body := <-bodies
+		if opts, _ := body["stream_options"].(map[string]any); opts["include_usage"] != true {
+			t.Errorf("%s: upstream request did not ask for usage: %v", tc.name, body)
+		}
+		var usage *chatUsage
+		for _, e := range evs {
+			if e.Usage != nil {
+				usage = e.Usage
+			}
+		}
+		if !tc.wantUsage {
+			if usage != nil {
+				t.Errorf("%s: unexpected usage chunk %+v", tc.name, usage)
+			}
+			continue
+		}
+		last := evs[len(evs)-1]
+		if usage == nil || last.Usage != usage || len(last.Choices) != 0 {
+			t.Fatalf("%s: usage was not sent in a final chunk without choices", tc.name)
+		}
+		if usage.PromptTokens != 7 || usage.CompletionTokens != 2 || usage.TotalTokens != 9 {
+			t.Errorf("%s: usage = %+v", tc.name, usage)
+		}
+	}
+}
+
+func TestConcurrentRequestsLogged(t *testing.T) {
+	upstream, _ := newUpstream(t)
+	p, path := newTestProject(t, upstream.URL)
+	gw := httptest.NewServer(New(p))
+	defer gw.Close()
+	const n = 64
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := http.Post(gw.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"up","stream":true,"messages":[{"role":"user","content":"Hello"}]}`))
+			if err != nil {
+				t.Errorf("posting to gateway: %v", err)
+				return
+			}
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}()
+	}
+	wg.Wait()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	defer db.Close()
+	count := 0
+	if err := db.QueryRow(`SELECT COUNT(*) FROM GatewayRequests;`).Scan(&count); err != nil {
+		t.Fatalf("counting requests: %v", err)
+	}
+	if count != n {
+		t.Errorf("logged %d of %d requests", count, n)
+	}
+	// Project files must stay single files
+	mode := ""
+	if err := db.QueryRow(`PRAGMA journal_mode;`).Scan(&mode); err != nil || mode != "delete" {
+		t.Errorf("journal mode = %q, %v", mode, err)
+	}
+	if _, err := os.Stat(path + "-wal"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected WAL file: %v", err)
+	}
+}
+
+func TestMissingModels(t *testing.T) {
+	upstream, _ := newUpstream(t)
+	p, path := newTestProject(t, upstream.URL)
+	def, err := p.FindLLM("up")
+	if err != nil {
+		t.Fatalf("finding LLM: %v", err)
+	}
+	agent := p.NewAgent()
+	agent.Name = "helper"
+	agent.LLM = def
+	p.SetAgent(agent)
+	// LLMs used by agents are not deleted
+	if err := p.DeleteLLM(def); err == nil {
+		t.Fatalf("deleted the LLM of an agent")
+	}
+	gw := httptest.NewServer(New(p))
+	defer gw.Close()
+	status := func(model string) int {
+		t.Helper()
+		res := post(t, gw, `{"model":"`+model+`","messages":[{"role":"user","content":"Hello"}]}`)
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if s := status("agent:helper"); s != http.StatusOK {
+		t.Errorf("agent status %d", s)
+	}
+	if s := status("missing"); s != http.StatusNotFound {
+		t.Errorf("missing LLM status %d", s)
+	}
+	if s := status("agent:missing"); s != http.StatusNotFound {
+		t.Errorf("missing agent status %d", s)
+	}
+	// Agents left without an LLM by older versions fail without stopping
+	// the server
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`DELETE FROM LLMs WHERE id = ?;`, def.ID); err != nil {
+		t.Fatalf("deleting LLM: %v", err)
+	}
+	if s := status("agent:helper"); s != http.StatusInternalServerError {
+		t.Errorf("dangling agent status %d", s)
+	}
+	if s := status("up"); s != http.StatusNotFound {
+		t.Errorf("deleted LLM status %d", s)
+	}
+}
@@ -0,0 +1,270 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// chatRequest is the body of a chat completion request.
+type chatRequest struct {
+	Model string `json:"model"`
+	Messages []*chatMessage `json:"messages"`
+	Stream bool `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	Temperature *float64 `json:"temperature"`
+	TopP *float64 `json:"top_p"`
+	MaxTokens *int `json:"max_tokens"`
+	MaxCompletionTokens *int `json:"max_completion_tokens"`
+	FrequencyPenalty *float64 `json:"frequency_penalty"`
+	PresencePenalty *float64 `json:"presence_penalty"`
+	Stop json.RawMessage `json:"stop"`
+	Seed *int `json:"seed"`
+	Tools []*chatTool `json:"tools"`
+}
+
+// chatMessage is a message of a chat completion request or response.
+type chatMessage struct {
+	Role string `json:"role"`
+	// Content is a string or an array of content parts in requests.
+	Content json.RawMessage `json:"content"`
+	ToolCalls []*chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// chatContentPart is a part of the content of a message.
+type chatContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// chatTool is a tool offered in a chat completion request.
+type chatTool struct {
+	Type string `json:"type"`
+	Function struct {
+		Name string `json:"name"`
+		Description string `json:"description"`
+		Parameters json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+// chatToolCall is a tool call, or a tool call delta in streamed responses.
+type chatToolCall struct {
+	Index *int `json:"index,omitempty"`
+	ID string `json:"id,omitempty"`
+	Type string `json:"type,omitempty"`
+	Function chatFunction `json:"function"`
+}
+
+// chatFunction is the function of a tool call.
+type chatFunction struct {
+	Name string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// chatResponse is a chat completion response or streamed chunk.
+type chatResponse struct {
+	ID string `json:"id"`
+	Object string `json:"object"`
+	Created int64 `json:"created"`
+	Model string `json:"model"`
+	Choices []*chatChoice `json:"choices"`
+	Usage *chatUsage `json:"usage,omitempty"`
+}
+
+// chatChoice is a choice of a chat completion response. Message is set in
+// responses and Delta in streamed chunks.
+type chatChoice struct {
+	Index int `json:"index"`
+	Message *chatResponseMessage `json:"message,omitempty"`
+	Delta *chatResponseMessage `json:"delta,omitempty"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+// chatResponseMessage is the response message or delta of a choice.
+type chatResponseMessage struct {
+	Role string `json:"role,omitempty"`
+	Content *string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	ToolCalls []*chatToolCall `json:"tool_calls,omitempty"`
+}
+
+// chatUsage is the token usage of a chat completion.
+type chatUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens int `json:"total_tokens"`
+}
+
+// errorResponse is the body of error responses and streamed errors.
+type errorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type string `json:"type"`
+		Code string `json:"code,omitempty"`
+	} `json:"error"`
+}
+
+// modelList is the body of the model list response.
+type modelList struct {
+	Object string `json:"object"`
+	Data []*model `json:"data"`
+}
+
+// model is an entry of the model list.
+type model struct {
+	ID string `json:"id"`
+	Object string `json:"object"`
+	Created int64 `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// parameters returns the completion parameters of the request.
+func (r *chatRequest) parameters() (llm.Parameters, error) {
+	ret := llm.Parameters{
+		Temperature: r.Temperature,
+		TopP: r.TopP,
+		MaxTokens: r.MaxTokens,
+		FrequencyPenalty: r.FrequencyPenalty,
+		PresencePenalty: r.PresencePenalty,
+		Seed: r.Seed,
+	}
+	if r.MaxCompletionTokens != nil {
+		ret.MaxTokens = r.MaxCompletionTokens
+	}
+	if len(r.Stop) > 0 && string(r.Stop) != "null" {
+		var stop string
+		if err := json.Unmarshal(r.Stop, &stop); err == nil {
+			ret.Stop = []string{stop}
+		} else if err := json.Unmarshal(r.Stop, &ret.Stop); err != nil {
+			return ret, errors.New("stop must be a string or an array of strings")
+		}
+	}
+	return ret, nil
+}
+
+// tools returns the definitions of the tools offered in the request.
+func (r *chatRequest) tools() []*llm.ToolDefinition {
+	ret := []*llm.ToolDefinition{}
+	for _, t := range r.Tools {
+		if t.Type != "" && t.Type != "function" {
+			continue
+		}
+		ret = append(ret, &llm.ToolDefinition{
+			Name: t.Function.Name,
+			Description: t.Function.Description,
+			Parameters: t.Function.Parameters,
+		})
+	}
+	return ret
+}
+
+// conversation splits the messages of the request into the system prompt,
+// the chat context and the prompt. Each user message starts a turn that the
+// assistant and tool messages after it are the response of. The prompt is nil
+// if the conversation continues after tool results.
+func (r *chatRequest) conversation() (system string, turns []*llm.Turn, prompt *llm.Message, err error) {
+	systems := []string{}
+	for _, m := range r.Messages {
+		msg, err := m.message()
+		if err != nil {
+			return "", nil, nil, err
+		}
+		switch msg.Role {
+		case "system", "developer":
+			systems = append(systems, msg.Content)
+		case "user":
+			turns = append(turns, &llm.Turn{
+				Prompt: msg,
+			})
+		case "assistant", "tool":
+			if len(turns) == 0 {
+				return "", nil, nil, fmt.Errorf("%s message before the first user message", msg.Role)
+			}
+			turn := turns[len(turns)-1]
+			turn.Response = append(turn.Response, msg)
+		default:
+			return "", nil, nil, fmt.Errorf("unsupported role \"%s\"", msg.Role)
+		}
+	}
+	if len(turns) == 0 {
+		return "", nil, nil, errors.New("no user message")
+	}
+	last := turns[len(turns)-1]
+	if len(last.Response) == 0 {
+		prompt = last.Prompt
+		turns = turns[:len(turns)-1]
+	} else if last.Response[len(last.Response)-1].Role != "tool" {
+		return "", nil, nil, errors.New("the last message must be a user or tool message")
+	}
+	return strings.Join(systems, "\n\n"), turns, prompt, nil
+}
+
+// message returns the request message as an LLM message.
+func (m *chatMessage) message() (*llm.Message, error) {
+	ret := &llm.Message{
+		Role: m.Role,
+		ToolCallID: m.ToolCallID,
+		Name: m.Name,
+	}
+	for _, tc := range m.ToolCalls {
+		ret.ToolCalls = append(ret.ToolCalls, &llm.ToolCall{
+			ID: tc.ID,
+			Name: tc.Function.Name,
+			Arguments: tc.Function.Arguments,
+		})
+	}
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return ret, nil
+	}
+	if err := json.Unmarshal(m.Content, &ret.Content); err == nil {
+		return ret, nil
+	}
+	parts := []*chatContentPart{}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return nil, errors.New("message content must be a string or an array of content parts")
+	}
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			ret.Content += part.Text
+		case "image_url":
+			if part.ImageURL == nil || !strings.HasPrefix(part.ImageURL.URL, "data:") {
+				return nil, errors.New("only data URLs are supported for images")
+			}
+			img := &llm.Image{}
+			if err := img.SetImageBase64(part.ImageURL.URL); err != nil {
+				return nil, fmt.Errorf("invalid image: %w", err)
+			}
+			ret.Images = append(ret.Images, img)
+		default:
+			return nil, fmt.Errorf("unsupported content part type \"%s\"", part.Type)
+		}
+	}
+	return ret, nil
+}
+
+// finishReason maps the finish reason reported by the API to the finish
+// reasons of OpenAI.
+func finishReason(turn *llm.Turn) string {
+	if len(turn.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+	switch strings.ToLower(turn.FinishReason) {
+	case "length", "max_tokens":
+		return "length"
+	case "content_filter", "safety", "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
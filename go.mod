@@ -4,8 +4,10 @@ go 1.25.3
 
 require (
 	fyne.io/fyne/v2 v2.7.0
-	github.com/revrost/go-openrouter v0.2.6
+	golang.org/x/crypto v0.33.0
+	golang.org/x/image v0.28.0
 	golang.org/x/text v0.26.0
+	modernc.org/sqlite v1.39.1
 )
 
 require (
@@ -42,12 +44,10 @@ require (
 	github.com/stretchr/testify v1.11.1 // indirect
 	github.com/yuin/goldmark v1.7.8 // indirect
 	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
-	golang.org/x/image v0.28.0 // indirect
 	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	modernc.org/libc v1.66.10 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
-	modernc.org/sqlite v1.39.1 // indirect
 )
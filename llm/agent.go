@@ -6,4 +6,6 @@ type Agent struct {
 	Name string
 	LLM *LanguageModel
 	System Message
+	// Variables are the custom variables of the system prompt template.
+	Variables map[string]string
 }
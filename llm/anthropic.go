@@ -0,0 +1,392 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// anthropicDefaultEndpoint is the endpoint used when the LLM definition does
+// not name one.
+const anthropicDefaultEndpoint string = "https://api.anthropic.com"
+
+// anthropicVersion is the value of the anthropic-version header.
+const anthropicVersion string = "2023-06-01"
+
+// anthropicMaxTokens is the value of the required max_tokens field if the
+// parameters do not set it.
+const anthropicMaxTokens int = 4096
+
+func init() {
+	RegisterProvider(&anthropicProvider{})
+}
+
+// anthropicProvider implements the Anthropic Messages API.
+type anthropicProvider struct{}
+
+// anthropicSource is the source of an image content block.
+type anthropicSource struct {
+	Type string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data string `json:"data"`
+}
+
+// anthropicContent is one content block of a message.
+type anthropicContent struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	Source *anthropicSource `json:"source,omitempty"`
+	// Tool use blocks
+	ID string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// Tool result blocks
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// anthropicTool is a tool offered to the model.
+type anthropicTool struct {
+	Name string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// anthropicMessage is one message of a Messages request.
+type anthropicMessage struct {
+	Role string `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+// anthropicRequest is the body of a Messages request.
+type anthropicRequest struct {
+	Model string `json:"model"`
+	MaxTokens int `json:"max_tokens"`
+	System string `json:"system,omitempty"`
+	Messages []anthropicMessage `json:"messages"`
+	Stream bool `json:"stream"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP *float64 `json:"top_p,omitempty"`
+	TopK *int `json:"top_k,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	Tools []anthropicTool `json:"tools,omitempty"`
+}
+
+// anthropicUsage is the token usage reported in the stream.
+type anthropicUsage struct {
+	InputTokens int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	CacheReadInputTokens int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
+// anthropicEvent is one event of a streamed Messages response. Only the
+// fields used by the provider are decoded.
+type anthropicEvent struct {
+	Type string `json:"type"`
+	Message struct {
+		ID string `json:"id"`
+		Model string `json:"model"`
+		Role string `json:"role"`
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Index int `json:"index"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+		Thinking string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ID implements Provider.
+func (p *anthropicProvider) ID() string { return "anthropic" }
+
+// Name implements Provider.
+func (p *anthropicProvider) Name() string { return "Anthropic" }
+
+// Capabilities implements Provider.
+func (p *anthropicProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Streaming: true,
+		Images: true,
+		ListModels: true,
+		RequiresAPIKey: true,
+		Tools: true,
+	}
+}
+
+// ValidateConfig implements Provider.
+func (p *anthropicProvider) ValidateConfig(def *LanguageModel) error {
+	if def.APIKey == "" {
+		return errors.New("an API key is required in LLM configuration for Anthropic")
+	}
+	if def.Model == "" {
+		return errors.New("a model is required in LLM configuration for Anthropic")
+	}
+	return nil
+}
+
+// url returns the URL of the API method at path, which does not include the
+// version prefix.
+func (p *anthropicProvider) url(def *LanguageModel, path string) string {
+	base := def.APIEndpoint
+	if base == "" {
+		base = anthropicDefaultEndpoint
+	}
+	base = strings.TrimSuffix(strings.TrimRight(base, "/"), "/v1")
+	return endpointURL(base, "/v1"+path)
+}
+
+// headers returns the HTTP headers to send with every request.
+func (p *anthropicProvider) headers(def *LanguageModel) map[string]string {
+	ret := map[string]string{
+		"x-api-key": def.APIKey,
+		"anthropic-version": anthropicVersion,
+	}
+	for k, v := range def.Headers {
+		ret[k] = v
+	}
+	return ret
+}
+
+// ListModels implements Provider.
+func (p *anthropicProvider) ListModels(def *LanguageModel) ([]string, error) {
+	var res struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	err := getJSON(context.Background(), p.url(def, "/models"), p.headers(def), &res)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, model := range res.Data {
+		ret = append(ret, model.ID)
+	}
+	return ret, nil
+}
+
+// Request implements RequestBuilder.
+func (p *anthropicProvider) Request(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (*Request, error) {
+	req := anthropicRequest{
+		Model: def.Model,
+		MaxTokens: anthropicMaxTokens,
+		Messages: []anthropicMessage{},
+		Stream: true,
+		Temperature: def.Parameters.Temperature,
+		TopP: def.Parameters.TopP,
+		TopK: def.Parameters.TopK,
+		StopSequences: def.Parameters.Stop,
+	}
+	if def.Parameters.MaxTokens != nil {
+		req.MaxTokens = *def.Parameters.MaxTokens
+	}
+	if system != nil {
+		req.System = system.Content
+	}
+	for _, t := range tools {
+		schema := t.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		req.Tools = append(req.Tools, anthropicTool{
+			Name: t.Name,
+			Description: t.Description,
+			InputSchema: schema,
+		})
+	}
+	for _, msg := range contextMessages(prompt, chatContext) {
+		role := msg.Role
+		content := []anthropicContent{}
+		if role == "tool" {
+			// Tool results are sent as content blocks of a user message
+			role = "user"
+			content = append(content, anthropicContent{
+				Type: "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content: msg.Content,
+			})
+		}
+		for _, img := range msg.Images {
+			mimeType, data := img.encoded("image/png", "image/jpeg", "image/webp", "image/gif")
+			content = append(content, anthropicContent{
+				Type: "image",
+				Source: &anthropicSource{
+					Type: "base64",
+					MediaType: mimeType,
+					Data: data,
+				},
+			})
+		}
+		if msg.Content != "" && msg.Role != "tool" {
+			content = append(content, anthropicContent{
+				Type: "text",
+				Text: msg.Content,
+			})
+		}
+		for _, tc := range msg.ToolCalls {
+			input := json.RawMessage(tc.Arguments)
+			if tc.Arguments == "" {
+				input = json.RawMessage("{}")
+			}
+			content = append(content, anthropicContent{
+				Type: "tool_use",
+				ID: tc.ID,
+				Name: tc.Name,
+				Input: input,
+			})
+		}
+		// The API requires alternating roles so merge consecutive messages
+		// from the same role
+		n := len(req.Messages)
+		if n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, content...)
+			continue
+		}
+		req.Messages = append(req.Messages, anthropicMessage{
+			Role: role,
+			Content: content,
+		})
+	}
+	return &Request{
+		Method: http.MethodPost,
+		URL: p.url(def, "/messages"),
+		Headers: p.headers(def),
+		Body: req,
+	}, nil
+}
+
+// StreamCompletion implements Provider.
+func (p *anthropicProvider) StreamCompletion(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (chan *Event, func(), error) {
+	req, err := p.Request(def, system, prompt, chatContext, tools)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan *Event, 1024)
+	ctx = withCapture(ctx, out)
+	go func() {
+		defer close(out)
+		res, err := doJSON(ctx, req.Method, req.URL, req.Headers, req.Body)
+		if err != nil {
+			sendError(out, err)
+			return
+		}
+		defer res.Body.Close()
+		usage := &Usage{}
+		finish := &Event{
+			Type: EventFinish,
+		}
+		role := "assistant"
+		// Maps content block indexes to tool call indexes
+		toolCalls := map[int]int{}
+		var streamErr error
+		stopped := false
+		err = readSSE(res.Body, func(event, data string) bool {
+			var e anthropicEvent
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				streamErr = err
+				return false
+			}
+			switch e.Type {
+			case "message_start":
+				// Input tokens do not include the cached ones
+				mu := e.Message.Usage
+				usage.PromptTokens = mu.InputTokens + mu.CacheReadInputTokens + mu.CacheCreationInputTokens
+				usage.CachedTokens = mu.CacheReadInputTokens
+				usage.CompletionTokens = mu.OutputTokens
+				finish.Model = e.Message.Model
+				finish.RequestID = e.Message.ID
+				if e.Message.Role != "" {
+					role = e.Message.Role
+				}
+			case "content_block_start":
+				if e.ContentBlock.Type != "tool_use" {
+					break
+				}
+				toolCalls[e.Index] = len(toolCalls)
+				out <- &Event{
+					Type: EventToolCall,
+					ToolCall: &ToolCallDelta{
+						Index: toolCalls[e.Index],
+						ID: e.ContentBlock.ID,
+						Name: e.ContentBlock.Name,
+					},
+				}
+			case "content_block_delta":
+				switch e.Delta.Type {
+				case "text_delta":
+					out <- &Event{
+						Type: EventContent,
+						Role: role,
+						Text: e.Delta.Text,
+					}
+				case "thinking_delta":
+					out <- &Event{
+						Type: EventReasoning,
+						Text: e.Delta.Thinking,
+					}
+				case "input_json_delta":
+					out <- &Event{
+						Type: EventToolCall,
+						ToolCall: &ToolCallDelta{
+							Index: toolCalls[e.Index],
+							Arguments: e.Delta.PartialJSON,
+						},
+					}
+				}
+			case "message_delta":
+				if e.Usage.InputTokens > 0 {
+					usage.PromptTokens = e.Usage.InputTokens + e.Usage.CacheReadInputTokens + e.Usage.CacheCreationInputTokens
+					usage.CachedTokens = e.Usage.CacheReadInputTokens
+				}
+				usage.CompletionTokens = e.Usage.OutputTokens
+				finish.FinishReason = e.Delta.StopReason
+				out <- &Event{
+					Type: EventUsage,
+					Usage: &Usage{
+						PromptTokens: usage.PromptTokens,
+						CompletionTokens: usage.CompletionTokens,
+						CachedTokens: usage.CachedTokens,
+					},
+				}
+			case "message_stop":
+				stopped = true
+				return false
+			case "error":
+				streamErr = providerError(e.Error.Type + ": " + e.Error.Message)
+				return false
+			}
+			return true
+		})
+		if err == nil {
+			err = streamErr
+		}
+		if err == nil && !stopped {
+			// The stream ended before the message was complete
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			sendError(out, err)
+			return
+		}
+		out <- finish
+	}()
+	return out, cancel, nil
+}
@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// anthropicStream is a streamed Messages response with text, a tool call
+// split over several deltas and the stop reason and usage at the end.
+const anthropicStream string = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","model":"claude-test","role":"assistant","usage":{"input_tokens":10,"cache_read_input_tokens":5,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"check."}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"go\"}"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}
+
+`
+
+// newAnthropicServer returns a stand-in Anthropic API that answers Messages
+// requests with the stream and records the last request body and headers.
+func newAnthropicServer(t *testing.T, stream string, body *anthropicRequest, header *http.Header) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		*header = r.Header.Clone()
+		buf, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(buf, body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, stream)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestAnthropicStream(t *testing.T) {
+	var req anthropicRequest
+	var header http.Header
+	s := newAnthropicServer(t, anthropicStream+"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n", &req, &header)
+	def := &LanguageModel{
+		API: "anthropic",
+		APIEndpoint: s.URL + "/v1",
+		APIKey: "secret",
+		Model: "claude-test",
+	}
+	// A previous turn that called a tool, so the tool result and the new
+	// prompt are both user messages that have to be merged
+	chatContext := []*Turn{{
+		Prompt: &Message{
+			Role: "user",
+			Content: "Look it up",
+		},
+		Response: []*Message{{
+			Role: "assistant",
+			ToolCalls: []*ToolCall{{
+				ID: "toolu_0",
+				Name: "lookup",
+				Arguments: `{"q":"old"}`,
+			}},
+		}, {
+			Role: "tool",
+			Name: "lookup",
+			ToolCallID: "toolu_0",
+			Content: "found",
+		}},
+	}}
+	system := &Message{
+		Role: "system",
+		Content: "Be brief.",
+	}
+	prompt := &Message{
+		Role: "user",
+		Content: "And this?",
+		Images: []*Image{testImage()},
+	}
+	events, cancel, err := ChatCompletion(def, system, prompt, chatContext, []*ToolDefinition{{Name: "lookup"}})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	defer cancel()
+	turn := &Turn{}
+	for e := range events {
+		turn.Apply(e)
+	}
+	if turn.Error != nil {
+		t.Fatalf("unexpected error: %v", turn.Error)
+	}
+	// Request
+	if header.Get("x-api-key") != "secret" || header.Get("anthropic-version") != anthropicVersion {
+		t.Errorf("unexpected headers %v", header)
+	}
+	if req.System != "Be brief." || req.Model != "claude-test" || !req.Stream || req.MaxTokens != anthropicMaxTokens {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if len(req.Tools) != 1 || string(req.Tools[0].InputSchema) != `{"type":"object"}` {
+		t.Errorf("unexpected tools %+v", req.Tools)
+	}
+	if len(req.Messages) != 3 {
+		t.Fatalf("sent %d messages, want 3: %+v", len(req.Messages), req.Messages)
+	}
+	for i, role := range []string{"user", "assistant", "user"} {
+		if req.Messages[i].Role != role {
+			t.Errorf("message %d role = %q, want %q", i, req.Messages[i].Role, role)
+		}
+	}
+	use := req.Messages[1].Content
+	if len(use) != 1 || use[0].Type != "tool_use" || use[0].ID != "toolu_0" || string(use[0].Input) != `{"q":"old"}` {
+		t.Errorf("unexpected tool use %+v", use)
+	}
+	merged := req.Messages[2].Content
+	if len(merged) != 3 {
+		t.Fatalf("merged user message has %d blocks, want 3: %+v", len(merged), merged)
+	}
+	if merged[0].Type != "tool_result" || merged[0].ToolUseID != "toolu_0" || merged[0].Content != "found" {
+		t.Errorf("unexpected tool result %+v", merged[0])
+	}
+	if merged[1].Type != "image" || merged[1].Source == nil || merged[1].Source.Type != "base64" || merged[1].Source.MediaType != "image/png" || merged[1].Source.Data == "" {
+		t.Errorf("unexpected image %+v", merged[1])
+	}
+	if merged[2].Type != "text" || merged[2].Text != "And this?" {
+		t.Errorf("unexpected text %+v", merged[2])
+	}
+	// Response
+	if got := responseText(turn); got != "Let me check." {
+		t.Errorf("response = %q", got)
+	}
+	if len(turn.ToolCalls) != 1 || turn.ToolCalls[0].ID != "toolu_1" || turn.ToolCalls[0].Name != "lookup" || turn.ToolCalls[0].Arguments != `{"q":"go"}` {
+		t.Errorf("unexpected tool calls %+v", turn.ToolCalls)
+	}
+	if turn.FinishReason != "tool_use" || turn.Model != "claude-test" || turn.RequestID != "msg_1" {
+		t.Errorf("finish = %q %q %q", turn.FinishReason, turn.Model, turn.RequestID)
+	}
+	if turn.Usage == nil || turn.Usage.PromptTokens != 15 || turn.Usage.CachedTokens != 5 || turn.Usage.CompletionTokens != 7 {
+		t.Errorf("usage = %+v", turn.Usage)
+	}
+}
+
+func TestAnthropicTruncatedStream(t *testing.T) {
+	var req anthropicRequest
+	var header http.Header
+	s := newAnthropicServer(t, anthropicStream, &req, &header)
+	def := &LanguageModel{
+		API: "anthropic",
+		APIEndpoint: s.URL,
+		APIKey: "secret",
+		Model: "claude-test",
+	}
+	turn := completeTurn(t, def, "Hi", nil, nil)
+	if turn.Error == nil || !errors.Is(turn.Error, io.ErrUnexpectedEOF) {
+		t.Fatalf("error = %v, want unexpected EOF", turn.Error)
+	}
+}
+
+func TestAnthropicErrorEvent(t *testing.T) {
+	var req anthropicRequest
+	var header http.Header
+	s := newAnthropicServer(t, `event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+`, &req, &header)
+	def := &LanguageModel{
+		API: "anthropic",
+		APIEndpoint: s.URL,
+		APIKey: "secret",
+		Model: "claude-test",
+	}
+	turn := completeTurn(t, def, "Hi", nil, nil)
+	if turn.Error == nil || turn.Error.Kind != ErrorKindProvider || turn.Error.Body != "overloaded_error: Overloaded" {
+		t.Fatalf("error = %+v", turn.Error)
+	}
+}
@@ -0,0 +1,172 @@
+package llm
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Pre-tokenization patterns of the OpenAI vocabularies. RE2 does not support
+// the look-ahead the original patterns use to leave the last space of a run
+// to the next word, so runs of spaces may count one token more.
+const (
+	cl100kPattern string = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`
+	o200kPattern string = `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+`
+)
+
+func init() {
+	RegisterTokenizer(NewBPETokenizer("cl100k_base", "OpenAI cl100k_base", cl100kPattern))
+	RegisterTokenizer(NewBPETokenizer("o200k_base", "OpenAI o200k_base", o200kPattern))
+}
+
+// TokenizerDir returns the directory BPE vocabularies are loaded from.
+func TokenizerDir() string {
+	if dir := os.Getenv("GEN_MAGIC_TOKENIZERS"); dir != "" {
+		return dir
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "tokenizers"
+	}
+	return filepath.Join(dir, "gen-magic", "tokenizers")
+}
+
+// BPETokenizer implements byte-level byte pair encoding with a vocabulary in
+// the tiktoken format, one base64-encoded token and its rank per line. The
+// vocabulary is loaded on first use from the file named after the tokenizer's
+// ID with the extension ".tiktoken" in TokenizerDir.
+type BPETokenizer struct {
+	id string
+	name string
+	pattern string
+	once sync.Once
+	ranks map[string]int
+	re *regexp.Regexp
+	err error
+}
+
+// NewBPETokenizer returns a new BPETokenizer using the pre-tokenization
+// pattern.
+func NewBPETokenizer(id, name, pattern string) *BPETokenizer {
+	return &BPETokenizer{
+		id: id,
+		name: name,
+		pattern: pattern,
+	}
+}
+
+// ID implements Tokenizer.
+func (t *BPETokenizer) ID() string { return t.id }
+
+// Name implements Tokenizer.
+func (t *BPETokenizer) Name() string { return t.name }
+
+// Path returns the path of the vocabulary file.
+func (t *BPETokenizer) Path() string {
+	return filepath.Join(TokenizerDir(), t.id+".tiktoken")
+}
+
+// Available implements Tokenizer.
+func (t *BPETokenizer) Available() bool {
+	if _, err := os.Stat(t.Path()); err != nil {
+		return false
+	}
+	t.load()
+	return t.err == nil
+}
+
+// load loads the vocabulary once.
+func (t *BPETokenizer) load() {
+	t.once.Do(func() {
+		if t.re, t.err = regexp.Compile(t.pattern); t.err != nil {
+			return
+		}
+		f, err := os.Open(t.Path())
+		if err != nil {
+			t.err = err
+			return
+		}
+		defer f.Close()
+		if t.ranks, t.err = LoadTiktoken(f); t.err != nil {
+			log.Printf("error loading tokenizer %s: %v\n", t.id, t.err)
+		}
+	})
+}
+
+// LoadTiktoken reads a vocabulary in the tiktoken format and returns the rank
+// of each token.
+func LoadTiktoken(r io.Reader) (map[string]int, error) {
+	ret := map[string]int{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		token, rank, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("invalid tiktoken line \"%s\"", line)
+		}
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, err
+		}
+		ret[string(b)] = n
+	}
+	return ret, s.Err()
+}
+
+// Count implements Tokenizer. The heuristic estimate is returned if the
+// vocabulary could not be loaded.
+func (t *BPETokenizer) Count(text string) int {
+	t.load()
+	if t.err != nil {
+		return heuristicTokenizer{}.Count(text)
+	}
+	ret := 0
+	for _, piece := range t.re.FindAllString(text, -1) {
+		if _, ok := t.ranks[piece]; ok {
+			ret++
+			continue
+		}
+		ret += t.mergeCount(piece)
+	}
+	return ret
+}
+
+// mergeCount returns the number of tokens the piece is encoded as by merging
+// the adjacent pair of parts with the lowest rank until no pair is in the
+// vocabulary.
+func (t *BPETokenizer) mergeCount(piece string) int {
+	// bounds holds the start of each part and the end of the piece
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, 0
+		for i := 0; i+2 < len(bounds); i++ {
+			rank, ok := t.ranks[piece[bounds[i]:bounds[i+2]]]
+			if ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
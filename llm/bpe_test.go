@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testVocab is a small vocabulary by rank, in which "hello" is one token and
+// " world" merges only its first two bytes.
+var testVocab = []string{"h", "e", "l", "o", " ", "w", "r", "d", "he", "ll", "hell", "hello", " w"}
+
+// writeVocab writes the vocabulary in the tiktoken format to a temporary
+// tokenizer directory as the vocabulary of the tokenizer ID.
+func writeVocab(t *testing.T, id string, vocab []string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("GEN_MAGIC_TOKENIZERS", dir)
+	var b strings.Builder
+	for rank, token := range vocab {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id+".tiktoken"), []byte(b.String()), 0644); err != nil {
+		t.Fatalf("writing vocabulary: %v", err)
+	}
+}
+
+func TestBPECount(t *testing.T) {
+	writeVocab(t, "test_vocab", testVocab)
+	tok := NewBPETokenizer("test_vocab", "Test", cl100kPattern)
+	if tok.Path() != filepath.Join(os.Getenv("GEN_MAGIC_TOKENIZERS"), "test_vocab.tiktoken") {
+		t.Errorf("path = %s", tok.Path())
+	}
+	if !tok.Available() {
+		t.Fatalf("tokenizer is not available")
+	}
+	for _, tc := range []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 1},
+		{"hell", 1},
+		// " w" then the remaining four bytes
+		{" world", 5},
+		{"hello world", 6},
+		// "he" is merged before "ll", then "hell", but not "ohell"
+		{"ohell", 2},
+		{"lll", 2},
+		// The space of " hello" is not merged with the word
+		{"hello hello", 3},
+	} {
+		if got := tok.Count(tc.text); got != tc.want {
+			t.Errorf("Count(%q) = %d, want %d", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestBPEUnavailable(t *testing.T) {
+	t.Setenv("GEN_MAGIC_TOKENIZERS", t.TempDir())
+	tok := NewBPETokenizer("missing_vocab", "Missing", cl100kPattern)
+	if tok.Available() {
+		t.Fatalf("tokenizer without a vocabulary is available")
+	}
+	// Counts fall back to the heuristic estimate
+	text := "The quick brown fox jumps over the lazy dog."
+	if got, want := tok.Count(text), (heuristicTokenizer{}).Count(text); got != want {
+		t.Errorf("Count = %d, want the heuristic %d", got, want)
+	}
+}
+
+func TestLoadTiktoken(t *testing.T) {
+	ranks, err := LoadTiktoken(strings.NewReader("aGVsbG8= 7\n\nIHc= 8\n"))
+	if err != nil || len(ranks) != 2 || ranks["hello"] != 7 || ranks[" w"] != 8 {
+		t.Errorf("LoadTiktoken = %v, %v", ranks, err)
+	}
+	for _, bad := range []string{"aGVsbG8=", "!!! 1", "aGVsbG8= x"} {
+		if _, err := LoadTiktoken(strings.NewReader(bad)); err == nil {
+			t.Errorf("loaded invalid vocabulary %q", bad)
+		}
+	}
+}
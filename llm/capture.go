@@ -0,0 +1,318 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCaptureBody is the number of bytes of each request and response body
+// kept in captured exchanges.
+const maxCaptureBody int = 8 * 1024 * 1024
+
+// redacted replaces the values of captured headers and query parameters that
+// may hold credentials.
+const redacted string = "[REDACTED]"
+
+// Exchange is a captured HTTP request to an API and its response. Headers and
+// query parameters that may hold credentials are redacted.
+type Exchange struct {
+	Method string
+	URL string
+	RequestHeaders http.Header
+	RequestBody string
+	// Status is the HTTP status code of the response, zero if there was no
+	// response.
+	Status int
+	ResponseHeaders http.Header
+	// ResponseBody is the raw response body as read by the provider.
+	ResponseBody string
+	// Chunks are the events of streamed responses in the order received.
+	Chunks []*Chunk
+	// Truncated is true if the bodies were longer than could be kept.
+	Truncated bool
+	Timing Timing
+	// Error is the error the request failed with, if any.
+	Error string
+}
+
+// Chunk is an event of a streamed response, such as a server-sent event or a
+// line of newline-delimited JSON.
+type Chunk struct {
+	// At is the time from the start of the request to the end of the chunk.
+	At time.Duration
+	Data string
+}
+
+// Timing holds the timing breakdown of an exchange. Durations of phases that
+// did not happen, such as the DNS lookup of a reused connection, are zero.
+type Timing struct {
+	Start time.Time
+	DNS time.Duration // DNS lookup
+	Connect time.Duration // TCP connection
+	TLS time.Duration // TLS handshake
+	FirstByte time.Duration // From the start to the first response byte
+	Total time.Duration // From the start to the end of the response
+}
+
+// captureKey is the context key of the stream captured exchanges are sent on.
+type captureKey struct{}
+
+// withCapture returns a context with which the HTTP requests of a completion
+// are captured and sent on its response stream as EventExchange events.
+func withCapture(ctx context.Context, out chan *Event) context.Context {
+	return context.WithValue(ctx, captureKey{}, out)
+}
+
+// captureTransport is an http.RoundTripper that captures the requests made
+// with a context from withCapture.
+type captureTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out, ok := req.Context().Value(captureKey{}).(chan *Event)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	c := &capture{
+		out: out,
+		x: &Exchange{
+			Method: req.Method,
+			URL: redactURL(req.URL),
+			RequestHeaders: redactHeaders(req.Header),
+			Timing: Timing{
+				Start: time.Now(),
+			},
+		},
+	}
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			buf, _ := io.ReadAll(io.LimitReader(body, int64(maxCaptureBody)+1))
+			body.Close()
+			c.x.RequestBody, c.x.Truncated = truncate(buf)
+		}
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), c.trace()))
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		c.finish(err)
+		return nil, err
+	}
+	c.x.Status = res.StatusCode
+	c.x.ResponseHeaders = redactHeaders(res.Header)
+	contentType := res.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "event-stream"):
+		c.sep = "\n\n"
+	case strings.Contains(contentType, "ndjson"), strings.Contains(contentType, "jsonl"):
+		c.sep = "\n"
+	}
+	res.Body = &captureBody{
+		ReadCloser: res.Body,
+		c: c,
+	}
+	return res, nil
+}
+
+// truncate returns the captured body as a string and true if it is longer
+// than maxCaptureBody and was truncated.
+func truncate(buf []byte) (string, bool) {
+	if len(buf) > maxCaptureBody {
+		return string(buf[:maxCaptureBody]), true
+	}
+	return string(buf), false
+}
+
+// isSecret returns true if the name of a header or query parameter suggests
+// that its value is a credential.
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"auth", "key", "token", "secret", "cookie", "password"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeaders returns a copy of the headers with credentials redacted.
+func redactHeaders(h http.Header) http.Header {
+	ret := h.Clone()
+	for name := range ret {
+		if isSecret(name) {
+			ret[name] = []string{redacted}
+		}
+	}
+	return ret
+}
+
+// redactURL returns the URL with credentials in the query and user info
+// redacted.
+func redactURL(u *url.URL) string {
+	r := *u
+	if r.User != nil {
+		r.User = url.User(redacted)
+	}
+	q := r.Query()
+	for name := range q {
+		if isSecret(name) {
+			q.Set(name, redacted)
+		}
+	}
+	if len(q) > 0 {
+		r.RawQuery = q.Encode()
+	}
+	return r.String()
+}
+
+// capture records an exchange as it happens.
+type capture struct {
+	lock sync.Mutex
+	once sync.Once
+	out chan *Event
+	x *Exchange
+	body bytes.Buffer
+	// sep separates the chunks of streamed responses, empty if the response
+	// is not streamed.
+	sep string
+	pending string
+	dnsStart time.Time
+	connectStart time.Time
+	tlsStart time.Time
+}
+
+// trace returns the client trace that records the timing of the exchange.
+// Trace hooks may be called from other goroutines.
+func (c *capture) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			c.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			c.x.Timing.DNS = time.Since(c.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			c.connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			c.x.Timing.Connect = time.Since(c.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			c.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			c.x.Timing.TLS = time.Since(c.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			c.x.Timing.FirstByte = time.Since(c.x.Timing.Start)
+		},
+	}
+}
+
+// read records data read from the response body.
+func (c *capture) read(data []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.x.Truncated {
+		return
+	}
+	if c.body.Len()+len(data) > maxCaptureBody {
+		c.x.Truncated = true
+		return
+	}
+	c.body.Write(data)
+	if c.sep == "" {
+		return
+	}
+	c.pending += strings.ReplaceAll(string(data), "\r", "")
+	for {
+		chunk, rest, found := strings.Cut(c.pending, c.sep)
+		if !found {
+			break
+		}
+		c.pending = rest
+		c.addChunk(chunk)
+	}
+}
+
+// addChunk adds a chunk of the streamed response unless it is blank.
+func (c *capture) addChunk(data string) {
+	if strings.TrimSpace(data) == "" {
+		return
+	}
+	c.x.Chunks = append(c.x.Chunks, &Chunk{
+		At: time.Since(c.x.Timing.Start),
+		Data: data,
+	})
+}
+
+// finish completes the exchange once and sends it on the response stream.
+func (c *capture) finish(err error) {
+	c.once.Do(func() {
+		c.lock.Lock()
+		c.x.Timing.Total = time.Since(c.x.Timing.Start)
+		c.x.ResponseBody = c.body.String()
+		if c.sep != "" {
+			c.addChunk(c.pending)
+			c.pending = ""
+		}
+		if err != nil {
+			c.x.Error = err.Error()
+		}
+		x := *c.x
+		c.lock.Unlock()
+		c.out <- &Event{
+			Type: EventExchange,
+			Exchange: &x,
+		}
+	})
+}
+
+// captureBody captures a response body as it is read and finishes the
+// exchange at its end.
+type captureBody struct {
+	io.ReadCloser
+	c *capture
+}
+
+// Read implements io.Reader.
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.c.read(p[:n])
+	if err == io.EOF {
+		b.c.finish(nil)
+	} else if err != nil {
+		b.c.finish(err)
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (b *captureBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.c.finish(nil)
+	return err
+}
@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultResponseTokens is the number of tokens kept free for the response
+// when fitting the context of models without a max tokens parameter.
+const DefaultResponseTokens int = 1024
+
+// summaryTokens is the number of tokens kept free for the summary of older
+// turns by the summarize-older strategy.
+const summaryTokens int = 512
+
+// knownContextLengths maps model name prefixes to the context lengths of the
+// models, in the order they are matched.
+var knownContextLengths = []struct {
+	prefix string
+	length int
+}{
+	{"gpt-3.5-turbo", 16385},
+	{"gpt-4o", 128000},
+	{"gpt-4.1", 1047576},
+	{"gpt-4.5", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-5", 400000},
+	{"chatgpt-", 128000},
+	{"o1-mini", 128000},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"claude", 200000},
+	{"gemini-1.5-pro", 2097152},
+	{"gemini", 1048576},
+	{"llama3.1", 131072},
+	{"llama3.2", 131072},
+	{"llama3.3", 131072},
+	{"llama-3.1", 131072},
+	{"llama-3.2", 131072},
+	{"llama-3.3", 131072},
+	{"llama3", 8192},
+	{"llama-3", 8192},
+	{"llama2", 4096},
+	{"mistral-large", 131072},
+	{"mistral", 32768},
+	{"mixtral", 32768},
+	{"qwen3", 40960},
+	{"qwen2.5", 32768},
+	{"gemma3", 131072},
+	{"gemma2", 8192},
+	{"phi3", 4096},
+	{"deepseek", 65536},
+}
+
+// KnownContextLength returns the context length of the model in tokens, or
+// zero if it is not known.
+func KnownContextLength(model string) int {
+	model = baseModelName(model)
+	for _, k := range knownContextLengths {
+		if strings.HasPrefix(model, k.prefix) {
+			return k.length
+		}
+	}
+	return 0
+}
+
+// ContextWindow returns the context length of the LLM definition in tokens,
+// which is ContextLength if set and the known context length of the model
+// otherwise. Zero means unknown.
+func (def *LanguageModel) ContextWindow() int {
+	if def.ContextLength > 0 {
+		return def.ContextLength
+	}
+	return KnownContextLength(def.Model)
+}
+
+// ResponseTokens returns the number of tokens to keep free for the response.
+func (def *LanguageModel) ResponseTokens() int {
+	if def.Parameters.MaxTokens != nil && *def.Parameters.MaxTokens > 0 {
+		return *def.Parameters.MaxTokens
+	}
+	return DefaultResponseTokens
+}
+
+// Truncation strategies applied when the context does not fit the context
+// window.
+const (
+	TruncateNone string = "none" // Send the context as-is
+	TruncateDropOldest string = "drop-oldest" // Drop the oldest turns
+	TruncateKeepFirstAndLast string = "keep-first-and-last" // Keep the first turn and drop the ones after it
+	TruncateSummarizeOlder string = "summarize-older" // Replace the oldest turns with a summary
+)
+
+// TruncationStrategies lists all truncation strategies.
+var TruncationStrategies = []string{
+	TruncateNone,
+	TruncateDropOldest,
+	TruncateKeepFirstAndLast,
+	TruncateSummarizeOlder,
+}
+
+// ContextFit is the result of fitting a chat context to a context window.
+type ContextFit struct {
+	// Turns are the turns to send as context.
+	Turns []*Turn
+	// Dropped are the turns that were dropped, oldest first. The
+	// summarize-older strategy expects these to be replaced with a summary.
+	Dropped []*Turn
+	// Tokens is the estimated number of tokens of the system prompt, prompt
+	// and context turns.
+	Tokens int
+	// Window is the context window the context was fit to, zero if unknown.
+	Window int
+}
+
+// FitContext fits the chat context of a completion to the context window of
+// the LLM definition, keeping room for the response, with the given
+// truncation strategy. Contexts of models with unknown context windows are not
+// truncated.
+func FitContext(def *LanguageModel, system, prompt *Message, chatContext []*Turn, strategy string) *ContextFit {
+	t := TokenizerFor(def)
+	ret := &ContextFit{
+		Turns: chatContext,
+		Window: def.ContextWindow(),
+	}
+	fixed := CountMessage(t, system) + CountMessage(t, prompt)
+	counts := make([]int, len(chatContext))
+	total := fixed
+	for i, turn := range chatContext {
+		counts[i] = CountTurn(t, turn)
+		total += counts[i]
+	}
+	ret.Tokens = total
+	if ret.Window <= 0 || strategy == TruncateNone || strategy == "" {
+		return ret
+	}
+	budget := ret.Window - def.ResponseTokens()
+	if strategy == TruncateSummarizeOlder {
+		budget -= summaryTokens
+	}
+	if total <= budget {
+		return ret
+	}
+	// Drop turns starting with the oldest, or with the second for
+	// keep-first-and-last, until the rest fits
+	first := 0
+	if strategy == TruncateKeepFirstAndLast && len(chatContext) > 1 {
+		first = 1
+	}
+	last := first
+	for last < len(chatContext) && total > budget {
+		total -= counts[last]
+		last++
+	}
+	if total > budget && first > 0 {
+		// Even the first turn does not fit with the last
+		total -= counts[0]
+		first = 0
+	}
+	ret.Dropped = append(ret.Dropped, chatContext[first:last]...)
+	ret.Turns = append(append([]*Turn{}, chatContext[:first]...), chatContext[last:]...)
+	ret.Tokens = total
+	return ret
+}
+
+// summaryPrompt is the prompt asking for the summary of older turns.
+const summaryPrompt string = `Summarize the conversation above in at most 300 words for
+use as the context of its continuation. Keep facts, decisions, names, numbers
+and open questions. Respond with the summary only.`
+
+// Summarize asks the LLM for a summary of the turns of a chat with the system
+// prompt and returns a turn that can replace them in the context of later
+// turns. The oldest of the turns are left out of the summary if they do not
+// all fit the context window.
+func Summarize(def *LanguageModel, system *Message, turns []*Turn) (*Turn, error) {
+	summaryDef := *def
+	maxTokens := summaryTokens
+	summaryDef.Parameters.MaxTokens = &maxTokens
+	prompt := &Message{
+		Role: "user",
+		Content: summaryPrompt,
+	}
+	fit := FitContext(&summaryDef, system, prompt, turns, TruncateDropOldest)
+	if len(turns) > 0 && len(fit.Turns) == 0 {
+		return nil, errors.New("the turns to summarize do not fit the context window")
+	}
+	events, _, err := ChatCompletion(&summaryDef, system, prompt, fit.Turns, nil)
+	if err != nil {
+		return nil, err
+	}
+	turn := &Turn{
+		Definition: summaryDef,
+		Prompt: prompt,
+	}
+	for e := range events {
+		turn.Apply(e)
+	}
+	if turn.Error != nil {
+		return nil, turn.Error
+	}
+	summary := ""
+	for _, msg := range turn.Response {
+		summary += msg.Content
+	}
+	if strings.TrimSpace(summary) == "" {
+		return nil, errors.New("the LLM returned an empty summary")
+	}
+	return &Turn{
+		Definition: *def,
+		Prompt: &Message{
+			Role: "user",
+			Content: fmt.Sprintf("Summarize our conversation so far (%d earlier turns).", len(turns)),
+		},
+		Response: []*Message{{
+			Role: "assistant",
+			Content: summary,
+		}},
+	}, nil
+}
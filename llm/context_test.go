@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fitTurns returns n turns of 24 heuristic tokens each, whose prompts are
+// their index.
+func fitTurns(n int) []*Turn {
+	ret := []*Turn{}
+	for i := range n {
+		// One word of 32 characters is 8 tokens plus 4 for the message
+		text := strings.Repeat(string(rune('a'+i)), 32)
+		ret = append(ret, &Turn{
+			Prompt: &Message{
+				Role: "user",
+				Content: string(rune('0'+i)) + text[1:],
+			},
+			Response: []*Message{{
+				Role: "assistant",
+				Content: text,
+			}},
+		})
+	}
+	return ret
+}
+
+// turnIndexes returns the indexes of the turns made by fitTurns.
+func turnIndexes(turns []*Turn) []int {
+	ret := []int{}
+	for _, turn := range turns {
+		ret = append(ret, int(turn.Prompt.Content[0]-'0'))
+	}
+	return ret
+}
+
+func TestFitContext(t *testing.T) {
+	// The prompt is 12 tokens, the five turns 120 and 100 are kept free for
+	// the response, so 232 fits everything
+	prompt := &Message{
+		Role: "user",
+		Content: strings.Repeat("p", 32),
+	}
+	for _, tc := range []struct {
+		strategy string
+		window int
+		kept []int
+		dropped []int
+		tokens int
+	}{
+		{TruncateDropOldest, 232, []int{0, 1, 2, 3, 4}, []int{}, 132},
+		{TruncateDropOldest, 231, []int{1, 2, 3, 4}, []int{0}, 108},
+		{TruncateDropOldest, 208, []int{1, 2, 3, 4}, []int{0}, 108},
+		{TruncateDropOldest, 207, []int{2, 3, 4}, []int{0, 1}, 84},
+		{TruncateDropOldest, 112, []int{}, []int{0, 1, 2, 3, 4}, 12},
+		{TruncateDropOldest, 111, []int{}, []int{0, 1, 2, 3, 4}, 12},
+		{TruncateKeepFirstAndLast, 232, []int{0, 1, 2, 3, 4}, []int{}, 132},
+		{TruncateKeepFirstAndLast, 231, []int{0, 2, 3, 4}, []int{1}, 108},
+		{TruncateKeepFirstAndLast, 207, []int{0, 3, 4}, []int{1, 2}, 84},
+		{TruncateKeepFirstAndLast, 136, []int{0}, []int{1, 2, 3, 4}, 36},
+		{TruncateKeepFirstAndLast, 135, []int{}, []int{0, 1, 2, 3, 4}, 12},
+		{TruncateSummarizeOlder, 744, []int{0, 1, 2, 3, 4}, []int{}, 132},
+		{TruncateSummarizeOlder, 743, []int{1, 2, 3, 4}, []int{0}, 108},
+		{TruncateNone, 100, []int{0, 1, 2, 3, 4}, []int{}, 132},
+		{TruncateDropOldest, 0, []int{0, 1, 2, 3, 4}, []int{}, 132},
+	} {
+		maxTokens := 100
+		def := &LanguageModel{
+			Model: "test-model",
+			ContextLength: tc.window,
+			Parameters: Parameters{
+				MaxTokens: &maxTokens,
+			},
+		}
+		fit := FitContext(def, nil, prompt, fitTurns(5), tc.strategy)
+		kept, dropped := turnIndexes(fit.Turns), turnIndexes(fit.Dropped)
+		if !slices.Equal(kept, tc.kept) || !slices.Equal(dropped, tc.dropped) || fit.Tokens != tc.tokens || fit.Window != tc.window {
+			t.Errorf("%s in %d: kept %v dropped %v with %d tokens in %d, want %v %v %d", tc.strategy, tc.window, kept, dropped, fit.Tokens, fit.Window, tc.kept, tc.dropped, tc.tokens)
+		}
+	}
+}
+
+func TestFitContextFailedTurns(t *testing.T) {
+	// Failed turns are not sent and do not count
+	turns := fitTurns(3)
+	turns[1].Error = &Error{
+		Kind: ErrorKindProvider,
+	}
+	maxTokens := 100
+	def := &LanguageModel{
+		Model: "test-model",
+		ContextLength: 160,
+		Parameters: Parameters{
+			MaxTokens: &maxTokens,
+		},
+	}
+	fit := FitContext(def, nil, nil, turns, TruncateDropOldest)
+	if fit.Tokens != 48 || len(fit.Dropped) != 0 {
+		t.Errorf("fit %d tokens dropping %v", fit.Tokens, turnIndexes(fit.Dropped))
+	}
+}
+
+func TestSummarizeFitsContext(t *testing.T) {
+	var body struct {
+		Messages []struct {
+			Role string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(buf, &body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"id":"c1","model":"gpt","choices":[{"index":0,"delta":{"role":"assistant","content":"A summary"},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+`)
+	}))
+	defer s.Close()
+	def := &LanguageModel{
+		API: "openai-compatible",
+		APIEndpoint: s.URL + "/v1",
+		Model: "gpt",
+		ContextLength: 2048,
+	}
+	turns := []*Turn{}
+	for i := range 40 {
+		turns = append(turns, &Turn{
+			Prompt: &Message{
+				Role: "user",
+				Content: strings.Repeat("question ", 50) + string(rune('a'+i%26)),
+			},
+			Response: []*Message{{
+				Role: "assistant",
+				Content: strings.Repeat("answer ", 50),
+			}},
+		})
+	}
+	summary, err := Summarize(def, nil, turns)
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if summary.Response[0].Content != "A summary" {
+		t.Errorf("summary = %q", summary.Response[0].Content)
+	}
+	sent := len(body.Messages)
+	if sent < 3 || sent >= len(turns)*2+1 {
+		t.Fatalf("sent %d messages for %d turns", sent, len(turns))
+	}
+	// The newest turns are kept and the summary prompt is last
+	if body.Messages[sent-3].Content != turns[len(turns)-1].Prompt.Content {
+		t.Errorf("newest turn was not sent")
+	}
+	if body.Messages[sent-1].Content != summaryPrompt {
+		t.Errorf("last message = %q", body.Messages[sent-1].Content)
+	}
+}
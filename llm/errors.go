@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrorKind classifies completion errors.
+type ErrorKind int
+
+const (
+	ErrorKindRequest ErrorKind = iota // The request could not be sent or the response read
+	ErrorKindHTTPStatus // The API responded with a non-2XX HTTP status
+	ErrorKindProvider // The API reported an error in the response stream
+	ErrorKindTimeout // The request timed out
+	ErrorKindCanceled // The request was canceled
+	ErrorKindTool // Tool calling failed
+)
+
+// String implements fmt.Stringer.
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindHTTPStatus:
+		return "HTTP Error"
+	case ErrorKindProvider:
+		return "Provider Error"
+	case ErrorKindTimeout:
+		return "Timeout"
+	case ErrorKindCanceled:
+		return "Canceled"
+	case ErrorKindTool:
+		return "Tool Error"
+	default:
+		return "Request Error"
+	}
+}
+
+// Error is a completion error delivered on the response stream.
+type Error struct {
+	Kind ErrorKind
+	// StatusCode is the HTTP status code of ErrorKindHTTPStatus errors.
+	StatusCode int
+	// Body is the error body returned by the API, if any.
+	Body string
+	// Err is the underlying error, if any.
+	Err error
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	switch {
+	case e.Kind == ErrorKindHTTPStatus && e.Body != "":
+		return fmt.Sprintf("API returned HTTP status %d: %s", e.StatusCode, e.Body)
+	case e.Kind == ErrorKindHTTPStatus:
+		return fmt.Sprintf("API returned HTTP status %d", e.StatusCode)
+	case e.Kind == ErrorKindProvider && e.Body != "":
+		return "API reported an error: " + e.Body
+	case e.Kind == ErrorKindCanceled:
+		return "completion canceled"
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return e.Kind.String()
+	}
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError classifies err and wraps it in an *Error. Errors that are already
+// of type *Error are returned unchanged.
+func newError(err error) *Error {
+	var ret *Error
+	if errors.As(err, &ret) {
+		return ret
+	}
+	ret = &Error{
+		Kind: ErrorKindRequest,
+		Err: err,
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		ret.Kind = ErrorKindCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		ret.Kind = ErrorKindTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		ret.Kind = ErrorKindTimeout
+	}
+	return ret
+}
+
+// providerError returns an error reported by the API in the response stream.
+func providerError(body string) *Error {
+	return &Error{
+		Kind: ErrorKindProvider,
+		Body: body,
+	}
+}
@@ -0,0 +1,147 @@
+package llm
+
+import "time"
+
+// EventType identifies the kind of a stream Event.
+type EventType int
+
+const (
+	EventContent EventType = iota // Response text delta
+	EventReasoning // Reasoning text delta
+	EventToolCall // Tool call delta
+	EventUsage // Token usage of the completion so far
+	EventFinish // End of the response
+	EventError // Completion error, the stream ends after this event
+	EventToolResult // Result of a tool call executed by RunTools
+	EventExchange // Captured HTTP request and response of the completion
+)
+
+// ToolCall is a request from the model to call a tool.
+type ToolCall struct {
+	ID string
+	Name string
+	// Arguments is the JSON-encoded argument object.
+	Arguments string
+}
+
+// ToolCallDelta is a partial tool call. Deltas with the same index belong to
+// the same tool call and their Arguments are concatenated.
+type ToolCallDelta struct {
+	Index int
+	ID string
+	Name string
+	Arguments string
+}
+
+// Event is one event of a streamed completion response.
+type Event struct {
+	Type EventType
+	// Role is the role of the response message, set on the first content
+	// event if known.
+	Role string
+	// Text is the text delta of content and reasoning events.
+	Text string
+	// ToolCall is the tool call delta of tool call events.
+	ToolCall *ToolCallDelta
+	// Usage is the token usage of usage events.
+	Usage *Usage
+	// FinishReason is the reason the response ended as reported by the API.
+	FinishReason string
+	// Model is the model that actually produced the response, if known.
+	Model string
+	// RequestID is the API's ID of the request, if known.
+	RequestID string
+	// Error is the completion error of error events.
+	Error *Error
+	// ToolResult is the tool call result of tool result events.
+	ToolResult *ToolResult
+	// Exchange is the captured HTTP exchange of exchange events.
+	Exchange *Exchange
+}
+
+// sendError sends err on the response stream as an error event.
+func sendError(out chan *Event, err error) {
+	out <- &Event{
+		Type: EventError,
+		Error: newError(err),
+	}
+}
+
+// Apply records the stream event in the turn.
+func (t *Turn) Apply(e *Event) {
+	if !t.Started.IsZero() {
+		switch e.Type {
+		case EventContent, EventReasoning, EventToolCall:
+			if t.FirstTokenLatency == 0 {
+				t.FirstTokenLatency = time.Since(t.Started)
+			}
+		case EventFinish, EventError:
+			t.Latency = time.Since(t.Started)
+		}
+	}
+	switch e.Type {
+	case EventContent:
+		t.responseMessage(e.Role).Content += e.Text
+	case EventReasoning:
+		t.Reasoning += e.Text
+	case EventToolCall:
+		msg := t.responseMessage(e.Role)
+		d := e.ToolCall
+		for len(msg.ToolCalls) <= d.Index {
+			tc := &ToolCall{}
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+			t.ToolCalls = append(t.ToolCalls, tc)
+		}
+		tc := msg.ToolCalls[d.Index]
+		if d.ID != "" {
+			tc.ID = d.ID
+		}
+		if d.Name != "" {
+			tc.Name = d.Name
+		}
+		tc.Arguments += d.Arguments
+	case EventUsage:
+		// Estimate the cost from the prices of the LLM if not reported
+		u := *e.Usage
+		if u.Cost == 0 {
+			u.Cost = t.Definition.EstimateCost(&u)
+		}
+		t.Usage = &u
+	case EventFinish:
+		t.FinishReason = e.FinishReason
+		if e.Model != "" {
+			t.Model = e.Model
+		}
+		if e.RequestID != "" {
+			t.RequestID = e.RequestID
+		}
+	case EventError:
+		t.Error = e.Error
+	case EventExchange:
+		t.Exchanges = append(t.Exchanges, e.Exchange)
+	case EventToolResult:
+		r := e.ToolResult
+		t.Response = append(t.Response, &Message{
+			Role: "tool",
+			Content: r.Content,
+			ToolCallID: r.Call.ID,
+			Name: r.Call.Name,
+		})
+	}
+}
+
+// responseMessage returns the response message that content and tool call
+// deltas are added to, starting a new message after tool results.
+func (t *Turn) responseMessage(role string) *Message {
+	if n := len(t.Response); n > 0 && t.Response[n-1].Role != "tool" {
+		return t.Response[n-1]
+	}
+	if role == "" {
+		role = "assistant"
+	}
+	msg := &Message{
+		Role: role,
+	}
+	t.Response = append(t.Response, msg)
+	return msg
+}
@@ -0,0 +1,377 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// geminiDefaultEndpoint is the endpoint used when the LLM definition does not
+// name one.
+const geminiDefaultEndpoint string = "https://generativelanguage.googleapis.com"
+
+func init() {
+	RegisterProvider(&geminiProvider{})
+}
+
+// geminiProvider implements the Google Gemini generateContent API.
+type geminiProvider struct{}
+
+// geminiInlineData is the inline data of a content part.
+type geminiInlineData struct {
+	MimeType string `json:"mime_type"`
+	Data string `json:"data"`
+}
+
+// geminiFunctionCall is a function call requested by the model.
+type geminiFunctionCall struct {
+	Name string `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// geminiFunctionResponse is the result of a function call.
+type geminiFunctionResponse struct {
+	Name string `json:"name"`
+	Response struct {
+		Content string `json:"content"`
+	} `json:"response"`
+}
+
+// geminiPart is one part of a content.
+type geminiPart struct {
+	Text string `json:"text,omitempty"`
+	Thought bool `json:"thought,omitempty"`
+	InlineData *geminiInlineData `json:"inline_data,omitempty"`
+	FunctionCall *geminiFunctionCall `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// geminiFunctionDeclaration declares a function the model may call.
+type geminiFunctionDeclaration struct {
+	Name string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// geminiTool is a set of functions offered to the model.
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// geminiContent is one content of a generateContent request.
+type geminiContent struct {
+	Role string `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiGenerationConfig is the generation config of a generateContent
+// request.
+type geminiGenerationConfig struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP *float64 `json:"topP,omitempty"`
+	TopK *int `json:"topK,omitempty"`
+	MaxOutputTokens *int `json:"maxOutputTokens,omitempty"`
+	FrequencyPenalty *float64 `json:"frequencyPenalty,omitempty"`
+	PresencePenalty *float64 `json:"presencePenalty,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+	Seed *int `json:"seed,omitempty"`
+}
+
+// geminiRequest is the body of a generateContent request.
+type geminiRequest struct {
+	SystemInstruction *geminiContent `json:"systemInstruction,omitempty"`
+	Contents []geminiContent `json:"contents"`
+	Tools []geminiTool `json:"tools,omitempty"`
+	GenerationConfig *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// geminiChunk is one chunk of a streamed generateContent response.
+type geminiChunk struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount int `json:"thoughtsTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+	ResponseID string `json:"responseId"`
+	Error *struct {
+		Status string `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ID implements Provider.
+func (p *geminiProvider) ID() string { return "gemini" }
+
+// Name implements Provider.
+func (p *geminiProvider) Name() string { return "Google Gemini" }
+
+// Capabilities implements Provider.
+func (p *geminiProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Streaming: true,
+		Images: true,
+		ListModels: true,
+		RequiresAPIKey: true,
+		Tools: true,
+	}
+}
+
+// ValidateConfig implements Provider.
+func (p *geminiProvider) ValidateConfig(def *LanguageModel) error {
+	if def.APIKey == "" {
+		return errors.New("an API key is required in LLM configuration for Gemini")
+	}
+	if def.Model == "" {
+		return errors.New("a model is required in LLM configuration for Gemini")
+	}
+	return nil
+}
+
+// url returns the URL of the API method at path, which does not include the
+// version prefix.
+func (p *geminiProvider) url(def *LanguageModel, path string) string {
+	base := def.APIEndpoint
+	if base == "" {
+		base = geminiDefaultEndpoint
+	}
+	base = strings.TrimSuffix(strings.TrimRight(base, "/"), "/v1beta")
+	return endpointURL(base, "/v1beta"+path)
+}
+
+// headers returns the HTTP headers to send with every request.
+func (p *geminiProvider) headers(def *LanguageModel) map[string]string {
+	ret := map[string]string{
+		"x-goog-api-key": def.APIKey,
+	}
+	for k, v := range def.Headers {
+		ret[k] = v
+	}
+	return ret
+}
+
+// ListModels implements Provider.
+func (p *geminiProvider) ListModels(def *LanguageModel) ([]string, error) {
+	var res struct {
+		Models []struct {
+			Name string `json:"name"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	err := getJSON(context.Background(), p.url(def, "/models?pageSize=1000"), p.headers(def), &res)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, model := range res.Models {
+		for _, method := range model.SupportedGenerationMethods {
+			if method == "generateContent" {
+				ret = append(ret, strings.TrimPrefix(model.Name, "models/"))
+				break
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Request implements RequestBuilder.
+func (p *geminiProvider) Request(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (*Request, error) {
+	req := geminiRequest{
+		Contents: []geminiContent{},
+	}
+	if params := def.Parameters; !params.IsZero() {
+		req.GenerationConfig = &geminiGenerationConfig{
+			Temperature: params.Temperature,
+			TopP: params.TopP,
+			TopK: params.TopK,
+			MaxOutputTokens: params.MaxTokens,
+			FrequencyPenalty: params.FrequencyPenalty,
+			PresencePenalty: params.PresencePenalty,
+			StopSequences: params.Stop,
+			Seed: params.Seed,
+		}
+	}
+	if system != nil && system.Content != "" {
+		req.SystemInstruction = &geminiContent{
+			Parts: []geminiPart{{Text: system.Content}},
+		}
+	}
+	if len(tools) > 0 {
+		tool := geminiTool{}
+		for _, t := range tools {
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, geminiFunctionDeclaration{
+				Name: t.Name,
+				Description: t.Description,
+				Parameters: t.Parameters,
+			})
+		}
+		req.Tools = []geminiTool{tool}
+	}
+	for _, msg := range contextMessages(prompt, chatContext) {
+		if msg.Role == "tool" {
+			// Function responses are parts of a user content, consecutive
+			// responses are sent together
+			part := geminiPart{
+				FunctionResponse: &geminiFunctionResponse{
+					Name: msg.Name,
+				},
+			}
+			part.FunctionResponse.Response.Content = msg.Content
+			n := len(req.Contents)
+			if n > 0 && len(req.Contents[n-1].Parts) > 0 && req.Contents[n-1].Parts[0].FunctionResponse != nil {
+				req.Contents[n-1].Parts = append(req.Contents[n-1].Parts, part)
+			} else {
+				req.Contents = append(req.Contents, geminiContent{
+					Role: "user",
+					Parts: []geminiPart{part},
+				})
+			}
+			continue
+		}
+		content := geminiContent{
+			Role: "user",
+			Parts: []geminiPart{},
+		}
+		if msg.Role == "assistant" {
+			content.Role = "model"
+		}
+		for _, img := range msg.Images {
+			mimeType, data := img.encoded("image/png", "image/jpeg", "image/webp")
+			content.Parts = append(content.Parts, geminiPart{
+				InlineData: &geminiInlineData{
+					MimeType: mimeType,
+					Data: data,
+				},
+			})
+		}
+		if msg.Content != "" {
+			content.Parts = append(content.Parts, geminiPart{
+				Text: msg.Content,
+			})
+		}
+		for _, tc := range msg.ToolCalls {
+			content.Parts = append(content.Parts, geminiPart{
+				FunctionCall: &geminiFunctionCall{
+					Name: tc.Name,
+					Args: json.RawMessage(tc.Arguments),
+				},
+			})
+		}
+		// Contents without parts are rejected
+		if len(content.Parts) == 0 {
+			continue
+		}
+		req.Contents = append(req.Contents, content)
+	}
+	return &Request{
+		Method: http.MethodPost,
+		URL: p.url(def, "/models/"+url.PathEscape(strings.TrimPrefix(def.Model, "models/"))+":streamGenerateContent?alt=sse"),
+		Headers: p.headers(def),
+		Body: req,
+	}, nil
+}
+
+// StreamCompletion implements Provider.
+func (p *geminiProvider) StreamCompletion(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (chan *Event, func(), error) {
+	req, err := p.Request(def, system, prompt, chatContext, tools)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan *Event, 1024)
+	ctx = withCapture(ctx, out)
+	go func() {
+		defer close(out)
+		res, err := doJSON(ctx, req.Method, req.URL, req.Headers, req.Body)
+		if err != nil {
+			sendError(out, err)
+			return
+		}
+		defer res.Body.Close()
+		finish := &Event{
+			Type: EventFinish,
+		}
+		toolCalls := 0
+		var streamErr error
+		err = readSSE(res.Body, func(event, data string) bool {
+			var chunk geminiChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				streamErr = err
+				return false
+			}
+			if chunk.Error != nil {
+				streamErr = providerError(chunk.Error.Status + ": " + chunk.Error.Message)
+				return false
+			}
+			if chunk.ModelVersion != "" {
+				finish.Model = chunk.ModelVersion
+			}
+			if chunk.ResponseID != "" {
+				finish.RequestID = chunk.ResponseID
+			}
+			// Only the first candidate is used
+			if len(chunk.Candidates) > 0 {
+				for _, part := range chunk.Candidates[0].Content.Parts {
+					switch {
+					case part.FunctionCall != nil:
+						// Gemini sends complete function calls
+						out <- &Event{
+							Type: EventToolCall,
+							ToolCall: &ToolCallDelta{
+								Index: toolCalls,
+								ID: fmt.Sprintf("call_%d", toolCalls),
+								Name: part.FunctionCall.Name,
+								Arguments: string(part.FunctionCall.Args),
+							},
+						}
+						toolCalls++
+					case part.Thought:
+						out <- &Event{
+							Type: EventReasoning,
+							Text: part.Text,
+						}
+					case part.Text != "":
+						out <- &Event{
+							Type: EventContent,
+							Text: part.Text,
+						}
+					}
+				}
+				if chunk.Candidates[0].FinishReason != "" {
+					finish.FinishReason = chunk.Candidates[0].FinishReason
+				}
+			}
+			if chunk.UsageMetadata != nil {
+				out <- &Event{
+					Type: EventUsage,
+					Usage: &Usage{
+						PromptTokens: chunk.UsageMetadata.PromptTokenCount,
+						// Thoughts are not included in the candidates
+						CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount + chunk.UsageMetadata.ThoughtsTokenCount,
+						ReasoningTokens: chunk.UsageMetadata.ThoughtsTokenCount,
+						CachedTokens: chunk.UsageMetadata.CachedContentTokenCount,
+					},
+				}
+			}
+			return true
+		})
+		if err == nil {
+			err = streamErr
+		}
+		if err != nil {
+			sendError(out, err)
+			return
+		}
+		out <- finish
+	}()
+	return out, cancel, nil
+}
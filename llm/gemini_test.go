@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// geminiStream is a streamed generateContent response with a thought, text
+// split over chunks, a function call and the usage in the last chunk.
+const geminiStream string = `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Thinking","thought":true}]}}],"modelVersion":"gemini-test-001","responseId":"resp-1"}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"text":"It is "}]}}]}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"text":"sunny."},{"functionCall":{"name":"lookup","args":{"q":"rain"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":6,"thoughtsTokenCount":4,"cachedContentTokenCount":8}}
+
+`
+
+func TestGeminiStream(t *testing.T) {
+	var req geminiRequest
+	var header http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-test:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("unexpected URL %s", r.URL)
+		}
+		header = r.Header.Clone()
+		buf, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(buf, &req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, geminiStream)
+	}))
+	defer s.Close()
+	def := &LanguageModel{
+		API: "gemini",
+		APIEndpoint: s.URL + "/v1beta",
+		APIKey: "secret",
+		Model: "models/gemini-test",
+	}
+	// A previous turn with two function calls whose responses are sent
+	// together, and an empty message that must not be sent
+	chatContext := []*Turn{{
+		Prompt: &Message{
+			Role: "user",
+			Content: "Weather?",
+		},
+		Response: []*Message{{
+			Role: "assistant",
+			ToolCalls: []*ToolCall{{
+				ID: "call_0",
+				Name: "lookup",
+				Arguments: `{"q":"sun"}`,
+			}, {
+				ID: "call_1",
+				Name: "lookup",
+				Arguments: `{"q":"wind"}`,
+			}},
+		}, {
+			Role: "assistant",
+		}, {
+			Role: "tool",
+			Name: "lookup",
+			ToolCallID: "call_0",
+			Content: "sunny",
+		}, {
+			Role: "tool",
+			Name: "lookup",
+			ToolCallID: "call_1",
+			Content: "calm",
+		}},
+	}}
+	system := &Message{
+		Role: "system",
+		Content: "Be brief.",
+	}
+	prompt := &Message{
+		Role: "user",
+		Content: "And here?",
+		Images: []*Image{testImage()},
+	}
+	events, cancel, err := ChatCompletion(def, system, prompt, chatContext, []*ToolDefinition{{Name: "lookup"}})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	defer cancel()
+	turn := &Turn{}
+	for e := range events {
+		turn.Apply(e)
+	}
+	if turn.Error != nil {
+		t.Fatalf("unexpected error: %v", turn.Error)
+	}
+	// Request
+	if header.Get("x-goog-api-key") != "secret" {
+		t.Errorf("unexpected headers %v", header)
+	}
+	if req.SystemInstruction == nil || len(req.SystemInstruction.Parts) != 1 || req.SystemInstruction.Parts[0].Text != "Be brief." || req.SystemInstruction.Role != "" {
+		t.Errorf("unexpected system instruction %+v", req.SystemInstruction)
+	}
+	if len(req.Tools) != 1 || len(req.Tools[0].FunctionDeclarations) != 1 || req.Tools[0].FunctionDeclarations[0].Name != "lookup" {
+		t.Errorf("unexpected tools %+v", req.Tools)
+	}
+	if len(req.Contents) != 4 {
+		t.Fatalf("sent %d contents, want 4: %+v", len(req.Contents), req.Contents)
+	}
+	for i, role := range []string{"user", "model", "user", "user"} {
+		if req.Contents[i].Role != role {
+			t.Errorf("content %d role = %q, want %q", i, req.Contents[i].Role, role)
+		}
+		if len(req.Contents[i].Parts) == 0 {
+			t.Errorf("content %d has no parts", i)
+		}
+	}
+	calls := req.Contents[1].Parts
+	if len(calls) != 2 || calls[0].FunctionCall == nil || calls[0].FunctionCall.Name != "lookup" || string(calls[1].FunctionCall.Args) != `{"q":"wind"}` {
+		t.Errorf("unexpected function calls %+v", calls)
+	}
+	responses := req.Contents[2].Parts
+	if len(responses) != 2 || responses[0].FunctionResponse == nil || responses[0].FunctionResponse.Response.Content != "sunny" || responses[1].FunctionResponse == nil || responses[1].FunctionResponse.Response.Content != "calm" {
+		t.Errorf("unexpected function responses %+v", responses)
+	}
+	parts := req.Contents[3].Parts
+	if len(parts) != 2 || parts[0].InlineData == nil || parts[0].InlineData.MimeType != "image/png" || parts[0].InlineData.Data == "" || parts[1].Text != "And here?" {
+		t.Errorf("unexpected prompt parts %+v", parts)
+	}
+	// Response
+	if got := responseText(turn); got != "It is sunny." {
+		t.Errorf("response = %q", got)
+	}
+	if turn.Reasoning != "Thinking" {
+		t.Errorf("reasoning = %q", turn.Reasoning)
+	}
+	if len(turn.ToolCalls) != 1 || turn.ToolCalls[0].Name != "lookup" || turn.ToolCalls[0].Arguments != `{"q":"rain"}` {
+		t.Errorf("unexpected tool calls %+v", turn.ToolCalls)
+	}
+	if turn.FinishReason != "STOP" || turn.Model != "gemini-test-001" || turn.RequestID != "resp-1" {
+		t.Errorf("finish = %q %q %q", turn.FinishReason, turn.Model, turn.RequestID)
+	}
+	if turn.Usage == nil || turn.Usage.PromptTokens != 20 || turn.Usage.CompletionTokens != 10 || turn.Usage.ReasoningTokens != 4 || turn.Usage.CachedTokens != 8 {
+		t.Errorf("usage = %+v", turn.Usage)
+	}
+}
+
+func TestGeminiEmptyPrompt(t *testing.T) {
+	// Continuing after function responses without a new prompt must not send
+	// a content without parts
+	r, err := BuildRequest(&LanguageModel{
+		API: "gemini",
+		APIKey: "secret",
+		Model: "gemini-test",
+	}, nil, &Message{
+		Role: "user",
+	}, []*Turn{{
+		Prompt: &Message{
+			Role: "user",
+			Content: "Look it up",
+		},
+		Response: []*Message{{
+			Role: "assistant",
+			ToolCalls: []*ToolCall{{
+				ID: "call_0",
+				Name: "lookup",
+				Arguments: `{}`,
+			}},
+		}, {
+			Role: "tool",
+			Name: "lookup",
+			ToolCallID: "call_0",
+			Content: "found",
+		}},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("BuildRequest: %v", err)
+	}
+	req := r.Body.(geminiRequest)
+	if len(req.Contents) != 3 {
+		t.Fatalf("sent %d contents, want 3: %+v", len(req.Contents), req.Contents)
+	}
+	if last := req.Contents[2]; len(last.Parts) != 1 || last.Parts[0].FunctionResponse == nil {
+		t.Errorf("unexpected last content %+v", last)
+	}
+}
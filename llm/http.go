@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// responseTimeout is how long to wait for the API to start responding. This
+// is generous as local servers may need to load the model first.
+const responseTimeout time.Duration = 5 * time.Minute
+
+// httpClient is the HTTP client used by all providers. Requests of
+// completions are captured for inspection.
+var httpClient = &http.Client{
+	Transport: func() http.RoundTripper {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.ResponseHeaderTimeout = responseTimeout
+		return &captureTransport{
+			next: t,
+		}
+	}(),
+}
+
+// Request is an HTTP request to an API. The body is encoded as JSON.
+type Request struct {
+	Method string
+	URL string
+	Headers map[string]string
+	Body any
+}
+
+// endpointURL joins the base endpoint URL of an LLM definition with the path
+// of an API method. If the base URL already ends with the path it is returned
+// unchanged.
+func endpointURL(base, path string) string {
+	base = strings.TrimRight(base, "/")
+	if strings.HasSuffix(base, path) {
+		return base
+	}
+	return base + path
+}
+
+// doJSON sends an HTTP request with the given method, URL, headers and
+// JSON-encoded body and returns the response. A nil body sends no content.
+// Responses with a non-2XX status are closed and returned as *Error values
+// holding the status code and body.
+func doJSON(ctx context.Context, method, url string, headers map[string]string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+		return nil, &Error{
+			Kind: ErrorKindHTTPStatus,
+			StatusCode: res.StatusCode,
+			Body: strings.TrimSpace(string(msg)),
+			Err: fmt.Errorf("%s %s returned %s", method, url, res.Status),
+		}
+	}
+	return res, nil
+}
+
+// getJSON sends a GET request and decodes the JSON response into v.
+func getJSON(ctx context.Context, url string, headers map[string]string, v any) error {
+	res, err := doJSON(ctx, http.MethodGet, url, headers, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(v)
+}
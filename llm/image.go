@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"slices"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+)
+
+// ImageFormats are the MIME types of the image formats that may be attached to
+// messages.
+var ImageFormats = []string{"image/png", "image/jpeg", "image/webp"}
+
+// Image wraps an image.Image for the LLM. The encoded image data is kept as
+// given so it is sent to the API without re-encoding where possible.
+type Image struct {
+	img image.Image
+	b64 string
+	mimeType string
+}
+
+// NewImage returns a new image with the given contents.
+func NewImage(img image.Image) *Image {
+	ret := &Image{}
+	ret.SetImage(img)
+	return ret
+}
+
+// NewImageBase64 returns a new image with the given contents.
+func NewImageBase64(s string) *Image {
+	ret := &Image{}
+	ret.SetImageBase64(s)
+	return ret
+}
+
+// NewImageData returns a new image from the encoded data of a PNG, JPEG or
+// WebP image.
+func NewImageData(data []byte) (*Image, error) {
+	ret := &Image{}
+	if err := ret.SetImageData(data); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (l *Image) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.DataURL())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *Image) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("invalid string")
+	}
+	return l.SetImageBase64(s)
+}
+
+// SetImageBase64 sets the image data from a Base64-encoded string containing
+// the data of a PNG, JPEG or WebP image. The string may also be a data URL.
+func (l *Image) SetImageBase64(s string) error {
+	if strings.Contains(s, ",") {
+		parts := strings.Split(s, ",")
+		s = parts[len(parts)-1]
+	}
+	imgData, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return l.SetImageData(imgData)
+}
+
+// SetImageData sets the image from the encoded data of a PNG, JPEG or WebP
+// image.
+func (l *Image) SetImageData(data []byte) error {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	mimeType := "image/" + format
+	if !slices.Contains(ImageFormats, mimeType) {
+		return fmt.Errorf("unsupported image format %s", format)
+	}
+	l.b64 = base64.StdEncoding.EncodeToString(data)
+	l.mimeType = mimeType
+	l.img = img
+	return nil
+}
+
+// SetImage sets the image from a standard image.Image. The image is encoded
+// as a PNG.
+func (l *Image) SetImage(img image.Image) error {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return err
+	}
+	l.b64 = base64.StdEncoding.EncodeToString(buf.Bytes())
+	l.mimeType = "image/png"
+	l.img = img
+	return nil
+}
+
+// Image returns the decoded image.
+func (l *Image) Image() image.Image {
+	return l.img
+}
+
+// MimeType returns the MIME type of the encoded image data.
+func (l *Image) MimeType() string {
+	return l.mimeType
+}
+
+// Base64 returns the Base64-encoded image data.
+func (l *Image) Base64() string {
+	return l.b64
+}
+
+// DataURL returns the image data as a data URL.
+func (l *Image) DataURL() string {
+	return "data:" + l.mimeType + ";base64," + l.b64
+}
+
+// encoded returns the MIME type and Base64-encoded data of the image in one
+// of the given formats, re-encoding the image as a PNG if the API does not
+// accept its format.
+func (l *Image) encoded(formats ...string) (string, string) {
+	if slices.Contains(formats, l.mimeType) {
+		return l.mimeType, l.b64
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, l.img); err != nil {
+		return l.mimeType, l.b64
+	}
+	return "image/png", base64.StdEncoding.EncodeToString(buf.Bytes())
+}
@@ -6,12 +6,80 @@ import (
 )
 
 // ChatCompletion executes a chat completion with the defined LLM and given
-// inputs.
-func ChatCompletion(def *LanguageModel, system, prompt *Message, context []*Turn) (chan *Message, func(), error) {
-	switch strings.ToLower(def.API) {
-	case "openrouter":
-		return openRouterChatCompletion(def, system, prompt, context)
-	default:
+// inputs and returns the stream of response events. The model may request
+// calls of the given tools, see RunTools to also execute them.
+func ChatCompletion(def *LanguageModel, system, prompt *Message, context []*Turn, tools []*ToolDefinition) (chan *Event, func(), error) {
+	p := GetProvider(strings.ToLower(def.API))
+	if p == nil {
 		return nil, nil, fmt.Errorf("unknown API \"%s\"", def.API)
 	}
+	if err := p.ValidateConfig(def); err != nil {
+		return nil, nil, err
+	}
+	if len(tools) > 0 && !p.Capabilities().Tools {
+		return nil, nil, fmt.Errorf("API \"%s\" does not support tool calling", def.API)
+	}
+	return p.StreamCompletion(def, system, prompt, context, tools)
+}
+
+// BuildRequest returns the HTTP request ChatCompletion would send for the
+// inputs without sending it.
+func BuildRequest(def *LanguageModel, system, prompt *Message, context []*Turn, tools []*ToolDefinition) (*Request, error) {
+	p := GetProvider(strings.ToLower(def.API))
+	if p == nil {
+		return nil, fmt.Errorf("unknown API \"%s\"", def.API)
+	}
+	if err := p.ValidateConfig(def); err != nil {
+		return nil, err
+	}
+	if len(tools) > 0 && !p.Capabilities().Tools {
+		return nil, fmt.Errorf("API \"%s\" does not support tool calling", def.API)
+	}
+	b, ok := p.(RequestBuilder)
+	if !ok {
+		return nil, fmt.Errorf("API \"%s\" does not send HTTP requests", def.API)
+	}
+	return b.Request(def, system, prompt, context, tools)
+}
+
+// ListModels lists the models available to the defined LLM's API.
+func ListModels(def *LanguageModel) ([]string, error) {
+	p := GetProvider(strings.ToLower(def.API))
+	if p == nil {
+		return nil, fmt.Errorf("unknown API \"%s\"", def.API)
+	}
+	if !p.Capabilities().ListModels {
+		return nil, fmt.Errorf("API \"%s\" does not support listing models", def.API)
+	}
+	return p.ListModels(def)
+}
+
+// empty returns true if the message has nothing to send to the API. Tool
+// results are never empty.
+func (m *Message) empty() bool {
+	return m.Content == "" && len(m.Images) == 0 && len(m.ToolCalls) == 0 && m.Role != "tool"
+}
+
+// contextMessages returns the prompts and responses of the chat context in
+// order followed by the prompt. Failed turns and empty messages are skipped.
+func contextMessages(prompt *Message, context []*Turn) []*Message {
+	ret := []*Message{}
+	for _, turn := range context {
+		if turn.Failed() {
+			continue
+		}
+		if turn.Prompt != nil && !turn.Prompt.empty() {
+			ret = append(ret, turn.Prompt)
+		}
+		for _, msg := range turn.Response {
+			if msg.empty() {
+				continue
+			}
+			ret = append(ret, msg)
+		}
+	}
+	if prompt != nil {
+		ret = append(ret, prompt)
+	}
+	return ret
 }
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"image"
+	"testing"
+)
+
+// completeTurn runs a chat completion to the end and returns the turn the
+// events were applied to.
+func completeTurn(t *testing.T, def *LanguageModel, prompt string, chatContext []*Turn, tools []*ToolDefinition) *Turn {
+	t.Helper()
+	turn := &Turn{
+		Definition: *def,
+		Prompt: &Message{
+			Role: "user",
+			Content: prompt,
+		},
+	}
+	events, cancel, err := ChatCompletion(def, nil, turn.Prompt, chatContext, tools)
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	defer cancel()
+	for e := range events {
+		turn.Apply(e)
+	}
+	return turn
+}
+
+// responseText returns the concatenated content of the turn's responses.
+func responseText(turn *Turn) string {
+	ret := ""
+	for _, msg := range turn.Response {
+		ret += msg.Content
+	}
+	return ret
+}
+
+// testImage returns a small PNG image to attach to messages.
+func testImage() *Image {
+	return NewImage(image.NewRGBA(image.Rect(0, 0, 2, 2)))
+}
@@ -0,0 +1,261 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterProvider(&mockProvider{})
+}
+
+// mockProvider implements a deterministic offline API for development and
+// testing. The model selects the behavior: "echo" streams the prompt back and
+// "script" streams the scripted responses in turn. Options are given in the
+// API endpoint as a URL query string such as
+// "mock://?chunk=4&latency=50ms&script=Hello&script=Goodbye":
+//
+//	chunk       Number of characters per streamed chunk, default 8
+//	latency     Delay before each chunk such as "50ms", default none
+//	error       Fail the stream immediately with this message
+//	fail-after  Fail the stream after this many chunks
+//	reasoning   Reasoning text to stream before the response
+//	script      A scripted response, may be repeated
+//	tool-call   A tool call such as 'name:{"arg":1}' requested when tools
+//	            are offered, may be repeated
+//
+// The echo model describes the images attached to the prompt after the text
+// and echoes tool results when continuing after tool calls. The script model
+// responds to the Nth user message of the conversation with the Nth script
+// option, wrapping around. Request IDs are derived from the request content.
+type mockProvider struct{}
+
+// mockOptions holds the options parsed from the API endpoint.
+type mockOptions struct {
+	chunk int
+	latency time.Duration
+	err string
+	failAfter int
+	reasoning string
+	script []string
+	toolCalls []*ToolCall
+}
+
+// parseMockOptions parses the mock options from the API endpoint.
+func parseMockOptions(endpoint string) (*mockOptions, error) {
+	ret := &mockOptions{
+		chunk: 8,
+		failAfter: -1,
+	}
+	_, query, _ := strings.Cut(endpoint, "?")
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if s := values.Get("chunk"); s != "" {
+		if ret.chunk, err = strconv.Atoi(s); err != nil || ret.chunk < 1 {
+			return nil, fmt.Errorf("invalid mock chunk size \"%s\"", s)
+		}
+	}
+	if s := values.Get("latency"); s != "" {
+		if ret.latency, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid mock latency \"%s\"", s)
+		}
+	}
+	if s := values.Get("fail-after"); s != "" {
+		if ret.failAfter, err = strconv.Atoi(s); err != nil {
+			return nil, fmt.Errorf("invalid mock fail-after \"%s\"", s)
+		}
+	}
+	ret.err = values.Get("error")
+	ret.reasoning = values.Get("reasoning")
+	ret.script = values["script"]
+	for i, s := range values["tool-call"] {
+		name, args, _ := strings.Cut(s, ":")
+		if name == "" {
+			return nil, fmt.Errorf("invalid mock tool-call \"%s\"", s)
+		}
+		ret.toolCalls = append(ret.toolCalls, &ToolCall{
+			ID: fmt.Sprintf("mock-call-%d", i),
+			Name: name,
+			Arguments: args,
+		})
+	}
+	return ret, nil
+}
+
+// ID implements Provider.
+func (p *mockProvider) ID() string { return "mock" }
+
+// Name implements Provider.
+func (p *mockProvider) Name() string { return "Mock (Offline)" }
+
+// Capabilities implements Provider.
+func (p *mockProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Streaming: true,
+		Images: true,
+		ListModels: true,
+		Tools: true,
+	}
+}
+
+// ValidateConfig implements Provider.
+func (p *mockProvider) ValidateConfig(def *LanguageModel) error {
+	opts, err := parseMockOptions(def.APIEndpoint)
+	if err != nil {
+		return err
+	}
+	switch def.Model {
+	case "", "echo":
+	case "script":
+		if len(opts.script) == 0 {
+			return errors.New("the mock script model requires at least one script option in the API endpoint")
+		}
+	default:
+		return fmt.Errorf("unknown mock model \"%s\"", def.Model)
+	}
+	return nil
+}
+
+// ListModels implements Provider.
+func (p *mockProvider) ListModels(def *LanguageModel) ([]string, error) {
+	return []string{"echo", "script"}, nil
+}
+
+// StreamCompletion implements Provider.
+func (p *mockProvider) StreamCompletion(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (chan *Event, func(), error) {
+	opts, err := parseMockOptions(def.APIEndpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	msgs := contextMessages(prompt, chatContext)
+	if len(msgs) == 0 {
+		return nil, nil, errors.New("nothing to complete")
+	}
+	last := msgs[len(msgs)-1]
+	response := last.Content
+	// Echo a description of each attached image
+	for _, img := range last.Images {
+		b := img.Image().Bounds()
+		response += fmt.Sprintf("\n[%s %dx%d]", img.MimeType(), b.Dx(), b.Dy())
+	}
+	// Echo all of the tool results when continuing after tool calls
+	var calls []*ToolCall
+	if last.Role == "tool" {
+		results := []string{}
+		for i := len(msgs)-1; i >= 0 && msgs[i].Role == "tool"; i-- {
+			results = append([]string{msgs[i].Name + ": " + msgs[i].Content}, results...)
+		}
+		response = strings.Join(results, "\n")
+	} else if len(tools) > 0 {
+		calls = opts.toolCalls
+	}
+	if def.Model == "script" {
+		users := 0
+		for _, msg := range msgs {
+			if msg.Role == "user" {
+				users++
+			}
+		}
+		response = opts.script[max(users-1, 0)%len(opts.script)]
+	}
+	requestID := mockRequestID(def, system, msgs)
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan *Event, 1024)
+	go func() {
+		defer close(out)
+		if opts.err != "" {
+			sendError(out, providerError(opts.err))
+			return
+		}
+		chunks := 0
+		// stream sends text in chunks as events of type t and returns false
+		// if the stream failed
+		stream := func(t EventType, text string) bool {
+			runes := []rune(text)
+			for i := 0; i < len(runes); i += opts.chunk {
+				if chunks == opts.failAfter {
+					sendError(out, providerError(fmt.Sprintf("mock failure after %d chunks", chunks)))
+					return false
+				}
+				if opts.latency > 0 {
+					select {
+					case <-ctx.Done():
+						sendError(out, ctx.Err())
+						return false
+					case <-time.After(opts.latency):
+					}
+				} else if ctx.Err() != nil {
+					sendError(out, ctx.Err())
+					return false
+				}
+				out <- &Event{
+					Type: t,
+					Text: string(runes[i:min(i+opts.chunk, len(runes))]),
+				}
+				chunks++
+			}
+			return true
+		}
+		if !stream(EventReasoning, opts.reasoning) {
+			return
+		}
+		finishReason := "stop"
+		if len(calls) > 0 {
+			// Request the tool calls instead of responding
+			for i, tc := range calls {
+				out <- &Event{
+					Type: EventToolCall,
+					ToolCall: &ToolCallDelta{
+						Index: i,
+						ID: tc.ID,
+						Name: tc.Name,
+						Arguments: tc.Arguments,
+					},
+				}
+			}
+			response = ""
+			finishReason = "tool_calls"
+		} else if !stream(EventContent, response) {
+			return
+		}
+		out <- &Event{
+			Type: EventUsage,
+			Usage: &Usage{
+				PromptTokens: len(strings.Fields(last.Content)),
+				CompletionTokens: len(strings.Fields(response)),
+			},
+		}
+		out <- &Event{
+			Type: EventFinish,
+			FinishReason: finishReason,
+			Model: "mock/" + def.Model,
+			RequestID: requestID,
+		}
+	}()
+	return out, cancel, nil
+}
+
+// mockRequestID returns a request ID derived from the content of the request
+// so that identical requests get identical IDs.
+func mockRequestID(def *LanguageModel, system *Message, msgs []*Message) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00", def.Model)
+	if system != nil {
+		fmt.Fprintf(h, "%s\x00", system.Content)
+	}
+	for _, msg := range msgs {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00", msg.Role, msg.Content, msg.ToolCallID, len(msg.Images))
+		for _, tc := range msg.ToolCalls {
+			fmt.Fprintf(h, "%s\x00%s\x00%s\x00", tc.ID, tc.Name, tc.Arguments)
+		}
+	}
+	return fmt.Sprintf("mock-%016x", h.Sum64())
+}
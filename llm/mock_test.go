@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// mockDef returns a mock LLM definition with the model and endpoint options.
+func mockDef(model, options string) *LanguageModel {
+	return &LanguageModel{
+		API: "mock",
+		APIEndpoint: "mock://?" + options,
+		Model: model,
+	}
+}
+
+// collectEvents runs a chat completion to the end and returns its events.
+func collectEvents(t *testing.T, def *LanguageModel, prompt string) []*Event {
+	t.Helper()
+	events, cancel, err := ChatCompletion(def, nil, &Message{
+		Role: "user",
+		Content: prompt,
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	defer cancel()
+	ret := []*Event{}
+	for e := range events {
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+func TestMockChunks(t *testing.T) {
+	for _, tc := range []struct {
+		chunk string
+		want []string
+	}{
+		{"chunk=1", []string{"h", "e", "l", "l", "o", " ", "w", "o", "r", "l", "d"}},
+		{"chunk=4", []string{"hell", "o wo", "rld"}},
+		{"chunk=11", []string{"hello world"}},
+		{"", []string{"hello wo", "rld"}},
+	} {
+		got := []string{}
+		for _, e := range collectEvents(t, mockDef("echo", tc.chunk), "hello world") {
+			if e.Type == EventContent {
+				got = append(got, e.Text)
+			}
+		}
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%s: chunks = %q, want %q", tc.chunk, got, tc.want)
+		}
+	}
+}
+
+func TestMockEcho(t *testing.T) {
+	turn := completeTurn(t, mockDef("echo", ""), "hello there world", nil, nil)
+	if turn.Error != nil {
+		t.Fatalf("unexpected error: %v", turn.Error)
+	}
+	if got := responseText(turn); got != "hello there world" {
+		t.Errorf("response = %q", got)
+	}
+	if turn.FinishReason != "stop" || turn.Model != "mock/echo" {
+		t.Errorf("finish = %q %q", turn.FinishReason, turn.Model)
+	}
+	if turn.Usage == nil || turn.Usage.PromptTokens != 3 || turn.Usage.CompletionTokens != 3 {
+		t.Errorf("usage = %+v", turn.Usage)
+	}
+}
+
+func TestMockError(t *testing.T) {
+	turn := completeTurn(t, mockDef("echo", "error=overloaded"), "hello", nil, nil)
+	if turn.Error == nil || turn.Error.Kind != ErrorKindProvider || turn.Error.Body != "overloaded" {
+		t.Fatalf("error = %v, want provider error \"overloaded\"", turn.Error)
+	}
+	if len(turn.Response) != 0 {
+		t.Errorf("unexpected response %q", responseText(turn))
+	}
+}
+
+func TestMockFailAfter(t *testing.T) {
+	turn := completeTurn(t, mockDef("echo", "chunk=2&fail-after=2"), "abcdefgh", nil, nil)
+	if turn.Error == nil || turn.Error.Kind != ErrorKindProvider {
+		t.Fatalf("error = %v, want provider error", turn.Error)
+	}
+	if got := responseText(turn); got != "abcd" {
+		t.Errorf("response = %q, want the first two chunks", got)
+	}
+	if turn.FinishReason != "" {
+		t.Errorf("failed stream finished with %q", turn.FinishReason)
+	}
+}
+
+func TestMockReasoning(t *testing.T) {
+	events := collectEvents(t, mockDef("echo", "chunk=3&reasoning=thinking"), "hi")
+	turn := &Turn{}
+	sawContent := false
+	for _, e := range events {
+		if e.Type == EventReasoning && sawContent {
+			t.Error("reasoning streamed after content")
+		}
+		if e.Type == EventContent {
+			sawContent = true
+		}
+		turn.Apply(e)
+	}
+	if turn.Reasoning != "thinking" || responseText(turn) != "hi" {
+		t.Errorf("reasoning = %q, response = %q", turn.Reasoning, responseText(turn))
+	}
+}
+
+func TestMockScript(t *testing.T) {
+	def := mockDef("script", "script=one&script=two")
+	first := completeTurn(t, def, "a", nil, nil)
+	second := completeTurn(t, def, "b", []*Turn{first}, nil)
+	third := completeTurn(t, def, "c", []*Turn{first, second}, nil)
+	for i, tc := range []struct {
+		turn *Turn
+		want string
+	}{
+		{first, "one"},
+		{second, "two"},
+		{third, "one"},
+	} {
+		if got := responseText(tc.turn); got != tc.want {
+			t.Errorf("turn %d = %q, want %q", i+1, got, tc.want)
+		}
+	}
+}
+
+func TestMockRequestID(t *testing.T) {
+	def := mockDef("echo", "")
+	a := completeTurn(t, def, "hello", nil, nil)
+	b := completeTurn(t, def, "hello", nil, nil)
+	c := completeTurn(t, def, "goodbye", nil, nil)
+	if a.RequestID == "" || a.RequestID != b.RequestID {
+		t.Errorf("identical requests got IDs %q and %q", a.RequestID, b.RequestID)
+	}
+	if a.RequestID == c.RequestID {
+		t.Errorf("different requests got the same ID %q", a.RequestID)
+	}
+}
+
+func TestMockToolCall(t *testing.T) {
+	calls := 0
+	tool := &Tool{
+		ToolDefinition: ToolDefinition{
+			Name: "lookup",
+		},
+		Func: func(ctx context.Context, arguments string) (string, error) {
+			calls++
+			if arguments != `{"q":1}` {
+				t.Errorf("arguments = %q", arguments)
+			}
+			return "found", nil
+		},
+	}
+	def := mockDef("echo", `tool-call=lookup:{"q":1}`)
+	turn := &Turn{
+		Definition: *def,
+	}
+	events, cancel, err := RunTools(def, nil, &Message{
+		Role: "user",
+		Content: "find it",
+	}, nil, []*Tool{tool}, 0)
+	if err != nil {
+		t.Fatalf("RunTools: %v", err)
+	}
+	defer cancel()
+	for e := range events {
+		turn.Apply(e)
+	}
+	if turn.Error != nil {
+		t.Fatalf("unexpected error: %v", turn.Error)
+	}
+	if calls != 1 || len(turn.ToolCalls) != 1 || turn.ToolCalls[0].Name != "lookup" {
+		t.Fatalf("calls = %d, tool calls = %+v", calls, turn.ToolCalls)
+	}
+	// The mock echoes the tool results after the call
+	if len(turn.Response) != 3 || turn.Response[1].Role != "tool" || turn.Response[2].Content != "lookup: found" {
+		t.Errorf("unexpected responses %+v", turn.Response)
+	}
+	if turn.FinishReason != "stop" {
+		t.Errorf("finish reason = %q", turn.FinishReason)
+	}
+}
+
+func TestRunToolsAPICase(t *testing.T) {
+	def := mockDef("echo", "tool-call=noop:{}")
+	def.API = "Mock"
+	tool := &Tool{
+		ToolDefinition: ToolDefinition{
+			Name: "noop",
+		},
+		Func: func(ctx context.Context, arguments string) (string, error) {
+			return "ok", nil
+		},
+	}
+	events, cancel, err := RunTools(def, nil, &Message{
+		Role: "user",
+		Content: "go",
+	}, nil, []*Tool{tool}, 0)
+	if err != nil {
+		t.Fatalf("RunTools: %v", err)
+	}
+	defer cancel()
+	turn := &Turn{}
+	for e := range events {
+		turn.Apply(e)
+	}
+	if turn.Error != nil || len(turn.ToolCalls) != 1 {
+		t.Errorf("error = %v, tool calls = %+v", turn.Error, turn.ToolCalls)
+	}
+}
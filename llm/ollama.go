@@ -0,0 +1,265 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ollamaDefaultEndpoint is the endpoint used when the LLM definition does not
+// name one.
+const ollamaDefaultEndpoint string = "http://localhost:11434"
+
+func init() {
+	RegisterProvider(&ollamaProvider{})
+}
+
+// ollamaProvider implements the native Ollama chat API.
+type ollamaProvider struct{}
+
+// ollamaToolCall is a tool call of an assistant message.
+type ollamaToolCall struct {
+	Function struct {
+		Name string `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaMessage is one message of an Ollama chat request.
+type ollamaMessage struct {
+	Role string `json:"role"`
+	Content string `json:"content"`
+	Images []string `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+// ollamaOptions are the model options of an Ollama chat request.
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP *float64 `json:"top_p,omitempty"`
+	TopK *int `json:"top_k,omitempty"`
+	NumPredict *int `json:"num_predict,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"`
+	Stop []string `json:"stop,omitempty"`
+	Seed *int `json:"seed,omitempty"`
+}
+
+// ollamaRequest is the body of an Ollama chat request.
+type ollamaRequest struct {
+	Model string `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream bool `json:"stream"`
+	Options *ollamaOptions `json:"options,omitempty"`
+	Tools []openAITool `json:"tools,omitempty"`
+}
+
+// ollamaChunk is one line of a streamed Ollama chat response.
+type ollamaChunk struct {
+	Model string `json:"model"`
+	Message struct {
+		Role string `json:"role"`
+		Content string `json:"content"`
+		Thinking string `json:"thinking"`
+		ToolCalls []ollamaToolCall `json:"tool_calls"`
+	} `json:"message"`
+	Done bool `json:"done"`
+	DoneReason string `json:"done_reason"`
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount int `json:"eval_count"`
+	Error string `json:"error"`
+}
+
+// ID implements Provider.
+func (p *ollamaProvider) ID() string { return "ollama" }
+
+// Name implements Provider.
+func (p *ollamaProvider) Name() string { return "Ollama" }
+
+// Capabilities implements Provider.
+func (p *ollamaProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Streaming: true,
+		Images: true,
+		ListModels: true,
+		Tools: true,
+	}
+}
+
+// ValidateConfig implements Provider.
+func (p *ollamaProvider) ValidateConfig(def *LanguageModel) error {
+	if def.Model == "" {
+		return errors.New("a model is required in LLM configuration for Ollama")
+	}
+	return nil
+}
+
+// endpoint returns the base URL of the Ollama server.
+func (p *ollamaProvider) endpoint(def *LanguageModel) string {
+	if def.APIEndpoint == "" {
+		return ollamaDefaultEndpoint
+	}
+	return def.APIEndpoint
+}
+
+// ListModels implements Provider.
+func (p *ollamaProvider) ListModels(def *LanguageModel) ([]string, error) {
+	var res struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	err := getJSON(context.Background(), endpointURL(p.endpoint(def), "/api/tags"), def.Headers, &res)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, model := range res.Models {
+		ret = append(ret, model.Name)
+	}
+	return ret, nil
+}
+
+// Request implements RequestBuilder.
+func (p *ollamaProvider) Request(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (*Request, error) {
+	req := ollamaRequest{
+		Model: def.Model,
+		Messages: []ollamaMessage{},
+		Stream: true,
+		Tools: openAITools(tools),
+	}
+	if params := def.Parameters; !params.IsZero() {
+		req.Options = &ollamaOptions{
+			Temperature: params.Temperature,
+			TopP: params.TopP,
+			TopK: params.TopK,
+			NumPredict: params.MaxTokens,
+			FrequencyPenalty: params.FrequencyPenalty,
+			PresencePenalty: params.PresencePenalty,
+			Stop: params.Stop,
+			Seed: params.Seed,
+		}
+	}
+	if system != nil && system.Content != "" {
+		req.Messages = append(req.Messages, ollamaMessage{
+			Role: "system",
+			Content: system.Content,
+		})
+	}
+	for _, msg := range contextMessages(prompt, chatContext) {
+		m := ollamaMessage{
+			Role: msg.Role,
+			Content: msg.Content,
+			ToolName: msg.Name,
+		}
+		for _, tc := range msg.ToolCalls {
+			call := ollamaToolCall{}
+			call.Function.Name = tc.Name
+			call.Function.Arguments = json.RawMessage(tc.Arguments)
+			if tc.Arguments == "" {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			m.ToolCalls = append(m.ToolCalls, call)
+		}
+		for _, img := range msg.Images {
+			_, data := img.encoded("image/png", "image/jpeg")
+			m.Images = append(m.Images, data)
+		}
+		req.Messages = append(req.Messages, m)
+	}
+	return &Request{
+		Method: http.MethodPost,
+		URL: endpointURL(p.endpoint(def), "/api/chat"),
+		Headers: def.Headers,
+		Body: req,
+	}, nil
+}
+
+// StreamCompletion implements Provider.
+func (p *ollamaProvider) StreamCompletion(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (chan *Event, func(), error) {
+	req, err := p.Request(def, system, prompt, chatContext, tools)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan *Event, 1024)
+	ctx = withCapture(ctx, out)
+	go func() {
+		defer close(out)
+		res, err := doJSON(ctx, req.Method, req.URL, req.Headers, req.Body)
+		if err != nil {
+			sendError(out, err)
+			return
+		}
+		defer res.Body.Close()
+		scanner := bufio.NewScanner(res.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+		toolCalls := 0
+		for scanner.Scan() {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var chunk ollamaChunk
+			if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+				sendError(out, err)
+				return
+			}
+			if chunk.Error != "" {
+				sendError(out, providerError(chunk.Error))
+				return
+			}
+			if chunk.Message.Thinking != "" {
+				out <- &Event{
+					Type: EventReasoning,
+					Text: chunk.Message.Thinking,
+				}
+			}
+			if chunk.Message.Content != "" {
+				out <- &Event{
+					Type: EventContent,
+					Role: chunk.Message.Role,
+					Text: chunk.Message.Content,
+				}
+			}
+			// Ollama sends complete tool calls
+			for _, tc := range chunk.Message.ToolCalls {
+				out <- &Event{
+					Type: EventToolCall,
+					ToolCall: &ToolCallDelta{
+						Index: toolCalls,
+						ID: fmt.Sprintf("call_%d", toolCalls),
+						Name: tc.Function.Name,
+						Arguments: string(tc.Function.Arguments),
+					},
+				}
+				toolCalls++
+			}
+			if chunk.Done {
+				out <- &Event{
+					Type: EventUsage,
+					Usage: &Usage{
+						PromptTokens: chunk.PromptEvalCount,
+						CompletionTokens: chunk.EvalCount,
+					},
+				}
+				out <- &Event{
+					Type: EventFinish,
+					FinishReason: chunk.DoneReason,
+					Model: chunk.Model,
+				}
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			sendError(out, err)
+			return
+		}
+		sendError(out, io.ErrUnexpectedEOF)
+	}()
+	return out, cancel, nil
+}
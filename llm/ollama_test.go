@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// newOllamaServer returns a stand-in Ollama server that answers chat requests
+// with the NDJSON lines and records the last request body.
+func newOllamaServer(t *testing.T, lines string, body *ollamaRequest) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/chat", func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(buf, body); err != nil {
+			t.Errorf("decoding chat request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, lines)
+	})
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"models":[{"name":"llama3:8b"},{"name":"qwen3:4b"}]}`)
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestOllamaStream(t *testing.T) {
+	var req ollamaRequest
+	s := newOllamaServer(t, `{"model":"llama3","message":{"role":"assistant","thinking":"hmm"},"done":false}
+{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}
+
+{"model":"llama3","message":{"role":"assistant","content":"lo"},"done":false}
+{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":3}
+`, &req)
+	def := &LanguageModel{
+		API: "ollama",
+		APIEndpoint: s.URL,
+		Model: "llama3",
+	}
+	turn := completeTurn(t, def, "Hi", nil, nil)
+	if turn.Error != nil {
+		t.Fatalf("unexpected error: %v", turn.Error)
+	}
+	if got := responseText(turn); got != "Hello" {
+		t.Errorf("response = %q, want %q", got, "Hello")
+	}
+	if turn.Reasoning != "hmm" {
+		t.Errorf("reasoning = %q, want %q", turn.Reasoning, "hmm")
+	}
+	if turn.Usage == nil || turn.Usage.PromptTokens != 12 || turn.Usage.CompletionTokens != 3 {
+		t.Errorf("usage = %+v, want 12 prompt and 3 completion tokens", turn.Usage)
+	}
+	if turn.FinishReason != "stop" || turn.Model != "llama3" {
+		t.Errorf("finish = %q %q, want stop llama3", turn.FinishReason, turn.Model)
+	}
+	if len(turn.Exchanges) != 1 || len(turn.Exchanges[0].Chunks) != 4 {
+		t.Errorf("expected one exchange with four chunks, got %+v", turn.Exchanges)
+	}
+	if req.Model != "llama3" || !req.Stream || len(req.Messages) != 1 || req.Messages[0].Content != "Hi" {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+func TestOllamaErrorLine(t *testing.T) {
+	var req ollamaRequest
+	s := newOllamaServer(t, `{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}
+{"error":"model crashed"}
+`, &req)
+	def := &LanguageModel{
+		API: "ollama",
+		APIEndpoint: s.URL,
+		Model: "llama3",
+	}
+	turn := completeTurn(t, def, "Hi", nil, nil)
+	if turn.Error == nil || turn.Error.Kind != ErrorKindProvider || turn.Error.Body != "model crashed" {
+		t.Fatalf("error = %v, want provider error \"model crashed\"", turn.Error)
+	}
+	if got := responseText(turn); got != "Hel" {
+		t.Errorf("response = %q, want %q", got, "Hel")
+	}
+}
+
+func TestOllamaUnexpectedEOF(t *testing.T) {
+	var req ollamaRequest
+	s := newOllamaServer(t, `{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}
+`, &req)
+	def := &LanguageModel{
+		API: "ollama",
+		APIEndpoint: s.URL,
+		Model: "llama3",
+	}
+	turn := completeTurn(t, def, "Hi", nil, nil)
+	if turn.Error == nil {
+		t.Fatal("expected an error for a stream without a done line")
+	}
+}
+
+func TestOllamaListModels(t *testing.T) {
+	var req ollamaRequest
+	s := newOllamaServer(t, "", &req)
+	models, err := ListModels(&LanguageModel{
+		API: "ollama",
+		APIEndpoint: s.URL,
+	})
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if want := []string{"llama3:8b", "qwen3:4b"}; !slices.Equal(models, want) {
+		t.Errorf("models = %v, want %v", models, want)
+	}
+}
@@ -0,0 +1,392 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+func init() {
+	RegisterProvider(&openAIProvider{})
+}
+
+// openAIProvider implements the OpenAI Chat Completions API as spoken by many
+// local servers and proxies.
+type openAIProvider struct{}
+
+// openAIImageURL is the image of an image content part.
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// openAIContentPart is one part of the content of a multimodal message.
+type openAIContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+// openAIFunctionCall is the function of a tool call.
+type openAIFunctionCall struct {
+	Name string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// openAIToolCall is a tool call of an assistant message.
+type openAIToolCall struct {
+	ID string `json:"id"`
+	Type string `json:"type"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+// openAIMessage is one message of an OpenAI chat completion request. Content
+// is either a string or, for messages with images, a slice of
+// openAIContentPart. It is nil for assistant messages with only tool calls.
+type openAIMessage struct {
+	Role string `json:"role"`
+	Content any `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// openAIFunction is the function of a tool offered to the model.
+type openAIFunction struct {
+	Name string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// openAITool is a tool offered to the model.
+type openAITool struct {
+	Type string `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+// openAIStreamOptions are the stream options of an OpenAI chat completion
+// request.
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIRequest is the body of an OpenAI chat completion request. The
+// sampling parameters use the OpenAI field names, top_k being an extension
+// understood by most local servers and OpenRouter.
+type openAIRequest struct {
+	Model string `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream bool `json:"stream"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+	Tools []openAITool `json:"tools,omitempty"`
+	Parameters
+}
+
+// openAIChunk is one chunk of a streamed OpenAI chat completion response.
+type openAIChunk struct {
+	ID string `json:"id"`
+	Model string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role string `json:"role"`
+			Content string `json:"content"`
+			Reasoning string `json:"reasoning"`
+			ReasoningContent string `json:"reasoning_content"`
+			ToolCalls []struct {
+				Index int `json:"index"`
+				ID string `json:"id"`
+				Function struct {
+					Name string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+		CompletionTokensDetails struct {
+			ReasoningTokens int `json:"reasoning_tokens"`
+		} `json:"completion_tokens_details"`
+		// Cost is reported by OpenRouter with usage accounting
+		Cost float64 `json:"cost"`
+	} `json:"usage"`
+	Error json.RawMessage `json:"error"`
+}
+
+// ID implements Provider.
+func (p *openAIProvider) ID() string { return "openai-compatible" }
+
+// Name implements Provider.
+func (p *openAIProvider) Name() string { return "OpenAI-Compatible" }
+
+// Capabilities implements Provider.
+func (p *openAIProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Streaming: true,
+		Images: true,
+		ListModels: true,
+		RequiresEndpoint: true,
+		Tools: true,
+	}
+}
+
+// ValidateConfig implements Provider.
+func (p *openAIProvider) ValidateConfig(def *LanguageModel) error {
+	if def.APIEndpoint == "" {
+		return errors.New("an API endpoint is required in LLM configuration for OpenAI-compatible APIs")
+	}
+	if def.Model == "" {
+		return errors.New("a model is required in LLM configuration for OpenAI-compatible APIs")
+	}
+	return nil
+}
+
+// ListModels implements Provider.
+func (p *openAIProvider) ListModels(def *LanguageModel) ([]string, error) {
+	return openAIListModels(def.APIEndpoint, openAIHeaders(def))
+}
+
+// Request implements RequestBuilder.
+func (p *openAIProvider) Request(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (*Request, error) {
+	return &Request{
+		Method: http.MethodPost,
+		URL: endpointURL(def.APIEndpoint, "/chat/completions"),
+		Headers: openAIHeaders(def),
+		Body: &openAIRequest{
+			Model: def.Model,
+			Messages: openAIMessages(system, prompt, chatContext),
+			Stream: true,
+			// Usage is only reported in streams if requested
+			StreamOptions: &openAIStreamOptions{
+				IncludeUsage: true,
+			},
+			Tools: openAITools(tools),
+			Parameters: def.Parameters,
+		},
+	}, nil
+}
+
+// StreamCompletion implements Provider.
+func (p *openAIProvider) StreamCompletion(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (chan *Event, func(), error) {
+	req, err := p.Request(def, system, prompt, chatContext, tools)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, cancel := openAIStream(req)
+	return out, cancel, nil
+}
+
+// openAIHeaders returns the HTTP headers to send with every request.
+func openAIHeaders(def *LanguageModel) map[string]string {
+	ret := map[string]string{}
+	if def.APIKey != "" {
+		ret["Authorization"] = "Bearer " + def.APIKey
+	}
+	for k, v := range def.Headers {
+		ret[k] = v
+	}
+	return ret
+}
+
+// openAIMessages returns the request messages for the given inputs.
+func openAIMessages(system, prompt *Message, chatContext []*Turn) []openAIMessage {
+	ret := []openAIMessage{}
+	if system != nil && system.Content != "" {
+		ret = append(ret, openAIMessage{
+			Role: "system",
+			Content: system.Content,
+		})
+	}
+	for _, msg := range contextMessages(prompt, chatContext) {
+		if len(msg.ToolCalls) > 0 || msg.Role == "tool" {
+			m := openAIMessage{
+				Role: msg.Role,
+				ToolCallID: msg.ToolCallID,
+			}
+			if msg.Content != "" || msg.Role == "tool" {
+				m.Content = msg.Content
+			}
+			for _, tc := range msg.ToolCalls {
+				m.ToolCalls = append(m.ToolCalls, openAIToolCall{
+					ID: tc.ID,
+					Type: "function",
+					Function: openAIFunctionCall{
+						Name: tc.Name,
+						Arguments: tc.Arguments,
+					},
+				})
+			}
+			ret = append(ret, m)
+			continue
+		}
+		if len(msg.Images) == 0 {
+			ret = append(ret, openAIMessage{
+				Role: msg.Role,
+				Content: msg.Content,
+			})
+			continue
+		}
+		parts := []openAIContentPart{}
+		if msg.Content != "" {
+			parts = append(parts, openAIContentPart{
+				Type: "text",
+				Text: msg.Content,
+			})
+		}
+		for _, img := range msg.Images {
+			mimeType, data := img.encoded("image/png", "image/jpeg", "image/webp", "image/gif")
+			parts = append(parts, openAIContentPart{
+				Type: "image_url",
+				ImageURL: &openAIImageURL{
+					URL: "data:" + mimeType + ";base64," + data,
+				},
+			})
+		}
+		ret = append(ret, openAIMessage{
+			Role: msg.Role,
+			Content: parts,
+		})
+	}
+	return ret
+}
+
+// openAITools returns the request tools for the given tool definitions.
+func openAITools(tools []*ToolDefinition) []openAITool {
+	ret := []openAITool{}
+	for _, t := range tools {
+		ret = append(ret, openAITool{
+			Type: "function",
+			Function: openAIFunction{
+				Name: t.Name,
+				Description: t.Description,
+				Parameters: t.Parameters,
+			},
+		})
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret
+}
+
+// openAIListModels lists the models available from an OpenAI-compatible API.
+func openAIListModels(endpoint string, headers map[string]string) ([]string, error) {
+	var res struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	err := getJSON(context.Background(), endpointURL(endpoint, "/models"), headers, &res)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, model := range res.Data {
+		ret = append(ret, model.ID)
+	}
+	return ret, nil
+}
+
+// openAIStream starts a streaming chat completion request to an
+// OpenAI-compatible API and returns the response stream and a function that
+// cancels the request.
+func openAIStream(req *Request) (chan *Event, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan *Event, 1024)
+	ctx = withCapture(ctx, out)
+	go func() {
+		defer close(out)
+		res, err := doJSON(ctx, req.Method, req.URL, req.Headers, req.Body)
+		if err != nil {
+			sendError(out, err)
+			return
+		}
+		defer res.Body.Close()
+		finish := &Event{
+			Type: EventFinish,
+			RequestID: res.Header.Get("X-Request-Id"),
+		}
+		var streamErr error
+		err = readSSE(res.Body, func(event, data string) bool {
+			if data == "[DONE]" {
+				return false
+			}
+			var chunk openAIChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				streamErr = err
+				return false
+			}
+			if len(chunk.Error) > 0 && string(chunk.Error) != "null" {
+				streamErr = providerError(string(chunk.Error))
+				return false
+			}
+			if chunk.ID != "" {
+				finish.RequestID = chunk.ID
+			}
+			if chunk.Model != "" {
+				finish.Model = chunk.Model
+			}
+			// Only the first choice is used
+			for _, choice := range chunk.Choices {
+				if choice.Index != 0 {
+					continue
+				}
+				d := choice.Delta
+				if d.Reasoning != "" || d.ReasoningContent != "" {
+					out <- &Event{
+						Type: EventReasoning,
+						Text: d.Reasoning + d.ReasoningContent,
+					}
+				}
+				if d.Content != "" {
+					out <- &Event{
+						Type: EventContent,
+						Role: d.Role,
+						Text: d.Content,
+					}
+				}
+				for _, tc := range d.ToolCalls {
+					out <- &Event{
+						Type: EventToolCall,
+						ToolCall: &ToolCallDelta{
+							Index: tc.Index,
+							ID: tc.ID,
+							Name: tc.Function.Name,
+							Arguments: tc.Function.Arguments,
+						},
+					}
+				}
+				if choice.FinishReason != "" {
+					finish.FinishReason = choice.FinishReason
+				}
+			}
+			if chunk.Usage != nil {
+				out <- &Event{
+					Type: EventUsage,
+					Usage: &Usage{
+						PromptTokens: chunk.Usage.PromptTokens,
+						CompletionTokens: chunk.Usage.CompletionTokens,
+						ReasoningTokens: chunk.Usage.CompletionTokensDetails.ReasoningTokens,
+						CachedTokens: chunk.Usage.PromptTokensDetails.CachedTokens,
+						Cost: chunk.Usage.Cost,
+					},
+				}
+			}
+			return true
+		})
+		if err == nil {
+			err = streamErr
+		}
+		if err != nil {
+			sendError(out, err)
+			return
+		}
+		out <- finish
+	}()
+	return out, cancel
+}
@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIStreamUsage(t *testing.T) {
+	var body map[string]any
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		buf, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(buf, &body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"id":"c1","model":"gpt","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}
+
+data: {"id":"c1","model":"gpt","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: {"id":"c1","model":"gpt","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":1,"prompt_tokens_details":{"cached_tokens":4}}}
+
+data: [DONE]
+
+`)
+	}))
+	defer s.Close()
+	def := &LanguageModel{
+		API: "openai-compatible",
+		APIEndpoint: s.URL + "/v1",
+		Model: "gpt",
+	}
+	turn := completeTurn(t, def, "Hello", nil, nil)
+	if turn.Error != nil {
+		t.Fatalf("unexpected error: %v", turn.Error)
+	}
+	opts, _ := body["stream_options"].(map[string]any)
+	if body["stream"] != true || opts["include_usage"] != true {
+		t.Errorf("request did not ask for usage: %v", body)
+	}
+	if responseText(turn) != "Hi" || turn.FinishReason != "stop" {
+		t.Errorf("response = %q finish = %q", responseText(turn), turn.FinishReason)
+	}
+	if turn.Usage == nil || turn.Usage.PromptTokens != 9 || turn.Usage.CompletionTokens != 1 || turn.Usage.CachedTokens != 4 {
+		t.Errorf("usage = %+v", turn.Usage)
+	}
+}
@@ -1,76 +1,105 @@
 package llm
 
 import (
-	"context"
 	"errors"
-	"io"
-	"log"
-
-	"github.com/revrost/go-openrouter"
+	"net/http"
 )
 
-func openRouterChatCompletion(def *LanguageModel, system, prompt *Message, chatContext []*Turn) (chan *Message, func(), error) {
+func init() {
+	RegisterProvider(&openRouterProvider{})
+}
+
+// openRouterProvider implements the OpenRouter.ai API, which is an extension
+// of the OpenAI Chat Completions API.
+type openRouterProvider struct{}
+
+// openRouterUsage requests usage accounting in the response.
+type openRouterUsage struct {
+	Include bool `json:"include"`
+}
+
+// openRouterRequest is the body of an OpenRouter chat completion request.
+type openRouterRequest struct {
+	openAIRequest
+	Usage *openRouterUsage `json:"usage,omitempty"`
+}
+
+// ID implements Provider.
+func (p *openRouterProvider) ID() string { return "openrouter" }
+
+// Name implements Provider.
+func (p *openRouterProvider) Name() string { return "OpenRouter.ai" }
+
+// Capabilities implements Provider.
+func (p *openRouterProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Streaming: true,
+		Images: true,
+		ListModels: true,
+		RequiresEndpoint: true,
+		RequiresAPIKey: true,
+		Tools: true,
+	}
+}
+
+// ValidateConfig implements Provider.
+func (p *openRouterProvider) ValidateConfig(def *LanguageModel) error {
 	if def.APIEndpoint == "" {
-		return nil, nil, errors.New("an API endpoint is required in LLM configuration for OpenRouter")
+		return errors.New("an API endpoint is required in LLM configuration for OpenRouter")
 	}
 	if def.APIKey == "" {
-		return nil, nil, errors.New("an API key is required in LLM configuration for OpenRouter")
+		return errors.New("an API key is required in LLM configuration for OpenRouter")
 	}
 	if def.Model == "" {
-		return nil, nil, errors.New("a model is required in LLM configuration for OpenRouter")
+		return errors.New("a model is required in LLM configuration for OpenRouter")
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	out := make(chan *Message, 1024)
-	client := openrouter.NewClient(def.APIKey)
-	messages := []openrouter.ChatCompletionMessage{
-		openrouter.SystemMessage(system.Content),
+	return nil
+}
+
+// headers returns the HTTP headers to send with every request.
+func (p *openRouterProvider) headers(def *LanguageModel) map[string]string {
+	ret := map[string]string{
+		"HTTP-Referer": "https://github.com/qbradq/gen-magic",
+		"X-Title": "Gen Magic",
 	}
-	for _, turn := range chatContext {
-		for _, msg := range turn.Response {
-			switch msg.Role {
-			case "user":
-				messages = append(messages, openrouter.UserMessage(msg.Content))
-			case "assistant":
-				messages = append(messages, openrouter.AssistantMessage(msg.Content))
-			default:
-				log.Printf("error in openRouterChatCompletion unsupported message role in chat context %s\n", msg.Role)
-			}
-		}
+	for k, v := range openAIHeaders(def) {
+		ret[k] = v
 	}
-	messages = append(messages, openrouter.UserMessage(prompt.Content))
-	go func() {
-		defer close(out)
-		stream, err := client.CreateChatCompletionStream(ctx, openrouter.ChatCompletionRequest{
-			Model: def.Model,
-			Messages: messages,
-			Stream: true,
-			Usage: &openrouter.IncludeUsage{
+	return ret
+}
+
+// ListModels implements Provider.
+func (p *openRouterProvider) ListModels(def *LanguageModel) ([]string, error) {
+	return openAIListModels(def.APIEndpoint, p.headers(def))
+}
+
+// Request implements RequestBuilder.
+func (p *openRouterProvider) Request(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (*Request, error) {
+	return &Request{
+		Method: http.MethodPost,
+		URL: endpointURL(def.APIEndpoint, "/chat/completions"),
+		Headers: p.headers(def),
+		Body: &openRouterRequest{
+			openAIRequest: openAIRequest{
+				Model: def.Model,
+				Messages: openAIMessages(system, prompt, chatContext),
+				Stream: true,
+				Tools: openAITools(tools),
+				Parameters: def.Parameters,
+			},
+			Usage: &openRouterUsage{
 				Include: true,
 			},
-		})
-		if err != nil {
-			log.Printf("error requesting streaming response: %v\n", err)
-		}
-		defer stream.Close()
-		first := true
-		for {
-			response, err := stream.Recv()
-			if err != nil {
-				if !errors.Is(err, io.EOF) {
-					log.Printf("error streaming response: %v\n", err)
-				}
-				break
-			}
-			for _, choice := range response.Choices {
-				// log.Println(choice.Delta.Content)
-				out <- &Message{
-					Role: choice.Delta.Role,
-					Content: choice.Delta.Content,
-					Delta: !first,
-				}
-				first = false
-			}
-		}
-	}()
+		},
+	}, nil
+}
+
+// StreamCompletion implements Provider.
+func (p *openRouterProvider) StreamCompletion(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*ToolDefinition) (chan *Event, func(), error) {
+	req, err := p.Request(def, system, prompt, chatContext, tools)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, cancel := openAIStream(req)
 	return out, cancel, nil
 }
@@ -0,0 +1,288 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded is the cause of runs canceled for exceeding their budget.
+var ErrBudgetExceeded = errors.New("orchestration budget exceeded")
+
+// Budget limits an orchestration run. Zero fields are unlimited.
+type Budget struct {
+	// MaxCalls is the maximum number of agent calls in the whole run.
+	MaxCalls int
+	// MaxDepth is the maximum nesting of agent calls. The coordinator is at
+	// depth zero.
+	MaxDepth int
+	// MaxTokens is the maximum number of prompt and completion tokens used by
+	// all completions of the run.
+	MaxTokens int
+	// MaxIterations is the maximum number of completions of one agent call,
+	// see RunTools.
+	MaxIterations int
+}
+
+// TraceNode is one agent call in the trace tree of an orchestration run.
+type TraceNode struct {
+	// ID identifies the node within the run, like "1.2.1".
+	ID string
+	Agent string
+	Prompt string
+	Depth int
+	// Turn accumulates the events of the agent's completions.
+	Turn *Turn
+	Start time.Time
+	End time.Time
+	// Error is the error the agent call ended with, if any.
+	Error error
+	lock sync.Mutex
+	children []*TraceNode
+}
+
+// Children returns the agent calls made by the node so far. It is safe to
+// call while the run is in progress.
+func (n *TraceNode) Children() []*TraceNode {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return append([]*TraceNode{}, n.children...)
+}
+
+// addChild adds a new child node for a call of the agent with the prompt.
+func (n *TraceNode) addChild(agent, prompt string) *TraceNode {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	ret := &TraceNode{
+		ID: n.ID + "." + strconv.Itoa(len(n.children)+1),
+		Agent: agent,
+		Prompt: prompt,
+		Depth: n.Depth + 1,
+	}
+	n.children = append(n.children, ret)
+	return ret
+}
+
+// Answer returns the last response text of the agent.
+func (n *TraceNode) Answer() string {
+	for i := len(n.Turn.Response)-1; i >= 0; i-- {
+		msg := n.Turn.Response[i]
+		if msg.Role != "tool" && msg.Content != "" {
+			return msg.Content
+		}
+	}
+	return ""
+}
+
+// Orchestrator runs a coordinator agent that may call other agents as tools
+// through ask_agent and list_agents. Each agent call may in turn call other
+// agents until the budget's depth limit.
+type Orchestrator struct {
+	Coordinator *Agent
+	// Agents are the agents that may be called.
+	Agents []*Agent
+	// Tools are additional tools offered to every agent.
+	Tools []*Tool
+	Budget Budget
+	// OnStart, if not nil, is called when an agent call starts. Nested
+	// calls start on the goroutine that executes the parent's tool calls.
+	OnStart func(node, parent *TraceNode)
+	// OnEvent, if not nil, is called with every event after it has been
+	// applied to the node's turn.
+	OnEvent func(node *TraceNode, e *Event)
+	// OnEnd, if not nil, is called when an agent call ends.
+	OnEnd func(node *TraceNode)
+	lock sync.Mutex
+	ctx context.Context
+	cancel context.CancelCauseFunc
+	calls int
+	tokens int
+}
+
+// Run runs the coordinator with the prompt and returns the root of the trace
+// tree when all agent calls have ended. The run is canceled with ctx or when
+// it exceeds its budget, in which case the returned error wraps
+// ErrBudgetExceeded. Callbacks of the coordinator are called from the
+// goroutine calling Run and those of nested agent calls from the goroutines
+// executing tool calls, so callbacks may run concurrently with each other and
+// with Run. Children may be read at any time from other goroutines.
+func (o *Orchestrator) Run(ctx context.Context, prompt string) (*TraceNode, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	o.lock.Lock()
+	o.ctx = ctx
+	o.cancel = cancel
+	o.calls = 0
+	o.tokens = 0
+	o.lock.Unlock()
+	root := &TraceNode{
+		ID: "1",
+		Agent: o.Coordinator.Name,
+		Prompt: prompt,
+	}
+	o.call(ctx, root, nil, o.Coordinator)
+	if cause := context.Cause(ctx); cause != nil && (root.Error != nil || errors.Is(cause, ErrBudgetExceeded)) {
+		return root, cause
+	}
+	return root, root.Error
+}
+
+// findAgent returns the callable agent with the given name.
+func (o *Orchestrator) findAgent(name string) *Agent {
+	for _, a := range o.Agents {
+		if strings.EqualFold(a.Name, name) {
+			return a
+		}
+	}
+	return nil
+}
+
+// useTokens adds to the tokens used by the run and cancels the run if this
+// exceeds the budget.
+func (o *Orchestrator) useTokens(n int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.tokens += n
+	if o.Budget.MaxTokens > 0 && o.tokens > o.Budget.MaxTokens {
+		o.cancel(fmt.Errorf("%w: %d of %d tokens used", ErrBudgetExceeded, o.tokens, o.Budget.MaxTokens))
+	}
+}
+
+// tools returns the tools offered to an agent at the given depth.
+func (o *Orchestrator) tools(node *TraceNode) []*Tool {
+	ret := append([]*Tool{}, o.Tools...)
+	if o.Budget.MaxDepth > 0 && node.Depth >= o.Budget.MaxDepth {
+		return ret
+	}
+	names := []string{}
+	for _, a := range o.Agents {
+		names = append(names, a.Name)
+	}
+	nameSchema, _ := json.Marshal(map[string]any{
+		"type": "string",
+		"description": "Name of the agent to ask",
+		"enum": names,
+	})
+	ret = append(ret, &Tool{
+		ToolDefinition: ToolDefinition{
+			Name: "list_agents",
+			Description: "Lists the agents that can be asked with ask_agent along with their models and instructions.",
+			Parameters: json.RawMessage(`{"type": "object", "properties": {}}`),
+		},
+		Func: func(ctx context.Context, arguments string) (string, error) {
+			type agentInfo struct {
+				Name string `json:"name"`
+				Model string `json:"model"`
+				Instructions string `json:"instructions"`
+			}
+			list := []agentInfo{}
+			for _, a := range o.Agents {
+				list = append(list, agentInfo{
+					Name: a.Name,
+					Model: a.LLM.Model,
+					Instructions: a.System.Content,
+				})
+			}
+			buf, err := json.Marshal(list)
+			return string(buf), err
+		},
+	}, &Tool{
+		ToolDefinition: ToolDefinition{
+			Name: "ask_agent",
+			Description: "Sends a prompt to another agent and returns its answer. The agent does not see this conversation, so the prompt must contain everything it needs.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"agent": ` + string(nameSchema) + `,
+					"prompt": {
+						"type": "string",
+						"description": "The complete prompt for the agent"
+					}
+				},
+				"required": ["agent", "prompt"]
+			}`),
+		},
+		Func: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Agent string `json:"agent"`
+				Prompt string `json:"prompt"`
+			}
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", err
+			}
+			agent := o.findAgent(args.Agent)
+			if agent == nil {
+				return "", fmt.Errorf("unknown agent \"%s\", use list_agents", args.Agent)
+			}
+			o.lock.Lock()
+			if o.Budget.MaxCalls > 0 && o.calls >= o.Budget.MaxCalls {
+				o.lock.Unlock()
+				return "", fmt.Errorf("%w: agent call limit of %d reached", ErrBudgetExceeded, o.Budget.MaxCalls)
+			}
+			o.calls++
+			o.lock.Unlock()
+			child := node.addChild(agent.Name, args.Prompt)
+			o.call(ctx, child, node, agent)
+			if child.Error != nil {
+				return "", child.Error
+			}
+			return child.Answer(), nil
+		},
+	})
+	return ret
+}
+
+// call runs one agent call and records it in the node.
+func (o *Orchestrator) call(ctx context.Context, node, parent *TraceNode, agent *Agent) {
+	node.Start = time.Now()
+	prompt := &Message{
+		Role: "user",
+		Content: node.Prompt,
+	}
+	node.Turn = &Turn{
+		Definition: *agent.LLM,
+		System: &agent.System,
+		Prompt: prompt,
+	}
+	if o.OnStart != nil {
+		o.OnStart(node, parent)
+	}
+	defer func() {
+		node.End = time.Now()
+		if o.OnEnd != nil {
+			o.OnEnd(node)
+		}
+	}()
+	events, cancel, err := RunTools(agent.LLM, &agent.System, prompt, nil, o.tools(node), o.Budget.MaxIterations)
+	if err != nil {
+		node.Error = err
+		return
+	}
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	used := 0
+	for e := range events {
+		node.Turn.Apply(e)
+		if e.Type == EventUsage && e.Usage != nil {
+			// Usage events carry the total of the agent call so far
+			total := e.Usage.PromptTokens + e.Usage.CompletionTokens
+			o.useTokens(total - used)
+			used = total
+		}
+		if o.OnEvent != nil {
+			o.OnEvent(node, e)
+		}
+	}
+	if node.Turn.Error != nil {
+		node.Error = node.Turn.Error
+		// Report budget errors rather than the cancellation they caused
+		if cause := context.Cause(o.ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+			node.Error = cause
+		}
+	}
+}
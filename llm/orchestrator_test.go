@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestOrchestratorTrace(t *testing.T) {
+	coordinator := &Agent{
+		Name: "coordinator",
+		LLM: mockDef("echo", `tool-call=ask_agent:{"agent":"helper","prompt":"one"}&tool-call=ask_agent:{"agent":"helper","prompt":"two"}`),
+	}
+	helper := &Agent{
+		Name: "helper",
+		LLM: mockDef("echo", "chunk=1&latency=1ms"),
+	}
+	var lock sync.Mutex
+	started := []string{}
+	ended := []string{}
+	o := &Orchestrator{
+		Coordinator: coordinator,
+		Agents: []*Agent{helper},
+		Budget: Budget{
+			MaxDepth: 1,
+		},
+		OnEnd: func(node *TraceNode) {
+			lock.Lock()
+			defer lock.Unlock()
+			ended = append(ended, node.ID)
+		},
+	}
+	// Walk the trace tree while the run is in progress
+	var rootLock sync.Mutex
+	var root *TraceNode
+	o.OnStart = func(node, parent *TraceNode) {
+		lock.Lock()
+		defer lock.Unlock()
+		started = append(started, node.ID)
+		if parent == nil {
+			rootLock.Lock()
+			root = node
+			rootLock.Unlock()
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			rootLock.Lock()
+			r := root
+			rootLock.Unlock()
+			if r != nil {
+				for _, c := range r.Children() {
+					_ = c.ID
+				}
+			}
+		}
+	}()
+	ret, err := o.Run(context.Background(), "start")
+	<-done
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	children := ret.Children()
+	if len(children) != 2 || children[0].ID != "1.1" || children[1].ID != "1.2" {
+		t.Fatalf("unexpected children %+v", children)
+	}
+	if children[0].Answer() != "one" || children[1].Answer() != "two" {
+		t.Errorf("answers = %q, %q", children[0].Answer(), children[1].Answer())
+	}
+	if len(started) != 3 || len(ended) != 3 || ended[2] != "1" {
+		t.Errorf("started %v, ended %v", started, ended)
+	}
+}
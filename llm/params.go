@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parameters holds the sampling parameters of a completion request. Nil
+// fields are not sent, leaving the provider default in effect. Providers
+// forward the fields their API supports and ignore the others.
+type Parameters struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP *float64 `json:"top_p,omitempty"`
+	TopK *int `json:"top_k,omitempty"`
+	MaxTokens *int `json:"max_tokens,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"`
+	Stop []string `json:"stop,omitempty"`
+	Seed *int `json:"seed,omitempty"`
+}
+
+// Merge returns a copy of the parameters with all fields that are set in o
+// overriding the values of p.
+func (p Parameters) Merge(o Parameters) Parameters {
+	if o.Temperature != nil {
+		p.Temperature = o.Temperature
+	}
+	if o.TopP != nil {
+		p.TopP = o.TopP
+	}
+	if o.TopK != nil {
+		p.TopK = o.TopK
+	}
+	if o.MaxTokens != nil {
+		p.MaxTokens = o.MaxTokens
+	}
+	if o.FrequencyPenalty != nil {
+		p.FrequencyPenalty = o.FrequencyPenalty
+	}
+	if o.PresencePenalty != nil {
+		p.PresencePenalty = o.PresencePenalty
+	}
+	if len(o.Stop) > 0 {
+		p.Stop = o.Stop
+	}
+	if o.Seed != nil {
+		p.Seed = o.Seed
+	}
+	return p
+}
+
+// IsZero returns true if no parameter is set.
+func (p Parameters) IsZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.TopK == nil &&
+		p.MaxTokens == nil && p.FrequencyPenalty == nil &&
+		p.PresencePenalty == nil && len(p.Stop) == 0 && p.Seed == nil
+}
+
+// String returns the JSON encoding of the parameters, or an empty string
+// if none are set.
+func (p Parameters) String() string {
+	if p.IsZero() {
+		return ""
+	}
+	buf, err := json.Marshal(p)
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+// ParseParameters parses parameters from their JSON encoding. An empty
+// string yields no parameters.
+func ParseParameters(s string) (Parameters, error) {
+	var ret Parameters
+	if strings.TrimSpace(s) == "" {
+		return ret, nil
+	}
+	if err := json.Unmarshal([]byte(s), &ret); err != nil {
+		return ret, fmt.Errorf("invalid parameters: %w", err)
+	}
+	return ret, nil
+}
+
+// ParseFloatParameter parses an optional floating point parameter. An empty
+// string yields nil.
+func ParseFloatParameter(s string) (*float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ParseIntParameter parses an optional integer parameter. An empty string
+// yields nil.
+func ParseIntParameter(s string) (*int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Capabilities describes the optional features supported by a Provider.
+type Capabilities struct {
+	// Streaming is true if the provider streams responses as they are
+	// generated rather than all at once.
+	Streaming bool
+	// Images is true if the provider accepts images in prompts.
+	Images bool
+	// ListModels is true if the provider can list the models available.
+	ListModels bool
+	// RequiresEndpoint is true if the LLM definition must name an endpoint.
+	RequiresEndpoint bool
+	// RequiresAPIKey is true if the LLM definition must include an API key.
+	RequiresAPIKey bool
+	// Tools is true if the provider supports tool calling.
+	Tools bool
+}
+
+// Provider implements one chat completion API.
+type Provider interface {
+	// ID returns the unique ID string of the API, such as "openrouter". This
+	// is what is stored in LanguageModel.API.
+	ID() string
+	// Name returns the human-readable name of the API.
+	Name() string
+	// Capabilities returns the optional features supported by the API.
+	Capabilities() Capabilities
+	// ValidateConfig returns a descriptive error if the LLM definition cannot
+	// be used with the API.
+	ValidateConfig(def *LanguageModel) error
+	// StreamCompletion starts a chat completion and returns the channel the
+	// response events are streamed over and a function that cancels the
+	// completion. The channel is closed after the last event. Tools, if any,
+	// are offered to the model.
+	StreamCompletion(def *LanguageModel, system, prompt *Message, context []*Turn, tools []*ToolDefinition) (chan *Event, func(), error)
+	// ListModels returns the names of all models available through the API.
+	ListModels(def *LanguageModel) ([]string, error)
+}
+
+// RequestBuilder is implemented by providers that complete chats with a
+// single HTTP request.
+type RequestBuilder interface {
+	// Request returns the HTTP request StreamCompletion sends for the inputs.
+	Request(def *LanguageModel, system, prompt *Message, context []*Turn, tools []*ToolDefinition) (*Request, error)
+}
+
+var providersLock sync.RWMutex
+var providers = map[string]Provider{}
+
+// RegisterProvider registers a provider by its ID. Registering two providers
+// with the same ID panics.
+func RegisterProvider(p Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	if _, found := providers[p.ID()]; found {
+		panic(fmt.Sprintf("duplicate LLM provider \"%s\"", p.ID()))
+	}
+	providers[p.ID()] = p
+}
+
+// GetProvider returns the provider with the given ID, or nil if there is no
+// such provider.
+func GetProvider(id string) Provider {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	return providers[id]
+}
+
+// Providers returns all registered providers sorted by ID.
+func Providers() []Provider {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	ret := []Provider{}
+	for _, id := range slices.Sorted(maps.Keys(providers)) {
+		ret = append(ret, providers[id])
+	}
+	return ret
+}
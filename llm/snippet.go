@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SnippetKeyVariable is the environment variable exported code reads the API
+// key from.
+const SnippetKeyVariable string = "API_KEY"
+
+// SnippetFormat is a language a request can be exported as.
+type SnippetFormat string
+
+const (
+	SnippetCurl SnippetFormat = "cURL"
+	SnippetGo SnippetFormat = "Go"
+	SnippetPython SnippetFormat = "Python"
+)
+
+// SnippetFormats lists all snippet formats in display order.
+var SnippetFormats = []SnippetFormat{
+	SnippetCurl,
+	SnippetGo,
+	SnippetPython,
+}
+
+// Snippet returns code in the given format that sends the request and prints
+// the response. Occurrences of the API key in the URL and headers are replaced
+// by a read of the SnippetKeyVariable environment variable.
+func (r *Request) Snippet(f SnippetFormat, apiKey string) (string, error) {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r.Body); err != nil {
+		return "", err
+	}
+	s := &snippet{
+		r: r,
+		key: apiKey,
+		body: strings.TrimSpace(body.String()),
+	}
+	switch f {
+	case SnippetCurl:
+		return s.curl(), nil
+	case SnippetGo:
+		return s.golang()
+	case SnippetPython:
+		return s.python(), nil
+	}
+	return "", fmt.Errorf("unknown snippet format \"%s\"", f)
+}
+
+// snippet renders a request as code.
+type snippet struct {
+	r *Request
+	key string
+	body string
+}
+
+// headers returns the names of the request headers, Content-Type first and the
+// rest sorted.
+func (s *snippet) headers() []string {
+	ret := []string{"Content-Type"}
+	for _, name := range slices.Sorted(maps.Keys(s.r.Headers)) {
+		if !strings.EqualFold(name, "Content-Type") {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// header returns the value of the named header.
+func (s *snippet) header(name string) string {
+	if name == "Content-Type" {
+		return "application/json"
+	}
+	return s.r.Headers[name]
+}
+
+// expr returns an expression of the value with each occurrence of the API key
+// replaced by keyExpr. Literal parts are quoted with quote and all parts are
+// joined with sep.
+func (s *snippet) expr(v string, quote func(string) string, keyExpr, sep string) string {
+	if s.key == "" || !strings.Contains(v, s.key) {
+		return quote(v)
+	}
+	parts := []string{}
+	for i, lit := range strings.Split(v, s.key) {
+		if i > 0 {
+			parts = append(parts, keyExpr)
+		}
+		if lit != "" {
+			parts = append(parts, quote(lit))
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// curl returns the request as a cURL command line.
+func (s *snippet) curl() string {
+	quote := func(v string) string {
+		return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+	}
+	key := `"$` + SnippetKeyVariable + `"`
+	var b strings.Builder
+	fmt.Fprintf(&b, "curl -N -X %s %s \\\n", s.r.Method, s.expr(s.r.URL, quote, key, ""))
+	for _, name := range s.headers() {
+		fmt.Fprintf(&b, "  -H %s \\\n", s.expr(name+": "+s.header(name), quote, key, ""))
+	}
+	b.WriteString("  --data-binary @- <<'EOF'\n")
+	b.WriteString(s.body)
+	b.WriteString("\nEOF\n")
+	return b.String()
+}
+
+// golang returns the request as a Go program.
+func (s *snippet) golang() (string, error) {
+	key := `os.Getenv("` + SnippetKeyVariable + `")`
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n\t\"io\"\n\t\"log\"\n\t\"net/http\"\n\t\"os\"\n\t\"strings\"\n)\n\n")
+	// Backquotes cannot appear in raw string literals
+	fmt.Fprintf(&b, "const body = `%s`\n\n", strings.ReplaceAll(s.body, "`", "` + \"`\" + `"))
+	b.WriteString("func main() {\n")
+	fmt.Fprintf(&b, "req, err := http.NewRequest(%s, %s, strings.NewReader(body))\n",
+		strconv.Quote(s.r.Method), s.expr(s.r.URL, strconv.Quote, key, "+"))
+	b.WriteString("if err != nil {\nlog.Fatal(err)\n}\n")
+	for _, name := range s.headers() {
+		fmt.Fprintf(&b, "req.Header.Set(%s, %s)\n", strconv.Quote(name),
+			s.expr(s.header(name), strconv.Quote, key, "+"))
+	}
+	b.WriteString("res, err := http.DefaultClient.Do(req)\nif err != nil {\nlog.Fatal(err)\n}\n")
+	b.WriteString("defer res.Body.Close()\n")
+	b.WriteString("if _, err := io.Copy(os.Stdout, res.Body); err != nil {\nlog.Fatal(err)\n}\n}\n")
+	ret, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(ret), nil
+}
+
+// python returns the request as a Python script using the requests package.
+func (s *snippet) python() string {
+	// JSON string literals are valid Python string literals
+	quote := func(v string) string {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(v)
+		return strings.TrimSpace(buf.String())
+	}
+	key := `os.environ["` + SnippetKeyVariable + `"]`
+	var b strings.Builder
+	b.WriteString("import json\nimport os\n\nimport requests\n\n")
+	fmt.Fprintf(&b, "url = %s\n", s.expr(s.r.URL, quote, key, " + "))
+	b.WriteString("headers = {\n")
+	for _, name := range s.headers() {
+		fmt.Fprintf(&b, "    %s: %s,\n", quote(name), s.expr(s.header(name), quote, key, " + "))
+	}
+	b.WriteString("}\n")
+	// Quotes are always escaped in JSON so they cannot end the raw string
+	fmt.Fprintf(&b, "body = json.loads(r\"\"\"\n%s\n\"\"\")\n\n", s.body)
+	fmt.Fprintf(&b, "with requests.request(%s, url, headers=headers, json=body, stream=True) as res:\n", quote(s.r.Method))
+	b.WriteString("    for line in res.iter_lines(decode_unicode=True):\n")
+	b.WriteString("        print(line)\n")
+	return b.String()
+}
@@ -0,0 +1,209 @@
+package llm
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the snippet tests")
+
+// snippetContent is a message that needs escaping in every snippet format.
+const snippetContent string = "It's \"quoted\" \\ `tick` <b> $HOME\nEOF\n'''"
+
+// snippetRequest returns a request with the API key in the URL and headers
+// and a body that needs escaping.
+func snippetRequest() *Request {
+	return &Request{
+		Method: "POST",
+		URL: "https://api.example.com/v1/chat?key=sk-123&alt=sse",
+		Headers: map[string]string{
+			"Authorization": "Bearer sk-123",
+			"X-Note": "it's \"quoted\"",
+		},
+		Body: map[string]any{
+			"model": "m",
+			"messages": []map[string]string{{
+				"role": "user",
+				"content": snippetContent,
+			}},
+		},
+	}
+}
+
+func TestSnippetGolden(t *testing.T) {
+	for _, tc := range []struct {
+		format SnippetFormat
+		file string
+	}{
+		{SnippetCurl, "snippet-curl.golden"},
+		{SnippetGo, "snippet-go.golden"},
+		{SnippetPython, "snippet-python.golden"},
+	} {
+		got, err := snippetRequest().Snippet(tc.format, "sk-123")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		if strings.Contains(got, "sk-123") {
+			t.Errorf("%s: the API key was not replaced", tc.format)
+		}
+		path := filepath.Join("testdata", tc.file)
+		if *updateGolden {
+			if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+				t.Fatalf("writing %s: %v", path, err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading %s: %v", path, err)
+		}
+		if got != string(want) {
+			t.Errorf("%s snippet differs from %s:\n%s", tc.format, path, got)
+		}
+	}
+	if _, err := snippetRequest().Snippet("Cobol", ""); err == nil {
+		t.Errorf("rendered an unknown format")
+	}
+}
+
+// checkSnippetRun checks the output of a snippet run with stand-ins for the
+// HTTP client, which print the URL, headers and body each on one line.
+func checkSnippetRun(t *testing.T, out []byte) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("unexpected output %q", out)
+	}
+	want := []string{
+		"https://api.example.com/v1/chat?key=sk-live&alt=sse",
+		"Content-Type: application/json",
+		"Authorization: Bearer sk-live",
+		`X-Note: it's "quoted"`,
+	}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d = %q, want %q", i, lines[i], w)
+		}
+	}
+	var body map[string]any
+	if err := json.Unmarshal([]byte(lines[4]), &body); err != nil {
+		t.Fatalf("decoding body %q: %v", lines[4], err)
+	}
+	var wantBody map[string]any
+	buf, _ := json.Marshal(snippetRequest().Body)
+	json.Unmarshal(buf, &wantBody)
+	if !reflect.DeepEqual(body, wantBody) {
+		t.Errorf("body = %v, want %v", body, wantBody)
+	}
+}
+
+func TestSnippetCurlQuoting(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	s, err := snippetRequest().Snippet(SnippetCurl, "sk-123")
+	if err != nil {
+		t.Fatalf("Snippet: %v", err)
+	}
+	// Replace curl with a function printing the URL, headers and body
+	stub := `curl() {
+	while [ $# -gt 0 ]; do
+		case "$1" in
+		-N) ;;
+		-X|--data-binary) shift ;;
+		-H) shift; printf '%s\n' "$1" ;;
+		*) printf '%s\n' "$1" ;;
+		esac
+		shift
+	done
+	tr -d '\n'
+	echo
+}
+`
+	cmd := exec.Command(bash, "-c", stub+s)
+	cmd.Env = append(os.Environ(), SnippetKeyVariable+"=sk-live", "HOME=/nowhere")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("running snippet: %v\n%s", err, out)
+	}
+	checkSnippetRun(t, out)
+}
+
+func TestSnippetPythonQuoting(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	s, err := snippetRequest().Snippet(SnippetPython, "sk-123")
+	if err != nil {
+		t.Fatalf("Snippet: %v", err)
+	}
+	// A stand-in for the requests package printing the URL, headers and body
+	dir := t.TempDir()
+	stub := `import json
+
+class _Response:
+    def __enter__(self):
+        return self
+    def __exit__(self, *args):
+        return False
+    def iter_lines(self, decode_unicode=False):
+        return []
+
+def request(method, url, headers=None, json=None, stream=False):
+    import json as _json
+    print(url)
+    for k, v in headers.items():
+        print(k + ": " + v)
+    print(_json.dumps(json))
+    return _Response()
+`
+	if err := os.WriteFile(filepath.Join(dir, "requests.py"), []byte(stub), 0644); err != nil {
+		t.Fatalf("writing stub: %v", err)
+	}
+	script := filepath.Join(dir, "snippet.py")
+	if err := os.WriteFile(script, []byte(s), 0644); err != nil {
+		t.Fatalf("writing snippet: %v", err)
+	}
+	cmd := exec.Command(python, script)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), SnippetKeyVariable+"=sk-live", "PYTHONPATH="+dir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("running snippet: %v\n%s", err, out)
+	}
+	checkSnippetRun(t, out)
+}
+
+func TestSnippetGoCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiling takes a while")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	s, err := snippetRequest().Snippet(SnippetGo, "sk-123")
+	if err != nil {
+		t.Fatalf("Snippet: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module snippet\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatalf("writing go.mod: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(s), 0644); err != nil {
+		t.Fatalf("writing snippet: %v", err)
+	}
+	cmd := exec.Command(goTool, "vet", ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("compiling snippet: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxStreamLine is the longest line accepted in a streamed response.
+const maxStreamLine int = 4 * 1024 * 1024
+
+// readSSE reads a server-sent event stream from r and calls fn with the event
+// type and data of each event in the stream. If fn returns false reading stops.
+// The event type is the empty string for events without an event field.
+func readSSE(r io.Reader, fn func(event, data string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	event := ""
+	data := []string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// Dispatch the event
+			if len(data) > 0 {
+				if !fn(event, strings.Join(data, "\n")) {
+					return nil
+				}
+			}
+			event = ""
+			data = data[:0]
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Comment line
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// Dispatch any trailing event that was not terminated with a blank line
+	if len(data) > 0 {
+		fn(event, strings.Join(data, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package llm
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Tokens counted for the role and separators of every message, and for every
+// attached image, by all tokenizers.
+const (
+	messageOverheadTokens int = 4
+	imageTokens int = 765
+)
+
+// Tokenizer implementations estimate the number of tokens in text as counted
+// by a family of models.
+type Tokenizer interface {
+	// ID returns the unique ID of the tokenizer.
+	ID() string
+	// Name returns the human-readable name of the tokenizer.
+	Name() string
+	// Available returns true if the tokenizer is able to count. Tokenizers
+	// that need data files which are not installed are not available.
+	Available() bool
+	// Count returns the number of tokens in the text.
+	Count(text string) int
+}
+
+var tokenizerLock sync.Mutex
+var tokenizers = map[string]Tokenizer{}
+
+// RegisterTokenizer registers a tokenizer by its ID.
+func RegisterTokenizer(t Tokenizer) {
+	tokenizerLock.Lock()
+	defer tokenizerLock.Unlock()
+	tokenizers[t.ID()] = t
+}
+
+// GetTokenizer returns the tokenizer with the given ID, or nil if there is no
+// such tokenizer.
+func GetTokenizer(id string) Tokenizer {
+	tokenizerLock.Lock()
+	defer tokenizerLock.Unlock()
+	return tokenizers[id]
+}
+
+// Tokenizers returns all registered tokenizers sorted by ID.
+func Tokenizers() []Tokenizer {
+	tokenizerLock.Lock()
+	defer tokenizerLock.Unlock()
+	ret := []Tokenizer{}
+	for _, t := range tokenizers {
+		ret = append(ret, t)
+	}
+	slices.SortFunc(ret, func(a, b Tokenizer) int {
+		return strings.Compare(a.ID(), b.ID())
+	})
+	return ret
+}
+
+func init() {
+	RegisterTokenizer(heuristicTokenizer{})
+}
+
+// heuristicTokenizer estimates token counts from the number of characters and
+// words, which is close for English text with most vocabularies.
+type heuristicTokenizer struct{}
+
+// ID implements Tokenizer.
+func (t heuristicTokenizer) ID() string { return "heuristic" }
+
+// Name implements Tokenizer.
+func (t heuristicTokenizer) Name() string { return "Heuristic Estimate" }
+
+// Available implements Tokenizer.
+func (t heuristicTokenizer) Available() bool { return true }
+
+// Count implements Tokenizer.
+func (t heuristicTokenizer) Count(text string) int {
+	// About four characters per token, but never less than one token per
+	// word
+	chars := (utf8.RuneCountInString(text) + 3) / 4
+	words := len(strings.Fields(text))
+	return max(chars, words)
+}
+
+// tokenizerModels maps model name prefixes to the IDs of the tokenizers of the
+// models, longer prefixes first.
+var tokenizerModels = []struct {
+	prefix string
+	id string
+}{
+	{"gpt-3.5", "cl100k_base"},
+	{"gpt-4o", "o200k_base"},
+	{"gpt-4.1", "o200k_base"},
+	{"gpt-4.5", "o200k_base"},
+	{"gpt-4", "cl100k_base"},
+	{"gpt-5", "o200k_base"},
+	{"chatgpt-", "o200k_base"},
+	{"o1", "o200k_base"},
+	{"o3", "o200k_base"},
+	{"o4", "o200k_base"},
+	{"text-embedding-3", "cl100k_base"},
+}
+
+// baseModelName returns the model name without provider prefixes such as
+// "openai/" and in lower case.
+func baseModelName(model string) string {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	return model
+}
+
+// TokenizerFor returns the tokenizer that best estimates token counts for the
+// model of the LLM definition. The heuristic tokenizer is returned for unknown
+// models and when the model's tokenizer is not available.
+func TokenizerFor(def *LanguageModel) Tokenizer {
+	model := baseModelName(def.Model)
+	for _, m := range tokenizerModels {
+		if !strings.HasPrefix(model, m.prefix) {
+			continue
+		}
+		if t := GetTokenizer(m.id); t != nil && t.Available() {
+			return t
+		}
+		break
+	}
+	return heuristicTokenizer{}
+}
+
+// CountMessage returns the estimated number of tokens of the message.
+func CountMessage(t Tokenizer, msg *Message) int {
+	if msg == nil {
+		return 0
+	}
+	ret := messageOverheadTokens + t.Count(msg.Content) + len(msg.Images)*imageTokens
+	for _, call := range msg.ToolCalls {
+		ret += t.Count(call.Name) + t.Count(call.Arguments)
+	}
+	return ret
+}
+
+// CountTurn returns the estimated number of tokens the turn adds to the
+// context of later turns. Failed turns are not sent as context and count zero.
+func CountTurn(t Tokenizer, turn *Turn) int {
+	if turn.Failed() {
+		return 0
+	}
+	ret := CountMessage(t, turn.Prompt)
+	for _, msg := range turn.Response {
+		ret += CountMessage(t, msg)
+	}
+	return ret
+}
+
+// CountTurns returns the estimated number of tokens of the turns.
+func CountTurns(t Tokenizer, turns []*Turn) int {
+	ret := 0
+	for _, turn := range turns {
+		ret += CountTurn(t, turn)
+	}
+	return ret
+}
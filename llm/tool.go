@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxToolIterations is the default limit of completions RunTools makes
+// for one prompt.
+const DefaultMaxToolIterations int = 8
+
+// ToolDefinition describes a tool to the model.
+type ToolDefinition struct {
+	Name string
+	Description string
+	// Parameters is the JSON Schema of the argument object.
+	Parameters json.RawMessage
+}
+
+// ToolFunc implements a tool. It is called with the JSON-encoded argument
+// object and returns the result text given to the model.
+type ToolFunc func(ctx context.Context, arguments string) (string, error)
+
+// Tool is a tool implemented in Go that models may call.
+type Tool struct {
+	ToolDefinition
+	Func ToolFunc
+}
+
+// ToolResult is the result of a tool call executed by RunTools.
+type ToolResult struct {
+	// Call is the tool call the result is for.
+	Call *ToolCall
+	// Content is the result text given to the model.
+	Content string
+	// IsError is true if the tool failed, Content then holds the error.
+	IsError bool
+}
+
+var toolsLock sync.RWMutex
+var tools = map[string]*Tool{}
+
+// RegisterTool registers a tool. Registering two tools with the same name
+// panics.
+func RegisterTool(t *Tool) {
+	toolsLock.Lock()
+	defer toolsLock.Unlock()
+	if _, duplicate := tools[t.Name]; duplicate {
+		panic(fmt.Sprintf("duplicate tool \"%s\"", t.Name))
+	}
+	tools[t.Name] = t
+}
+
+// GetTool returns the tool registered with the given name or nil.
+func GetTool(name string) *Tool {
+	toolsLock.RLock()
+	defer toolsLock.RUnlock()
+	return tools[name]
+}
+
+// Tools returns all registered tools sorted by name.
+func Tools() []*Tool {
+	toolsLock.RLock()
+	defer toolsLock.RUnlock()
+	ret := []*Tool{}
+	for _, name := range slices.Sorted(maps.Keys(tools)) {
+		ret = append(ret, tools[name])
+	}
+	return ret
+}
+
+// ToolDefinitions returns the definitions of the tools.
+func ToolDefinitions(tools []*Tool) []*ToolDefinition {
+	ret := []*ToolDefinition{}
+	for _, t := range tools {
+		ret = append(ret, &t.ToolDefinition)
+	}
+	return ret
+}
+
+// callTool executes the tool call with the first matching tool.
+func callTool(ctx context.Context, tools []*Tool, call *ToolCall) *ToolResult {
+	ret := &ToolResult{
+		Call: call,
+	}
+	for _, t := range tools {
+		if t.Name != call.Name {
+			continue
+		}
+		args := call.Arguments
+		if args == "" {
+			args = "{}"
+		}
+		content, err := t.Func(ctx, args)
+		if err != nil {
+			ret.Content = err.Error()
+			ret.IsError = true
+		} else {
+			ret.Content = content
+		}
+		return ret
+	}
+	ret.Content = fmt.Sprintf("unknown tool \"%s\"", call.Name)
+	ret.IsError = true
+	return ret
+}
+
+// RunTools executes a chat completion that may call the given tools. Tool
+// calls requested by the model are executed and their results sent back in
+// another completion until the model responds without calling tools or
+// maxIterations completions have been made. All events of all completions are
+// forwarded on the returned stream along with an EventToolResult event for
+// each executed tool call. Usage events carry the total over all completions.
+func RunTools(def *LanguageModel, system, prompt *Message, chatContext []*Turn, tools []*Tool, maxIterations int) (chan *Event, func(), error) {
+	if len(tools) == 0 {
+		return ChatCompletion(def, system, prompt, chatContext, nil)
+	}
+	p := GetProvider(strings.ToLower(def.API))
+	if p != nil && !p.Capabilities().Tools {
+		return nil, nil, fmt.Errorf("API \"%s\" does not support tool calling", def.API)
+	}
+	if maxIterations < 1 {
+		maxIterations = DefaultMaxToolIterations
+	}
+	defs := ToolDefinitions(tools)
+	events, cancelStep, err := ChatCompletion(def, system, prompt, chatContext, defs)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var stepLock sync.Mutex
+	out := make(chan *Event, 1024)
+	go func() {
+		defer close(out)
+		// turn accumulates the responses of all completions so far to send
+		// as the context of the next
+		turn := &Turn{
+			Prompt: prompt,
+		}
+		total := Usage{}
+		for i := 1; ; i++ {
+			step := Usage{}
+			calls := len(turn.ToolCalls)
+			for e := range events {
+				turn.Apply(e)
+				if e.Type == EventUsage && e.Usage != nil {
+					step = *e.Usage
+					sum := total.Add(step)
+					e = &Event{
+						Type: EventUsage,
+						Usage: &sum,
+					}
+				}
+				out <- e
+			}
+			total = total.Add(step)
+			if turn.Error != nil {
+				return
+			}
+			requested := turn.ToolCalls[calls:]
+			if len(requested) == 0 {
+				return
+			}
+			for _, call := range requested {
+				if ctx.Err() != nil {
+					sendError(out, ctx.Err())
+					return
+				}
+				e := &Event{
+					Type: EventToolResult,
+					ToolResult: callTool(ctx, tools, call),
+				}
+				turn.Apply(e)
+				out <- e
+			}
+			if i >= maxIterations {
+				sendError(out, &Error{
+					Kind: ErrorKindTool,
+					Err: fmt.Errorf("tool call limit of %d completions reached", maxIterations),
+				})
+				return
+			}
+			stepLock.Lock()
+			if ctx.Err() != nil {
+				stepLock.Unlock()
+				sendError(out, ctx.Err())
+				return
+			}
+			events, cancelStep, err = ChatCompletion(def, system, nil, append(slices.Clip(chatContext), turn), defs)
+			stepLock.Unlock()
+			if err != nil {
+				sendError(out, err)
+				return
+			}
+		}
+	}()
+	return out, func() {
+		stepLock.Lock()
+		defer stepLock.Unlock()
+		cancel()
+		cancelStep()
+	}, nil
+}
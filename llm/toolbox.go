@@ -0,0 +1,242 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+func init() {
+	RegisterTool(&Tool{
+		ToolDefinition: ToolDefinition{
+			Name: "current_time",
+			Description: "Returns the current date and time in RFC 3339 format.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"timezone": {
+						"type": "string",
+						"description": "IANA time zone name such as \"America/Chicago\", defaults to the local time zone"
+					}
+				}
+			}`),
+		},
+		Func: currentTime,
+	})
+	RegisterTool(&Tool{
+		ToolDefinition: ToolDefinition{
+			Name: "calculate",
+			Description: "Evaluates an arithmetic expression with + - * / ^ % and parentheses and returns the result.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"expression": {
+						"type": "string",
+						"description": "The expression to evaluate, such as \"(2 + 3) * 4.5\""
+					}
+				},
+				"required": ["expression"]
+			}`),
+		},
+		Func: calculate,
+	})
+}
+
+// currentTime implements the current_time tool.
+func currentTime(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if args.Timezone != "" {
+		loc, err := time.LoadLocation(args.Timezone)
+		if err != nil {
+			return "", err
+		}
+		now = now.In(loc)
+	}
+	return now.Format(time.RFC3339), nil
+}
+
+// calculate implements the calculate tool.
+func calculate(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", err
+	}
+	e := &exprParser{s: args.Expression}
+	v, err := e.parse()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64), nil
+}
+
+// exprParser is a recursive descent parser and evaluator of arithmetic
+// expressions.
+type exprParser struct {
+	s string
+	pos int
+}
+
+// parse evaluates the whole expression.
+func (p *exprParser) parse() (float64, error) {
+	v, err := p.sum()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != 0 {
+		return 0, fmt.Errorf("unexpected \"%c\" at position %d", p.peek(), p.pos+1)
+	}
+	return v, nil
+}
+
+// peek returns the next non-space character or zero at the end.
+func (p *exprParser) peek() byte {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// sum parses terms separated by + and -.
+func (p *exprParser) sum() (float64, error) {
+	v, err := p.product()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return v, nil
+		}
+		p.pos++
+		r, err := p.product()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			v += r
+		} else {
+			v -= r
+		}
+	}
+}
+
+// product parses factors separated by *, / and %.
+func (p *exprParser) product() (float64, error) {
+	v, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return v, nil
+		}
+		p.pos++
+		r, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			v *= r
+		case '/':
+			if r == 0 {
+				return 0, errors.New("division by zero")
+			}
+			v /= r
+		case '%':
+			if r == 0 {
+				return 0, errors.New("division by zero")
+			}
+			v = math.Mod(v, r)
+		}
+	}
+}
+
+// unary parses signs, which bind looser than exponentiation so that -2 ^ 2
+// is -4.
+func (p *exprParser) unary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		v, err := p.unary()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power parses right-associative exponentiation. The exponent may be signed.
+func (p *exprParser) power() (float64, error) {
+	v, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != '^' {
+		return v, nil
+	}
+	p.pos++
+	r, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(v, r), nil
+}
+
+// primary parses parentheses and numbers.
+func (p *exprParser) primary() (float64, error) {
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		v, err := p.sum()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return v, nil
+	case c == 0:
+		return 0, errors.New("unexpected end of expression")
+	}
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == 'e' || c == 'E' {
+			// The exponent may be signed
+			p.pos++
+			if p.pos < len(p.s) && (p.s[p.pos] == '+' || p.s[p.pos] == '-') {
+				p.pos++
+			}
+			continue
+		}
+		if c != '.' && (c < '0' || c > '9') {
+			break
+		}
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, fmt.Errorf("unexpected \"%c\" at position %d", p.s[p.pos], p.pos+1)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(p.s[start:p.pos]), 64)
+}
@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestExprParser(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want float64
+	}{
+		{"1 + 2", 3},
+		{"2 + 3 * 4", 14},
+		{"(2 + 3) * 4", 20},
+		{"10 - 4 - 3", 3},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"(-2) ^ 2", 4},
+		{"2 ^ -1", 0.5},
+		{"-2 ^ -2 ^ 2", -0.0625},
+		{"3 * -2 ^ 2", -12},
+		{"7 % 4", 3},
+		{"7 / 2", 3.5},
+		{"--3", 3},
+		{"+4", 4},
+		{".5 + 1.25", 1.75},
+		{"1e3", 1000},
+		{"1E3", 1000},
+		{"1e-3", 0.001},
+		{"1e+3", 1000},
+		{"2.5e-1 * 4", 1},
+		{"1e-3+1", 1.001},
+		{"3e2-1", 299},
+		{" ( 1 ) ", 1},
+	} {
+		p := &exprParser{s: tc.expr}
+		got, err := p.parse()
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.expr, err)
+			continue
+		}
+		if math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("%q = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestExprParserErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 + 2)",
+		"1 / 0",
+		"5 % 0",
+		"2 * x",
+		"1e",
+		"1e-",
+		"1..2",
+	} {
+		p := &exprParser{s: expr}
+		if v, err := p.parse(); err == nil {
+			t.Errorf("%q: expected an error, got %v", expr, v)
+		}
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	got, err := calculate(context.Background(), `{"expression":"1e-3 * 2"}`)
+	if err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+	if got != "0.002" {
+		t.Errorf("calculate = %q, want %q", got, "0.002")
+	}
+}
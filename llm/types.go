@@ -1,13 +1,6 @@
 package llm
 
-import (
-	"bytes"
-	"encoding/base64"
-	"errors"
-	"image"
-	"image/png"
-	"strings"
-)
+import "time"
 
 // LanguageModel contains all of the data needed to define and communicate with
 // a language model.
@@ -17,74 +10,48 @@ type LanguageModel struct {
 	API string
 	APIEndpoint string
 	APIKey string
+	KeyStorage string // ID of the secret backend the API key is stored with
+	KeyName string // Name the API key is stored under, if the backend uses names
 	Model string
+	Headers map[string]string
+	Parameters Parameters
+	// ContextLength is the context window of the model in tokens, zero to use
+	// the known context length of the model.
+	ContextLength int
+	// InputPrice and OutputPrice are the prices of the model in USD per
+	// million prompt and completion tokens, used to estimate the cost of
+	// completions when the API does not report it. Zero is unknown.
+	InputPrice float64
+	OutputPrice float64
 }
 
-// Image wraps an image.Image for the LLM.
-type Image struct {
-	img image.Image
-	b64 string
+// EstimateCost returns the cost of the usage in USD according to the prices
+// of the LLM definition.
+func (def *LanguageModel) EstimateCost(u *Usage) float64 {
+	return (float64(u.PromptTokens)*def.InputPrice + float64(u.CompletionTokens)*def.OutputPrice) / 1e6
 }
 
-// NewImage returns a new image with the given contents.
-func NewImage(img image.Image) *Image {
-	ret := &Image{}
-	ret.SetImage(img)
-	return ret
+// Usage holds the token counts of a completion as reported by the API.
+type Usage struct {
+	PromptTokens int
+	CompletionTokens int
+	// ReasoningTokens are the completion tokens spent on reasoning.
+	ReasoningTokens int
+	// CachedTokens are the prompt tokens read from the API's prompt cache.
+	CachedTokens int
+	// Cost is the cost of the completion in USD, zero if not known.
+	Cost float64
 }
 
-// NewImageBase64 returns a new image with the given contents.
-func NewImageBase64(s string) *Image {
-	ret := &Image{}
-	ret.SetImageBase64(s)
-	return ret
-}
-
-// MarshalJSON implements json.Marshaler.
-func (l *Image) MarshalJSON() ([]byte, error) {
-	return []byte("\"" + l.b64 + "\""), nil
-}
-
-// UnmarshalJSON implements json.Unmarshaler.
-func (l *Image) UnmarshalJSON(data []byte) error {
-	ld := len(data)
-	if(ld < 2) {
-		return errors.New("invalid string")
-	}
-	l.SetImageBase64(string(data[1:ld-1]))
-	return nil
-}
-
-// SetImageBase64 sets the image data from a Base64-encoded string containing
-// the data of a PNG.
-func (l *Image) SetImageBase64(s string) error {
-	if strings.Contains(s, ",") {
-		parts := strings.Split(s, ",")
-		s = parts[len(parts)-1]
-	}
-	imgData, err := base64.StdEncoding.DecodeString(s)
-	if err != nil {
-		return err
+// Add returns the sum of the usages.
+func (u Usage) Add(o Usage) Usage {
+	return Usage{
+		PromptTokens: u.PromptTokens + o.PromptTokens,
+		CompletionTokens: u.CompletionTokens + o.CompletionTokens,
+		ReasoningTokens: u.ReasoningTokens + o.ReasoningTokens,
+		CachedTokens: u.CachedTokens + o.CachedTokens,
+		Cost: u.Cost + o.Cost,
 	}
-	img, err := png.Decode(bytes.NewReader(imgData))
-	if err != nil {
-		return err
-	}
-	l.b64 = s
-	l.img = img
-	return nil
-}
-
-// SetImage sets the image from a standard image.Image.
-func (l *Image) SetImage(img image.Image) error {
-	var buf bytes.Buffer
-	err := png.Encode(&buf, img)
-	if err != nil {
-		return err
-	}
-	l.b64 = base64.StdEncoding.EncodeToString(buf.Bytes())
-	l.img = img
-	return nil
 }
 
 // Message holds the data of a single LLM message.
@@ -92,13 +59,69 @@ type Message struct {
 	Role string
 	Content string
 	Images []*Image
-	Delta bool
+	// ToolCalls are the tool calls requested by an assistant message.
+	ToolCalls []*ToolCall
+	// ToolCallID is the ID of the tool call a "tool" message is the result
+	// of.
+	ToolCallID string
+	// Name is the name of the tool of a "tool" message.
+	Name string
 }
 
 // Turn holds the data of a complete turn of LLM exchanges.
 type Turn struct {
+	ID int64
+	// ParentID is the ID of the turn this turn continues in a branching chat,
+	// zero for the first turn.
+	ParentID int64
 	Definition LanguageModel
 	System *Message
 	Prompt *Message
 	Response []*Message
+	// Reasoning is the reasoning text streamed with the response, if any.
+	Reasoning string
+	// ToolCalls are the tool calls requested in the response, if any.
+	ToolCalls []*ToolCall
+	// Usage is the token usage reported by the API, if any.
+	Usage *Usage
+	// FinishReason is the reason the response ended as reported by the API.
+	FinishReason string
+	// Model is the model that actually produced the response, if known.
+	Model string
+	// RequestID is the API's ID of the request, if known.
+	RequestID string
+	// Error is the error the turn ended with, if any.
+	Error *Error
+	// Started is the time the request was started. If set, Apply measures
+	// FirstTokenLatency and Latency from it.
+	Started time.Time
+	// FirstTokenLatency is the time from the start of the request to the
+	// first streamed output.
+	FirstTokenLatency time.Duration
+	// Latency is the time from the start of the request to the end of the
+	// response.
+	Latency time.Duration
+	// Exchanges are the captured HTTP requests and responses of the turn.
+	Exchanges []*Exchange
+}
+
+// Failed returns true if the turn ended in an error other than cancellation.
+// Failed turns are not included in the context of later turns.
+func (t *Turn) Failed() bool {
+	return t.Error != nil && t.Error.Kind != ErrorKindCanceled
+}
+
+// Reset clears the response of the turn so it may be completed again.
+func (t *Turn) Reset() {
+	t.Response = nil
+	t.Reasoning = ""
+	t.ToolCalls = nil
+	t.Usage = nil
+	t.FinishReason = ""
+	t.Model = ""
+	t.RequestID = ""
+	t.Error = nil
+	t.FirstTokenLatency = 0
+	t.Latency = 0
+	t.Exchanges = nil
 }
@@ -2,12 +2,18 @@ package main
 
 import (
 	"log"
+	"os"
 
+	"github.com/qbradq/gen-magic/cli"
 	"github.com/qbradq/gen-magic/ui"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Llongfile)
+	// Run headless subcommands without opening a window
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
 	m := ui.NewMain()
 	m.Run()
 }
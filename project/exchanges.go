@@ -0,0 +1,157 @@
+package project
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// saveExchanges replaces the captured HTTP exchanges of the turn.
+func saveExchanges(tx *sql.Tx, turn *llm.Turn) error {
+	if _, err := tx.Exec(`
+		DELETE FROM Exchanges
+		WHERE turn = ?
+		;
+	`, turn.ID); err != nil {
+		return err
+	}
+	for i, x := range turn.Exchanges {
+		requestHeaders, err := json.Marshal(x.RequestHeaders)
+		if err != nil {
+			return err
+		}
+		responseHeaders, err := json.Marshal(x.ResponseHeaders)
+		if err != nil {
+			return err
+		}
+		chunks, err := json.Marshal(x.Chunks)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO Exchanges (
+				turn, seq, method, url, request_headers, request_body,
+				status, response_headers, response_body, chunks, truncated,
+				started_at, dns_us, connect_us, tls_us, first_byte_us,
+				total_us, error_txt
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			;
+		`,
+			turn.ID,
+			i,
+			x.Method,
+			x.URL,
+			string(requestHeaders),
+			x.RequestBody,
+			x.Status,
+			string(responseHeaders),
+			x.ResponseBody,
+			string(chunks),
+			x.Truncated,
+			x.Timing.Start.UTC().Format(time.RFC3339Nano),
+			x.Timing.DNS.Microseconds(),
+			x.Timing.Connect.Microseconds(),
+			x.Timing.TLS.Microseconds(),
+			x.Timing.FirstByte.Microseconds(),
+			x.Timing.Total.Microseconds(),
+			x.Error,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadExchanges loads the captured HTTP exchanges of a turn. Exchanges are not
+// loaded with the turns of sessions as they may be large.
+func (p *Project) LoadExchanges(turn int64) ([]*llm.Exchange, error) {
+	rows, err := p.db.Query(`
+		SELECT
+			IFNULL(method, ''),
+			IFNULL(url, ''),
+			IFNULL(request_headers, ''),
+			IFNULL(request_body, ''),
+			IFNULL(status, 0),
+			IFNULL(response_headers, ''),
+			IFNULL(response_body, ''),
+			IFNULL(chunks, ''),
+			IFNULL(truncated, 0),
+			IFNULL(started_at, ''),
+			IFNULL(dns_us, 0),
+			IFNULL(connect_us, 0),
+			IFNULL(tls_us, 0),
+			IFNULL(first_byte_us, 0),
+			IFNULL(total_us, 0),
+			IFNULL(error_txt, '')
+		FROM Exchanges
+		WHERE turn = ?
+		ORDER BY seq ASC
+		;
+	`, turn)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ret := []*llm.Exchange{}
+	for rows.Next() {
+		x := &llm.Exchange{}
+		var requestHeaders, responseHeaders, chunks, started string
+		var dns, connect, tls, firstByte, total int64
+		if err := rows.Scan(
+			&x.Method,
+			&x.URL,
+			&requestHeaders,
+			&x.RequestBody,
+			&x.Status,
+			&responseHeaders,
+			&x.ResponseBody,
+			&chunks,
+			&x.Truncated,
+			&started,
+			&dns,
+			&connect,
+			&tls,
+			&firstByte,
+			&total,
+			&x.Error,
+		); err != nil {
+			return nil, err
+		}
+		x.RequestHeaders = parseHeaders(requestHeaders)
+		x.ResponseHeaders = parseHeaders(responseHeaders)
+		if chunks != "" {
+			if err := json.Unmarshal([]byte(chunks), &x.Chunks); err != nil {
+				log.Printf("error loading exchange chunks: %v\n", err)
+			}
+		}
+		if started != "" {
+			if x.Timing.Start, err = time.Parse(time.RFC3339Nano, started); err != nil {
+				log.Printf("error loading exchange start time: %v\n", err)
+			}
+		}
+		x.Timing.DNS = time.Duration(dns) * time.Microsecond
+		x.Timing.Connect = time.Duration(connect) * time.Microsecond
+		x.Timing.TLS = time.Duration(tls) * time.Microsecond
+		x.Timing.FirstByte = time.Duration(firstByte) * time.Microsecond
+		x.Timing.Total = time.Duration(total) * time.Microsecond
+		ret = append(ret, x)
+	}
+	return ret, rows.Err()
+}
+
+// parseHeaders parses HTTP headers stored as JSON.
+func parseHeaders(s string) http.Header {
+	ret := http.Header{}
+	if s == "" {
+		return ret
+	}
+	if err := json.Unmarshal([]byte(s), &ret); err != nil {
+		log.Printf("error loading exchange headers: %v\n", err)
+	}
+	return ret
+}
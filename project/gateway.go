@@ -0,0 +1,85 @@
+package project
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// GatewayRequest holds the log entry of a request served by the gateway.
+type GatewayRequest struct {
+	RemoteAddr string
+	// Model is the model name requested by the client.
+	Model string
+	// LLMID and AgentID identify the LLM definition and agent the model name
+	// was mapped to, zero if none.
+	LLMID int64
+	AgentID int64
+	Stream bool
+	// Status is the HTTP status code of the response.
+	Status int
+	// Request is the request body.
+	Request string
+	// Response is the response text.
+	Response string
+	FinishReason string
+	Usage *llm.Usage
+	Latency time.Duration
+	// Error is the error the request failed with, if any.
+	Error string
+}
+
+// LogGatewayRequest adds the request to the log of gateway requests.
+func (p *Project) LogGatewayRequest(r *GatewayRequest) error {
+	var promptTokens, completionTokens sql.NullInt64
+	var cost sql.NullFloat64
+	if r.Usage != nil {
+		promptTokens = sql.NullInt64{Int64: int64(r.Usage.PromptTokens), Valid: true}
+		completionTokens = sql.NullInt64{Int64: int64(r.Usage.CompletionTokens), Valid: true}
+		cost = sql.NullFloat64{Float64: r.Usage.Cost, Valid: true}
+	}
+	var llmID, agentID sql.NullInt64
+	if r.LLMID != 0 {
+		llmID = sql.NullInt64{Int64: r.LLMID, Valid: true}
+	}
+	if r.AgentID != 0 {
+		agentID = sql.NullInt64{Int64: r.AgentID, Valid: true}
+	}
+	_, err := p.db.Exec(`
+		INSERT INTO GatewayRequests (
+			remote_addr,
+			model,
+			llm,
+			agent,
+			stream,
+			status,
+			request,
+			response,
+			finish_reason,
+			prompt_tokens,
+			completion_tokens,
+			cost,
+			latency_ms,
+			error_txt
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		;
+	`,
+		r.RemoteAddr,
+		r.Model,
+		llmID,
+		agentID,
+		r.Stream,
+		r.Status,
+		r.Request,
+		r.Response,
+		r.FinishReason,
+		promptTokens,
+		completionTokens,
+		cost,
+		r.Latency.Milliseconds(),
+		r.Error,
+	)
+	return err
+}
@@ -0,0 +1,159 @@
+package project
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qbradq/gen-magic/data"
+)
+
+// migration is one numbered schema migration.
+type migration struct {
+	Version int
+	Name string
+	SQL string
+}
+
+// loadMigrations loads all embedded migrations sorted by version. Migration
+// files are named with the version number followed by a dash and a name, like
+// "0002-add-things.sql".
+func loadMigrations() ([]migration, error) {
+	files, err := fs.Glob(data.Migrations, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	ret := []migration{}
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".sql")
+		n, _, _ := strings.Cut(name, "-")
+		v, err := strconv.Atoi(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name \"%s\"", file)
+		}
+		buf, err := data.Migrations.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, migration{
+			Version: v,
+			Name: name,
+			SQL: string(buf),
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Version < ret[j].Version
+	})
+	for i, m := range ret {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %s is out of sequence", m.Name)
+		}
+	}
+	return ret, nil
+}
+
+// schemaVersion returns the schema version of the project database and true
+// if the database has any tables at all.
+func (p *Project) schemaVersion() (int, bool, error) {
+	var tables int
+	row := p.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		;
+	`)
+	if err := row.Scan(&tables); err != nil {
+		return 0, false, err
+	}
+	if _, err := p.db.Exec(`
+		CREATE TABLE IF NOT EXISTS SchemaVersion (
+			version INTEGER PRIMARY KEY NOT NULL,
+			name_txt VARCHAR(64),
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`); err != nil {
+		return 0, false, err
+	}
+	var v int
+	row = p.db.QueryRow(`
+		SELECT IFNULL(MAX(version), 0)
+		FROM SchemaVersion
+		;
+	`)
+	if err := row.Scan(&v); err != nil {
+		return 0, false, err
+	}
+	return v, tables > 0, nil
+}
+
+// migrate brings the project database up to the latest schema version. If
+// the database already holds data it is backed up next to the project file
+// first. Projects with a schema newer than this build are refused.
+func (p *Project) migrate(source string) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	latest := len(migrations)
+	current, populated, err := p.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > latest {
+		return fmt.Errorf("project schema version %d is newer than this version of Gen Magic supports (%d), please upgrade", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+	if populated {
+		if err := p.backup(source, current); err != nil {
+			return fmt.Errorf("error backing up project before migration: %w", err)
+		}
+	}
+	for _, m := range migrations[current:] {
+		if err := p.applyMigration(m); err != nil {
+			return fmt.Errorf("error applying migration %s: %w", m.Name, err)
+		}
+		log.Printf("applied project migration %s\n", m.Name)
+	}
+	return nil
+}
+
+// applyMigration applies one migration in a transaction.
+func (p *Project) applyMigration(m migration) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO SchemaVersion (version, name_txt)
+		VALUES (?, ?)
+		;
+	`, m.Version, m.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// backup writes a copy of the project database next to the project file.
+func (p *Project) backup(source string, version int) error {
+	dest := fmt.Sprintf("%s.v%d-%s.bak", source, version, time.Now().Format("20060102-150405"))
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup file %s already exists", dest)
+	}
+	if _, err := p.db.Exec(`VACUUM INTO ?;`, dest); err != nil {
+		return err
+	}
+	log.Printf("backed up project to %s\n", dest)
+	return nil
+}
@@ -0,0 +1,213 @@
+package project
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// baselineSchema is the schema of project files from before schema
+// versioning.
+const baselineSchema string = `
+CREATE TABLE IF NOT EXISTS Settings (
+    id TEXT PRIMARY KEY NOT NULL,
+    val TEXT
+);
+CREATE TABLE IF NOT EXISTS APIs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_str VARCHAR(32) UNIQUE NOT NULL,
+    name_txt VARCHAR(64) NOT NULL
+);
+CREATE TABLE IF NOT EXISTS LLMs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name_txt VARCHAR(64),
+    api INTEGER,
+    uri VARCHAR(255),
+    api_key VARCHAR(255),
+    model VARCHAR(255),
+    FOREIGN KEY (api) REFERENCES APIs(id)
+);
+CREATE TABLE IF NOT EXISTS Agents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name_txt VARCHAR(64),
+    llm INTEGER,
+    sys_prompt TEXT,
+    FOREIGN KEY (llm) REFERENCES LLMs(id)
+);
+INSERT INTO APIs (id_str, name_txt) VALUES ('openai-compatible', 'OpenAI Compatible');
+INSERT INTO LLMs (name_txt, api, uri, api_key, model) VALUES ('Old LLM', 1, 'http://localhost', 'sk-old', 'old-model');
+INSERT INTO Agents (name_txt, llm, sys_prompt) VALUES ('Old Agent', 1, 'Be old.');
+INSERT INTO Settings (id, val) VALUES ('init.static-data-load.base', 'true');
+`
+
+// loadProject loads the project database at path.
+func loadProject(t *testing.T, path string) *Project {
+	t.Helper()
+	p := &Project{}
+	if err := p.Load("sqlite", path); err != nil {
+		t.Fatalf("loading project: %v", err)
+	}
+	t.Cleanup(func() {
+		p.Close()
+	})
+	return p
+}
+
+// schemaSnapshot returns the columns of every table of the database and the
+// recorded migrations.
+func schemaSnapshot(t *testing.T, db *sql.DB) map[string][]string {
+	t.Helper()
+	ret := map[string][]string{}
+	rows, err := db.Query(`
+		SELECT name
+		FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		;
+	`)
+	if err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("listing tables: %v", err)
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	for _, table := range tables {
+		rows, err := db.Query(`SELECT name FROM pragma_table_info(?);`, table)
+		if err != nil {
+			t.Fatalf("listing columns of %s: %v", table, err)
+		}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatalf("listing columns of %s: %v", table, err)
+			}
+			ret[table] = append(ret[table], name)
+		}
+		rows.Close()
+	}
+	rows, err = db.Query(`SELECT version, name_txt FROM SchemaVersion ORDER BY version;`)
+	if err != nil {
+		t.Fatalf("listing migrations: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v int
+		var name string
+		if err := rows.Scan(&v, &name); err != nil {
+			t.Fatalf("listing migrations: %v", err)
+		}
+		ret["migrations"] = append(ret["migrations"], name)
+	}
+	return ret
+}
+
+// backups returns the backup files of the project database at path.
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	ret, err := filepath.Glob(path + ".v*.bak")
+	if err != nil {
+		t.Fatalf("listing backups: %v", err)
+	}
+	return ret
+}
+
+// checkMigrated checks that the project is at the latest schema version and
+// that migrating it again changes nothing.
+func checkMigrated(t *testing.T, p *Project, path string) map[string][]string {
+	t.Helper()
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	v, populated, err := p.schemaVersion()
+	if err != nil || v != len(migrations) || !populated {
+		t.Fatalf("schema version = %d %v %v, want %d", v, populated, err, len(migrations))
+	}
+	before := schemaSnapshot(t, p.db)
+	if len(before["migrations"]) != len(migrations) || before["migrations"][0] != "0001-base" {
+		t.Errorf("recorded migrations %v", before["migrations"])
+	}
+	backed := backups(t, path)
+	if err := p.migrate(path); err != nil {
+		t.Fatalf("migrating again: %v", err)
+	}
+	if after := schemaSnapshot(t, p.db); !reflect.DeepEqual(before, after) {
+		t.Errorf("migrating again changed the schema from %v to %v", before, after)
+	}
+	if again := backups(t, path); len(again) != len(backed) {
+		t.Errorf("migrating again made backups %v", again)
+	}
+	return before
+}
+
+func TestMigrateEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.gen-magic")
+	p := loadProject(t, path)
+	checkMigrated(t, p, path)
+	// Empty databases have nothing to back up
+	if b := backups(t, path); len(b) != 0 {
+		t.Errorf("unexpected backups %v", b)
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "baseline.gen-magic")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatalf("creating baseline schema: %v", err)
+	}
+	db.Close()
+	p := loadProject(t, path)
+	got := checkMigrated(t, p, path)
+	// The schema matches that of a new project
+	emptyPath := filepath.Join(dir, "empty.gen-magic")
+	want := schemaSnapshot(t, loadProject(t, emptyPath).db)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("migrated schema %v, want %v", got, want)
+	}
+	// The data is kept
+	def, err := p.FindLLM("Old LLM")
+	if err != nil || def.Model != "old-model" || def.APIKey != "sk-old" || def.API != "openai-compatible" {
+		t.Errorf("migrated LLM = %+v, %v", def, err)
+	}
+	if agent, err := p.FindAgent("Old Agent"); err != nil || agent.System.Content != "Be old." {
+		t.Errorf("migrated agent = %+v, %v", agent, err)
+	}
+	// The database was backed up before migrating
+	b := backups(t, path)
+	if len(b) != 1 || !strings.HasPrefix(filepath.Base(b[0]), "baseline.gen-magic.v0-") {
+		t.Fatalf("backups = %v", b)
+	}
+	backup, err := sql.Open("sqlite", b[0])
+	if err != nil {
+		t.Fatalf("opening backup: %v", err)
+	}
+	defer backup.Close()
+	var name string
+	if err := backup.QueryRow(`SELECT name_txt FROM LLMs;`).Scan(&name); err != nil || name != "Old LLM" {
+		t.Errorf("backed up LLM = %q, %v", name, err)
+	}
+}
+
+func TestMigrateNewer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "newer.gen-magic")
+	p := loadProject(t, path)
+	if _, err := p.db.Exec(`INSERT INTO SchemaVersion (version, name_txt) VALUES (999, '0999-future');`); err != nil {
+		t.Fatalf("recording future migration: %v", err)
+	}
+	p.Close()
+	if err := (&Project{}).Load("sqlite", path); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("loading a newer project = %v", err)
+	}
+}
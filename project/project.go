@@ -0,0 +1,721 @@
+package project
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/qbradq/gen-magic/data"
+	"github.com/qbradq/gen-magic/llm"
+	"github.com/qbradq/gen-magic/secrets"
+	_ "modernc.org/sqlite"
+)
+
+// ErrNotFound is wrapped by the errors of lookups that found nothing.
+var ErrNotFound = errors.New("not found")
+
+// Project holds all of the data for a project.
+type Project struct {
+	db *sql.DB
+	name string
+}
+
+// WithDB executes a method with the database.
+
+// sqlitePragmas are added to SQLite data sources so that concurrent writers,
+// such as the gateway logging requests, wait for each other instead of failing
+// with SQLITE_BUSY. The rollback journal is used so project files stay single
+// files that can be copied and shared, which also reverts files that were
+// switched to WAL mode.
+const sqlitePragmas string = "_pragma=busy_timeout(5000)&_pragma=journal_mode(DELETE)"
+
+// Connect connects to a data source, completely replacing any existing data.
+func (p *Project) Load(driver, source string) error {
+	var err error
+	dsn := source
+	if driver == "sqlite" {
+		if strings.Contains(dsn, "?") {
+			dsn += "&" + sqlitePragmas
+		} else {
+			dsn += "?" + sqlitePragmas
+		}
+	}
+	p.db, err = sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	if err := p.dbInit(source); err != nil {
+		p.db.Close()
+		p.db = nil
+		return err
+	}
+	p.name = strings.TrimSuffix(filepath.Base(source), ".gen-magic")
+	return nil
+}
+
+// Name returns the name of the project, which is its file name without the
+// extension.
+func (p *Project) Name() string {
+	return p.name
+}
+
+// Close closes the data source.
+func (p *Project) Close() error {
+	if p.db != nil {
+		if err := p.db.Close(); err != nil {
+			return err
+		}
+		p.db = nil
+	}
+	return nil
+}
+
+// dbInit initializes the database.
+func (p *Project) dbInit(source string) error {
+	var err error
+	// Bring the schema up to date
+	if err = p.migrate(source); err != nil {
+		log.Printf("error migrating schema: %v\n", err)
+		return err
+	}
+	// Make sure all registered APIs are present
+	if err = p.syncAPIs(); err != nil {
+		log.Printf("error synchronizing APIs: %v\n", err)
+		return err
+	}
+	// Lay down base data if needed
+	if !p.BoolSetting("init.static-data-load.base", false) {
+		if _, err := p.db.Exec(data.StaticDataSQL); err != nil {
+			log.Printf("error running data script: %v\n", err)
+			return err
+		}
+		p.SetBoolSetting("init.static-data-load.base", true)
+	}
+	return nil
+}
+
+// StringSetting returns the given setting as a string or the default value.
+func (p *Project) StringSetting(key, dv string) (ret string) {
+	row := p.db.QueryRow(`
+		SELECT
+			IFNULL(val, '') AS val
+		FROM Settings
+		WHERE id = ?
+		;
+	`, key)
+	if err := row.Scan(&ret); err != nil {
+		return dv
+	}
+	return ret
+}
+
+// SetStringSetting sets the given setting from a string value.
+func (p *Project) SetStringSetting(key, v string) error {
+	_, err := p.db.Exec(`
+		INSERT INTO Settings(id, val)
+		VALUES (?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			val = ?
+		;
+	`, key, v, v)
+	return err
+}
+
+// IntSetting returns the given setting as an int or the default value.
+func (p *Project) IntSetting(key string, dv int) int {
+	var s string
+	row := p.db.QueryRow(`
+		SELECT
+			IFNULL(val, '') AS val
+		FROM Settings
+		WHERE id = ?
+		;
+	`, key)
+	if err := row.Scan(&s); err != nil {
+		return dv
+	}
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return dv
+	}
+	return int(v)
+}
+
+// SetIntSetting sets the given setting from an int value.
+func (p *Project) SetIntSetting(key string, v int) error {
+	return p.SetStringSetting(key, strconv.FormatInt(int64(v), 10))
+}
+
+// BoolSetting returns the given setting as a boolean or the default value.
+func (p *Project) BoolSetting(key string, dv bool) bool {
+	var s string
+	row := p.db.QueryRow(`
+		SELECT
+			IFNULL(val, '') AS val
+		FROM Settings
+		WHERE id = ?
+		;
+	`, key)
+	if err := row.Scan(&s); err != nil {
+		return dv
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return dv
+	}
+	return v
+}
+
+// SetBoolSetting sets the given setting from a boolean value.
+func (p *Project) SetBoolSetting(key string, v bool) error {
+	return p.SetStringSetting(key, strconv.FormatBool(v))
+}
+// LLMApi wraps the information for one LLM API.
+type LLMApi struct {
+	ID string
+	Name string
+}
+
+// syncAPIs inserts or updates a row in the APIs table for every registered
+// LLM provider.
+func (p *Project) syncAPIs() error {
+	for _, provider := range llm.Providers() {
+		_, err := p.db.Exec(`
+			INSERT INTO APIs (id_str, name_txt)
+			VALUES (?, ?)
+			ON CONFLICT(id_str) DO UPDATE SET
+				name_txt = ?
+			;
+		`, provider.ID(), provider.Name(), provider.Name())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListAPIs lists all APIs available.
+func (p *Project) ListAPIs() []LLMApi {
+	ret := []LLMApi{}
+	for _, provider := range llm.Providers() {
+		ret = append(ret, LLMApi{
+			ID: provider.ID(),
+			Name: provider.Name(),
+		})
+	}
+	return ret
+}
+
+// LLMName names an LLM definition.
+type LLMName struct {
+	ID int64
+	Name string
+}
+
+// ListLLMs lists all LLM definitions.
+func (p *Project) ListLLMs() []LLMName {
+	ret := []LLMName{}
+	rows, err := p.db.Query(`
+		SELECT
+			id,
+			name_txt
+		FROM LLMs
+		ORDER BY id ASC
+		;
+	`)
+	if err != nil {
+		log.Printf("error listing LLMs (query): %v\n", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		n := LLMName{}
+		if err := rows.Scan(&n.ID, &n.Name); err != nil {
+			log.Printf("error listing LLMs (scan): %v\n", err)
+			return nil
+		}
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+// GetLLM returns an LLM definition from the project.
+func (p *Project) GetLLM(id int64) *llm.LanguageModel {
+	ret, err := p.getLLM(id)
+	if err != nil {
+		log.Fatalf("error getting LLM: %v\n", err)
+	}
+	return ret
+}
+
+// getLLM returns an LLM definition by ID, wrapping ErrNotFound if there is
+// none.
+func (p *Project) getLLM(id int64) (*llm.LanguageModel, error) {
+	row := p.db.QueryRow(`
+		SELECT
+			LLMs.id,
+			IFNULL(LLMs.name_txt, '') AS name_txt,
+			IFNULL(APIs.id_str, '') AS id_str,
+			IFNULL(LLMs.uri, '') AS uir,
+			IFNULL(LLMs.api_key, '') AS api_key,
+			IFNULL(LLMs.model, '') AS model,
+			IFNULL(LLMs.params, '') AS params,
+			IFNULL(LLMs.context_length, 0) AS context_length,
+			IFNULL(LLMs.input_price, 0) AS input_price,
+			IFNULL(LLMs.output_price, 0) AS output_price
+		FROM LLMs
+		INNER JOIN APIs ON LLMs.api = APIs.id
+		WHERE LLMs.id = ?
+		;
+	`, id)
+	ret := &llm.LanguageModel{}
+	var stored, params string
+	err := row.Scan(&ret.ID, &ret.Name, &ret.API, &ret.APIEndpoint, &stored, &ret.Model, &params, &ret.ContextLength, &ret.InputPrice, &ret.OutputPrice)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("LLM %d %w", id, ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	if ret.Parameters, err = llm.ParseParameters(params); err != nil {
+		log.Printf("error getting LLM parameters: %v\n", err)
+	}
+	// Resolve the API key through the secret backend it was stored with
+	b, name := secrets.Detect(stored)
+	ret.KeyStorage = b.ID()
+	ret.KeyName = name
+	if ret.APIKey, err = b.Resolve(stored); err != nil {
+		log.Printf("error resolving API key of LLM \"%s\": %v\n", ret.Name, err)
+	}
+	ret.Headers = p.getLLMHeaders(id)
+	return ret, nil
+}
+
+// FindLLM returns the LLM definition with the given name, wrapping ErrNotFound
+// if there is none.
+func (p *Project) FindLLM(name string) (*llm.LanguageModel, error) {
+	row := p.db.QueryRow(`
+		SELECT id
+		FROM LLMs
+		WHERE name_txt = ?
+		ORDER BY id ASC
+		LIMIT 1
+		;
+	`, name)
+	var id int64
+	if err := row.Scan(&id); errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("LLM \"%s\" %w", name, ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	return p.getLLM(id)
+}
+
+// storedAPIKey returns the API key of an LLM definition as it is stored in the
+// project.
+func (p *Project) storedAPIKey(id int64) (ret string) {
+	row := p.db.QueryRow(`
+		SELECT
+			IFNULL(api_key, '') AS api_key
+		FROM LLMs
+		WHERE id = ?
+		;
+	`, id)
+	if err := row.Scan(&ret); err != nil {
+		return ""
+	}
+	return ret
+}
+
+// storeAPIKey returns the value to store in the project for the API key of
+// the LLM definition. A stored key that cannot be resolved, for instance
+// because of a wrong passphrase, is kept unless a new key was entered.
+func (p *Project) storeAPIKey(def *llm.LanguageModel) (string, error) {
+	b := secrets.Get(def.KeyStorage)
+	if def.APIKey == "" {
+		current := p.storedAPIKey(def.ID)
+		cb, _ := secrets.Detect(current)
+		if _, err := cb.Resolve(current); err != nil && cb.ID() == b.ID() {
+			return current, nil
+		}
+	}
+	return b.Store(def.KeyName, def.APIKey)
+}
+
+// HasEncryptedKeys returns true if any LLM definition of the project has an
+// encrypted API key.
+func (p *Project) HasEncryptedKeys() bool {
+	row := p.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM LLMs
+		WHERE api_key LIKE 'enc:%'
+		;
+	`)
+	var n int
+	if err := row.Scan(&n); err != nil {
+		log.Printf("error counting encrypted keys (scan): %v\n", err)
+		return false
+	}
+	return n > 0
+}
+
+// CheckPassphrase returns an error if the encrypted API keys of the project
+// cannot be decrypted with the current passphrase.
+func (p *Project) CheckPassphrase() error {
+	row := p.db.QueryRow(`
+		SELECT api_key
+		FROM LLMs
+		WHERE api_key LIKE 'enc:%'
+		LIMIT 1
+		;
+	`)
+	var stored string
+	if err := row.Scan(&stored); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	_, err := secrets.Resolve(stored)
+	return err
+}
+
+// getLLMHeaders returns the custom HTTP headers of an LLM definition.
+func (p *Project) getLLMHeaders(id int64) map[string]string {
+	ret := map[string]string{}
+	rows, err := p.db.Query(`
+		SELECT
+			name_txt,
+			IFNULL(val, '') AS val
+		FROM LLMHeaders
+		WHERE llm = ?
+		;
+	`, id)
+	if err != nil {
+		log.Printf("error getting LLM headers (query): %v\n", err)
+		return ret
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			log.Printf("error getting LLM headers (scan): %v\n", err)
+			return ret
+		}
+		ret[k] = v
+	}
+	return ret
+}
+
+// setLLMHeaders replaces the custom HTTP headers of an LLM definition.
+func (p *Project) setLLMHeaders(id int64, headers map[string]string) error {
+	if _, err := p.db.Exec(`
+		DELETE FROM LLMHeaders
+		WHERE llm = ?
+		;
+	`, id); err != nil {
+		return err
+	}
+	for k, v := range headers {
+		if _, err := p.db.Exec(`
+			INSERT INTO LLMHeaders (llm, name_txt, val)
+			VALUES (?, ?, ?)
+			;
+		`, id, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetLLM stores an LLM definition in the project.
+func (p *Project) SetLLM(def *llm.LanguageModel) error {
+	key, err := p.storeAPIKey(def)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec(`
+		UPDATE LLMs
+		SET
+			name_txt = ?,
+			api = (SELECT id FROM APIs WHERE id_str = ?),
+			uri = ?,
+			api_key = ?,
+			model = ?,
+			params = ?,
+			context_length = ?,
+			input_price = ?,
+			output_price = ?
+		WHERE
+			id = ?
+		;
+	`, def.Name, def.API, def.APIEndpoint, key, def.Model, def.Parameters.String(), def.ContextLength, def.InputPrice, def.OutputPrice, def.ID)
+	if err != nil {
+		return err
+	}
+	return p.setLLMHeaders(def.ID, def.Headers)
+}
+
+// NewLLM returns a newly allocated LLM with a database ID.
+func (p *Project) NewLLM() *llm.LanguageModel {
+	ret := &llm.LanguageModel{
+		Name: "Un-named LLM",
+		API: "openrouter",
+		APIEndpoint: "https://openrouter.ai/api/v1",
+		KeyStorage: "plain",
+		Model: "meta-llama/llama-3.3-70b-instruct:free",
+	}
+	res, err := p.db.Exec(`
+		INSERT INTO LLMs (name_txt, api, uri, model)
+		VALUES (
+			?,
+			(SELECT id FROM APIs WHERE id_str = ?),
+			?,
+			?
+		);
+	`, ret.Name, ret.API, ret.APIEndpoint, ret.Model)
+	if err != nil {
+		log.Fatalf("error inserting new LLM definition: %v\n", err)
+	}
+	ret.ID, err = res.LastInsertId()
+	if err != nil {
+		log.Fatalf("error inserting new LLM definition ID: %v\n", err)
+	}
+	return ret
+}
+
+// DeleteLLM deletes the given LLM. LLMs used by agents are not deleted.
+func (p *Project) DeleteLLM(def *llm.LanguageModel) error {
+	rows, err := p.db.Query(`
+		SELECT IFNULL(name_txt, '')
+		FROM Agents
+		WHERE llm = ?
+		ORDER BY id ASC
+		;
+	`, def.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	agents := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		agents = append(agents, "\""+name+"\"")
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(agents) > 0 {
+		return fmt.Errorf("the LLM \"%s\" is used by the agents %s", def.Name, strings.Join(agents, ", "))
+	}
+	if err := p.setLLMHeaders(def.ID, nil); err != nil {
+		return err
+	}
+	_, err = p.db.Exec(`
+		DELETE FROM LLMs
+		WHERE id = ?
+		;
+	`, def.ID)
+	return err
+}
+
+// AgentName identifies an agent.
+type AgentName struct {
+	ID int64
+	Name string
+}
+
+// ListAgents lists all agent definitions.
+func (p *Project) ListAgents() []AgentName {
+	ret := []AgentName{}
+	rows, err := p.db.Query(`
+		SELECT id, name_txt
+		FROM Agents
+		;
+	`)
+	if err != nil {
+		log.Fatalf("error listing agents (select): %v\n", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		name := AgentName{}
+		if err := rows.Scan(&name.ID, &name.Name); err != nil {
+			log.Fatalf("error listing agents (scan): %v\n", err)
+		}
+		ret = append(ret, name)
+	}
+	return ret
+}
+
+// GetAgent returns an agent by ID.
+func (p *Project) GetAgent(id int64) *llm.Agent {
+	ret, err := p.getAgent(id)
+	if err != nil {
+		log.Fatalf("error getting agent: %v\n", err)
+	}
+	return ret
+}
+
+// getAgent returns an agent by ID, wrapping ErrNotFound if there is none.
+func (p *Project) getAgent(id int64) (*llm.Agent, error) {
+	ret := &llm.Agent{
+		ID: id,
+		System: llm.Message{
+			Role: "system",
+		},
+	}
+	row := p.db.QueryRow(`
+		SELECT name_txt, llm, sys_prompt
+		FROM Agents
+		WHERE id = ?
+		;
+	`, id)
+	var llmID int64
+	err := row.Scan(&ret.Name, &llmID, &ret.System.Content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("agent %d %w", id, ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	// A missing LLM is an error of the agent rather than a failed lookup
+	if ret.LLM, err = p.getLLM(llmID); err != nil {
+		return nil, fmt.Errorf("error getting the LLM of agent \"%s\": %v", ret.Name, err)
+	}
+	ret.Variables = p.getAgentVariables(id)
+	return ret, nil
+}
+
+// getAgentVariables returns the custom template variables of an agent.
+func (p *Project) getAgentVariables(id int64) map[string]string {
+	ret := map[string]string{}
+	rows, err := p.db.Query(`
+		SELECT
+			name_txt,
+			IFNULL(val, '') AS val
+		FROM AgentVariables
+		WHERE agent = ?
+		;
+	`, id)
+	if err != nil {
+		log.Printf("error getting agent variables (query): %v\n", err)
+		return ret
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			log.Printf("error getting agent variables (scan): %v\n", err)
+			return ret
+		}
+		ret[k] = v
+	}
+	return ret
+}
+
+// setAgentVariables replaces the custom template variables of an agent.
+func (p *Project) setAgentVariables(id int64, vars map[string]string) error {
+	if _, err := p.db.Exec(`
+		DELETE FROM AgentVariables
+		WHERE agent = ?
+		;
+	`, id); err != nil {
+		return err
+	}
+	for k, v := range vars {
+		if _, err := p.db.Exec(`
+			INSERT INTO AgentVariables (agent, name_txt, val)
+			VALUES (?, ?, ?)
+			;
+		`, id, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindAgent returns the agent with the given name, wrapping ErrNotFound if
+// there is none.
+func (p *Project) FindAgent(name string) (*llm.Agent, error) {
+	row := p.db.QueryRow(`
+		SELECT id
+		FROM Agents
+		WHERE name_txt = ?
+		ORDER BY id ASC
+		LIMIT 1
+		;
+	`, name)
+	var id int64
+	if err := row.Scan(&id); errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("agent \"%s\" %w", name, ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	return p.getAgent(id)
+}
+
+// SetAgent sets the agent's information.
+func (p *Project) SetAgent(agent *llm.Agent) {
+	_, err := p.db.Exec(`
+		UPDATE Agents
+		SET
+			name_txt = ?,
+			llm = ?,
+			sys_prompt = ?
+		WHERE
+			id = ?
+		;
+	`, agent.Name, agent.LLM.ID, agent.System.Content, agent.ID)
+	if err != nil {
+		log.Fatalf("error setting agent (update): %v\n", err)
+	}
+	if err := p.setAgentVariables(agent.ID, agent.Variables); err != nil {
+		log.Fatalf("error setting agent variables: %v\n", err)
+	}
+}
+
+// NewAgent returns a new Agent object.
+func (p *Project) NewAgent() *llm.Agent {
+	llms := p.ListLLMs()
+	ret := &llm.Agent{
+		Name: "Unnamed Agent",
+		LLM: p.GetLLM(llms[0].ID),
+		System: llm.Message{
+			Role: "system",
+			Content: "You are a helpful AI assistant.",
+		},
+	}
+	res, err := p.db.Exec(`
+		INSERT INTO Agents (name_txt, llm, sys_prompt)
+		VALUES (?, ?, ?)
+		;
+	`, ret.Name, ret.LLM.ID, ret.System.Content)
+	if err != nil {
+		log.Fatalf("error creating new agent (insert): %v\n", err)
+	}
+	ret.ID, err = res.LastInsertId()
+	if err != nil {
+		log.Fatalf("error creating new agent (last_id): %v\n", err)
+	}
+	return ret
+}
+
+// DeleteAgent deletes the given agent.
+func (p *Project) DeleteAgent(agent *llm.Agent) {
+	_, err := p.db.Exec(`
+		DELETE FROM Agents
+		WHERE id = ?
+		;
+	`, agent.ID)
+	if err != nil {
+		log.Fatalf("error deleting agent (delete): %v\n", err)
+	}
+	if err := p.setAgentVariables(agent.ID, nil); err != nil {
+		log.Fatalf("error deleting agent variables: %v\n", err)
+	}
+}
@@ -0,0 +1,108 @@
+package project
+
+import "strings"
+
+// Prompt is a reusable prompt of the prompt library. The body is a
+// text/template with {{variable}} placeholders, see RenderTemplate.
+type Prompt struct {
+	ID int64
+	Name string
+	Description string
+	Tags []string
+	Body string
+}
+
+// Matches returns true if the name, description or one of the tags of the
+// prompt contains the query, ignoring case.
+func (r *Prompt) Matches(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+	for _, s := range append([]string{r.Name, r.Description}, r.Tags...) {
+		if strings.Contains(strings.ToLower(s), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListPrompts returns all prompts of the library sorted by name.
+func (p *Project) ListPrompts() ([]*Prompt, error) {
+	rows, err := p.db.Query(`
+		SELECT
+			id,
+			IFNULL(name_txt, ''),
+			IFNULL(description, ''),
+			IFNULL(tags, ''),
+			IFNULL(body, '')
+		FROM Prompts
+		ORDER BY name_txt COLLATE NOCASE, id
+		;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ret := []*Prompt{}
+	for rows.Next() {
+		r := &Prompt{}
+		var tags string
+		if err := rows.Scan(&r.ID, &r.Name, &r.Description, &tags, &r.Body); err != nil {
+			return nil, err
+		}
+		r.Tags = ParseTags(tags)
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
+
+// SavePrompt stores the prompt, creating it and setting its ID if it is new.
+func (p *Project) SavePrompt(r *Prompt) error {
+	tags := strings.Join(r.Tags, ", ")
+	if r.ID == 0 {
+		res, err := p.db.Exec(`
+			INSERT INTO Prompts (name_txt, description, tags, body)
+			VALUES (?, ?, ?, ?)
+			;
+		`, r.Name, r.Description, tags, r.Body)
+		if err != nil {
+			return err
+		}
+		r.ID, err = res.LastInsertId()
+		return err
+	}
+	_, err := p.db.Exec(`
+		UPDATE Prompts
+		SET
+			name_txt = ?,
+			description = ?,
+			tags = ?,
+			body = ?
+		WHERE
+			id = ?
+		;
+	`, r.Name, r.Description, tags, r.Body, r.ID)
+	return err
+}
+
+// DeletePrompt deletes the prompt with the given ID.
+func (p *Project) DeletePrompt(id int64) error {
+	_, err := p.db.Exec(`
+		DELETE FROM Prompts
+		WHERE id = ?
+		;
+	`, id)
+	return err
+}
+
+// ParseTags parses a comma-separated list of tags. Blank tags are ignored.
+func ParseTags(s string) []string {
+	ret := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			ret = append(ret, tag)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,537 @@
+package project
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// SessionName identifies a chat session.
+type SessionName struct {
+	ID int64
+	Name string
+	Updated string
+}
+
+// Session holds a saved chat session.
+type Session struct {
+	ID int64
+	Name string
+	AgentID int64
+	// Turns are all turns of all branches ordered by ID.
+	Turns []*llm.Turn
+	// ActiveTurn is the ID of the last turn of the branch last shown, zero if
+	// not known.
+	ActiveTurn int64
+}
+
+// Branch returns the turns of the active branch of the session, which leads to
+// the turn with the ID ActiveTurn, or to the last turn if there is no such
+// turn, and continues with the last child of each turn below it.
+func (s *Session) Branch() []*llm.Turn {
+	if len(s.Turns) == 0 {
+		return nil
+	}
+	turns := map[int64]*llm.Turn{}
+	children := map[int64]*llm.Turn{}
+	for _, turn := range s.Turns {
+		turns[turn.ID] = turn
+	}
+	for _, turn := range s.Turns {
+		if _, ok := turns[turn.ParentID]; ok {
+			children[turn.ParentID] = turn
+		}
+	}
+	leaf, ok := turns[s.ActiveTurn]
+	if !ok {
+		leaf = s.Turns[len(s.Turns)-1]
+	}
+	for c, ok := children[leaf.ID]; ok; c, ok = children[leaf.ID] {
+		leaf = c
+	}
+	ret := []*llm.Turn{}
+	for c, ok := leaf, true; ok; c, ok = turns[c.ParentID] {
+		ret = append([]*llm.Turn{c}, ret...)
+	}
+	return ret
+}
+
+// NewSession creates a new, empty chat session and returns its ID.
+func (p *Project) NewSession(name string, agentID int64) (int64, error) {
+	res, err := p.db.Exec(`
+		INSERT INTO Sessions (name_txt, agent)
+		VALUES (?, ?)
+		;
+	`, name, agentID)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ListSessions lists all chat sessions, most recently updated first.
+func (p *Project) ListSessions() []SessionName {
+	ret := []SessionName{}
+	rows, err := p.db.Query(`
+		SELECT
+			id,
+			IFNULL(name_txt, '') AS name_txt,
+			IFNULL(updated_at, '') AS updated_at
+		FROM Sessions
+		ORDER BY updated_at DESC, id DESC
+		;
+	`)
+	if err != nil {
+		log.Printf("error listing sessions (query): %v\n", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		n := SessionName{}
+		if err := rows.Scan(&n.ID, &n.Name, &n.Updated); err != nil {
+			log.Printf("error listing sessions (scan): %v\n", err)
+			return nil
+		}
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+// RenameSession renames a chat session.
+func (p *Project) RenameSession(id int64, name string) error {
+	_, err := p.db.Exec(`
+		UPDATE Sessions
+		SET name_txt = ?
+		WHERE id = ?
+		;
+	`, name, id)
+	return err
+}
+
+// DeleteSession deletes a chat session and all of its turns.
+func (p *Project) DeleteSession(id int64) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`
+		DELETE FROM Messages
+		WHERE turn IN (SELECT id FROM Turns WHERE session = ?)
+		;
+	`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM Exchanges
+		WHERE turn IN (SELECT id FROM Turns WHERE session = ?)
+		;
+	`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM Turns
+		WHERE session = ?
+		;
+	`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM Sessions
+		WHERE id = ?
+		;
+	`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetActiveTurn sets the last turn of the active branch of a chat session.
+func (p *Project) SetActiveTurn(session, turn int64) error {
+	_, err := p.db.Exec(`
+		UPDATE Sessions
+		SET active_turn = ?
+		WHERE id = ?
+		;
+	`, turn, session)
+	return err
+}
+
+// LoadSession loads a chat session with all of its turns. The LLM definitions
+// of the turns do not include endpoints or API keys.
+func (p *Project) LoadSession(id int64) (*Session, error) {
+	ret := &Session{
+		ID: id,
+	}
+	row := p.db.QueryRow(`
+		SELECT
+			IFNULL(name_txt, '') AS name_txt,
+			IFNULL(agent, 0) AS agent,
+			IFNULL(active_turn, 0) AS active_turn
+		FROM Sessions
+		WHERE id = ?
+		;
+	`, id)
+	if err := row.Scan(&ret.Name, &ret.AgentID, &ret.ActiveTurn); err != nil {
+		return nil, err
+	}
+	rows, err := p.db.Query(`
+		SELECT
+			id,
+			IFNULL(parent, 0),
+			IFNULL(llm, 0),
+			IFNULL(llm_name, ''),
+			IFNULL(api, ''),
+			IFNULL(model, ''),
+			IFNULL(params, ''),
+			IFNULL(sys_prompt, ''),
+			IFNULL(reasoning, ''),
+			IFNULL(tool_calls, ''),
+			IFNULL(finish_reason, ''),
+			IFNULL(response_model, ''),
+			IFNULL(request_id, ''),
+			prompt_tokens,
+			completion_tokens,
+			IFNULL(reasoning_tokens, 0),
+			IFNULL(cached_tokens, 0),
+			IFNULL(cost, 0),
+			IFNULL(first_token_ms, 0),
+			IFNULL(latency_ms, 0),
+			IFNULL(created_at, ''),
+			error_kind,
+			IFNULL(error_status, 0),
+			IFNULL(error_body, ''),
+			IFNULL(error_txt, '')
+		FROM Turns
+		WHERE session = ?
+		ORDER BY id ASC
+		;
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		turn := &llm.Turn{
+			System: &llm.Message{
+				Role: "system",
+			},
+		}
+		var toolCalls, params string
+		var promptTokens, completionTokens, errorKind sql.NullInt64
+		var reasoningTokens, cachedTokens int
+		var cost float64
+		var firstTokenMS, latencyMS int64
+		var created string
+		var errorStatus int
+		var errorBody, errorText string
+		if err := rows.Scan(
+			&turn.ID,
+			&turn.ParentID,
+			&turn.Definition.ID,
+			&turn.Definition.Name,
+			&turn.Definition.API,
+			&turn.Definition.Model,
+			&params,
+			&turn.System.Content,
+			&turn.Reasoning,
+			&toolCalls,
+			&turn.FinishReason,
+			&turn.Model,
+			&turn.RequestID,
+			&promptTokens,
+			&completionTokens,
+			&reasoningTokens,
+			&cachedTokens,
+			&cost,
+			&firstTokenMS,
+			&latencyMS,
+			&created,
+			&errorKind,
+			&errorStatus,
+			&errorBody,
+			&errorText,
+		); err != nil {
+			return nil, err
+		}
+		if turn.Definition.Parameters, err = llm.ParseParameters(params); err != nil {
+			log.Printf("error loading turn parameters: %v\n", err)
+		}
+		if toolCalls != "" {
+			if err := json.Unmarshal([]byte(toolCalls), &turn.ToolCalls); err != nil {
+				log.Printf("error loading turn tool calls: %v\n", err)
+			}
+		}
+		if promptTokens.Valid || completionTokens.Valid {
+			turn.Usage = &llm.Usage{
+				PromptTokens: int(promptTokens.Int64),
+				CompletionTokens: int(completionTokens.Int64),
+				ReasoningTokens: reasoningTokens,
+				CachedTokens: cachedTokens,
+				Cost: cost,
+			}
+		}
+		turn.FirstTokenLatency = time.Duration(firstTokenMS) * time.Millisecond
+		turn.Latency = time.Duration(latencyMS) * time.Millisecond
+		if created != "" {
+			if turn.Started, err = time.ParseInLocation(time.DateTime, created, time.UTC); err != nil {
+				log.Printf("error loading turn start time: %v\n", err)
+			}
+		}
+		if errorKind.Valid {
+			turn.Error = &llm.Error{
+				Kind: llm.ErrorKind(errorKind.Int64),
+				StatusCode: errorStatus,
+				Body: errorBody,
+			}
+			if errorText != "" {
+				turn.Error.Err = errors.New(errorText)
+			}
+		}
+		ret.Turns = append(ret.Turns, turn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, turn := range ret.Turns {
+		if err := p.loadTurnMessages(turn); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// loadTurnMessages loads the prompt and response messages of a turn.
+func (p *Project) loadTurnMessages(turn *llm.Turn) error {
+	rows, err := p.db.Query(`
+		SELECT
+			kind,
+			IFNULL(role, ''),
+			IFNULL(content, ''),
+			IFNULL(images, ''),
+			IFNULL(tool_calls, ''),
+			IFNULL(tool_call_id, ''),
+			IFNULL(tool_name, '')
+		FROM Messages
+		WHERE turn = ?
+		ORDER BY seq ASC
+		;
+	`, turn.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind, images, toolCalls string
+		msg := &llm.Message{}
+		if err := rows.Scan(&kind, &msg.Role, &msg.Content, &images, &toolCalls, &msg.ToolCallID, &msg.Name); err != nil {
+			return err
+		}
+		if toolCalls != "" {
+			if err := json.Unmarshal([]byte(toolCalls), &msg.ToolCalls); err != nil {
+				log.Printf("error loading message tool calls: %v\n", err)
+			}
+		}
+		if images != "" {
+			if err := json.Unmarshal([]byte(images), &msg.Images); err != nil {
+				log.Printf("error loading message images: %v\n", err)
+			}
+		}
+		if kind == "prompt" {
+			turn.Prompt = msg
+		} else {
+			turn.Response = append(turn.Response, msg)
+		}
+	}
+	return rows.Err()
+}
+
+// SaveTurn inserts or updates the turn in the chat session. Turns with an ID
+// of zero are inserted and have their ID set.
+func (p *Project) SaveTurn(session int64, turn *llm.Turn) error {
+	var toolCalls string
+	if len(turn.ToolCalls) > 0 {
+		buf, err := json.Marshal(turn.ToolCalls)
+		if err != nil {
+			return err
+		}
+		toolCalls = string(buf)
+	}
+	var promptTokens, completionTokens, reasoningTokens, cachedTokens, errorKind sql.NullInt64
+	var cost sql.NullFloat64
+	var created sql.NullString
+	var errorStatus int
+	var errorBody, errorText string
+	if turn.Usage != nil {
+		promptTokens = sql.NullInt64{Int64: int64(turn.Usage.PromptTokens), Valid: true}
+		completionTokens = sql.NullInt64{Int64: int64(turn.Usage.CompletionTokens), Valid: true}
+		reasoningTokens = sql.NullInt64{Int64: int64(turn.Usage.ReasoningTokens), Valid: true}
+		cachedTokens = sql.NullInt64{Int64: int64(turn.Usage.CachedTokens), Valid: true}
+		cost = sql.NullFloat64{Float64: turn.Usage.Cost, Valid: true}
+	}
+	if !turn.Started.IsZero() {
+		created = sql.NullString{String: turn.Started.UTC().Format(time.DateTime), Valid: true}
+	}
+	if turn.Error != nil {
+		errorKind = sql.NullInt64{Int64: int64(turn.Error.Kind), Valid: true}
+		errorStatus = turn.Error.StatusCode
+		errorBody = turn.Error.Body
+		if turn.Error.Err != nil {
+			errorText = turn.Error.Err.Error()
+		}
+	}
+	var parent sql.NullInt64
+	if turn.ParentID != 0 {
+		parent = sql.NullInt64{Int64: turn.ParentID, Valid: true}
+	}
+	var system string
+	if turn.System != nil {
+		system = turn.System.Content
+	}
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	args := []any{
+		parent,
+		turn.Definition.ID,
+		turn.Definition.Name,
+		turn.Definition.API,
+		turn.Definition.Model,
+		turn.Definition.Parameters.String(),
+		system,
+		turn.Reasoning,
+		toolCalls,
+		turn.FinishReason,
+		turn.Model,
+		turn.RequestID,
+		promptTokens,
+		completionTokens,
+		reasoningTokens,
+		cachedTokens,
+		cost,
+		turn.FirstTokenLatency.Milliseconds(),
+		turn.Latency.Milliseconds(),
+		errorKind,
+		errorStatus,
+		errorBody,
+		errorText,
+	}
+	if turn.ID == 0 {
+		res, err := tx.Exec(`
+			INSERT INTO Turns (
+				parent, llm, llm_name, api, model, params, sys_prompt,
+				reasoning, tool_calls, finish_reason, response_model,
+				request_id, prompt_tokens, completion_tokens,
+				reasoning_tokens, cached_tokens, cost, first_token_ms,
+				latency_ms, error_kind, error_status, error_body, error_txt,
+				session, created_at
+			)
+			VALUES (
+				?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+				?, ?, ?, IFNULL(?, CURRENT_TIMESTAMP)
+			)
+			;
+		`, append(args, session, created)...)
+		if err != nil {
+			return err
+		}
+		if turn.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	} else {
+		_, err := tx.Exec(`
+			UPDATE Turns
+			SET
+				parent = ?,
+				llm = ?,
+				llm_name = ?,
+				api = ?,
+				model = ?,
+				params = ?,
+				sys_prompt = ?,
+				reasoning = ?,
+				tool_calls = ?,
+				finish_reason = ?,
+				response_model = ?,
+				request_id = ?,
+				prompt_tokens = ?,
+				completion_tokens = ?,
+				reasoning_tokens = ?,
+				cached_tokens = ?,
+				cost = ?,
+				first_token_ms = ?,
+				latency_ms = ?,
+				error_kind = ?,
+				error_status = ?,
+				error_body = ?,
+				error_txt = ?
+			WHERE id = ?
+			;
+		`, append(args, turn.ID)...)
+		if err != nil {
+			return err
+		}
+	}
+	// Replace the messages of the turn
+	if _, err := tx.Exec(`
+		DELETE FROM Messages
+		WHERE turn = ?
+		;
+	`, turn.ID); err != nil {
+		return err
+	}
+	msgs := []*llm.Message{}
+	if turn.Prompt != nil {
+		msgs = append(msgs, turn.Prompt)
+	}
+	msgs = append(msgs, turn.Response...)
+	for i, msg := range msgs {
+		kind := "response"
+		if msg == turn.Prompt {
+			kind = "prompt"
+		}
+		var images, toolCalls string
+		if len(msg.Images) > 0 {
+			buf, err := json.Marshal(msg.Images)
+			if err != nil {
+				return err
+			}
+			images = string(buf)
+		}
+		if len(msg.ToolCalls) > 0 {
+			buf, err := json.Marshal(msg.ToolCalls)
+			if err != nil {
+				return err
+			}
+			toolCalls = string(buf)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO Messages (
+				turn, seq, kind, role, content, images, tool_calls,
+				tool_call_id, tool_name
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			;
+		`, turn.ID, i, kind, msg.Role, msg.Content, images, toolCalls, msg.ToolCallID, msg.Name); err != nil {
+			return err
+		}
+	}
+	if err := saveExchanges(tx, turn); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE Sessions
+		SET updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+		;
+	`, session); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
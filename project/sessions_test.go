@@ -0,0 +1,219 @@
+package project
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// newTurn returns a completed turn continuing the parent with the prompt.
+func newTurn(parent *llm.Turn, prompt string) *llm.Turn {
+	ret := &llm.Turn{
+		Definition: llm.LanguageModel{
+			ID: 1,
+			Name: "Test LLM",
+			API: "mock",
+			Model: "echo",
+		},
+		System: &llm.Message{
+			Role: "system",
+			Content: "Be helpful.",
+		},
+		Prompt: &llm.Message{
+			Role: "user",
+			Content: prompt,
+		},
+		Response: []*llm.Message{{
+			Role: "assistant",
+			Content: prompt,
+		}},
+		FinishReason: "stop",
+	}
+	if parent != nil {
+		ret.ParentID = parent.ID
+	}
+	return ret
+}
+
+// branchPrompts returns the prompts of the turns.
+func branchPrompts(turns []*llm.Turn) []string {
+	ret := []string{}
+	for _, turn := range turns {
+		ret = append(ret, turn.Prompt.Content)
+	}
+	return ret
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	p := loadProject(t, filepath.Join(t.TempDir(), "sessions.gen-magic"))
+	session, err := p.NewSession("Chat", 0)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	save := func(turn *llm.Turn) {
+		t.Helper()
+		if err := p.SaveTurn(session, turn); err != nil {
+			t.Fatalf("SaveTurn: %v", err)
+		}
+	}
+	// The first turn calls a tool and has everything that is saved set, as
+	// well as the parts of the definition that are not saved
+	temperature := 0.5
+	first := newTurn(nil, "Look it up")
+	first.Definition.APIEndpoint = "https://example.com/v1"
+	first.Definition.APIKey = "sk-secret"
+	first.Definition.KeyStorage = "plain"
+	first.Definition.Headers = map[string]string{"X-Test": "1"}
+	first.Definition.ContextLength = 8192
+	first.Definition.InputPrice = 1
+	first.Definition.Parameters.Temperature = &temperature
+	first.Prompt.Images = []*llm.Image{llm.NewImageBase64("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg==")}
+	first.Response = []*llm.Message{{
+		Role: "assistant",
+		ToolCalls: []*llm.ToolCall{{
+			ID: "call_0",
+			Name: "lookup",
+			Arguments: `{"q":"go"}`,
+		}},
+	}, {
+		Role: "tool",
+		Name: "lookup",
+		ToolCallID: "call_0",
+		Content: "found",
+	}, {
+		Role: "assistant",
+		Content: "It was found.",
+	}}
+	first.ToolCalls = first.Response[0].ToolCalls
+	first.Reasoning = "Thinking"
+	first.Model = "mock/echo"
+	first.RequestID = "req-1"
+	first.Usage = &llm.Usage{
+		PromptTokens: 10,
+		CompletionTokens: 5,
+		ReasoningTokens: 2,
+		CachedTokens: 3,
+		Cost: 0.25,
+	}
+	first.Started = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	first.FirstTokenLatency = 100 * time.Millisecond
+	first.Latency = 2 * time.Second
+	save(first)
+	// Two branches continue the first turn and the first branch goes on
+	a := newTurn(first, "Branch A")
+	save(a)
+	b := newTurn(first, "Branch B")
+	b.Error = &llm.Error{
+		Kind: llm.ErrorKindHTTPStatus,
+		StatusCode: 429,
+		Body: "slow down",
+		Err: errors.New("rate limited"),
+	}
+	b.Response = nil
+	save(b)
+	deeper := newTurn(a, "Deeper A")
+	save(deeper)
+	// Updating a turn replaces its messages
+	a.Response[0].Content = "Updated A"
+	save(a)
+	// Without a known active turn the branch leads to the last turn by ID,
+	// and branches continue with the last child of each turn
+	for _, tc := range []struct {
+		name string
+		active int64
+		want []string
+	}{
+		{"last turn", 0, []string{"Look it up", "Branch A", "Deeper A"}},
+		{"active leaf", b.ID, []string{"Look it up", "Branch B"}},
+		{"active middle", a.ID, []string{"Look it up", "Branch A", "Deeper A"}},
+		{"active root", first.ID, []string{"Look it up", "Branch B"}},
+		{"unknown turn", 999, []string{"Look it up", "Branch A", "Deeper A"}},
+	} {
+		if err := p.SetActiveTurn(session, tc.active); err != nil {
+			t.Fatalf("SetActiveTurn: %v", err)
+		}
+		s, err := p.LoadSession(session)
+		if err != nil {
+			t.Fatalf("LoadSession: %v", err)
+		}
+		if s.ActiveTurn != tc.active {
+			t.Errorf("%s: active turn = %d", tc.name, s.ActiveTurn)
+		}
+		got := branchPrompts(s.Branch())
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: branch %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: branch %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+	s, err := p.LoadSession(session)
+	if err != nil {
+		t.Fatalf("LoadSession: %v", err)
+	}
+	if s.Name != "Chat" || len(s.Turns) != 4 {
+		t.Fatalf("loaded session %q with %d turns", s.Name, len(s.Turns))
+	}
+	got := s.Turns[0]
+	// Definition: only the identity, model and parameters are saved
+	def := got.Definition
+	if def.ID != 1 || def.Name != "Test LLM" || def.API != "mock" || def.Model != "echo" {
+		t.Errorf("definition = %+v", def)
+	}
+	if def.Parameters.Temperature == nil || *def.Parameters.Temperature != 0.5 {
+		t.Errorf("parameters = %+v", def.Parameters)
+	}
+	if def.APIEndpoint != "" || def.APIKey != "" || def.KeyStorage != "" || def.Headers != nil || def.ContextLength != 0 || def.InputPrice != 0 {
+		t.Errorf("definition fields that are not saved were loaded: %+v", def)
+	}
+	// Messages
+	if got.System == nil || got.System.Content != "Be helpful." {
+		t.Errorf("system = %+v", got.System)
+	}
+	if got.Prompt == nil || got.Prompt.Content != "Look it up" || len(got.Prompt.Images) != 1 || got.Prompt.Images[0].MimeType() != "image/png" {
+		t.Errorf("prompt = %+v", got.Prompt)
+	}
+	if len(got.Response) != 3 {
+		t.Fatalf("loaded %d responses, want 3", len(got.Response))
+	}
+	if calls := got.Response[0].ToolCalls; len(calls) != 1 || calls[0].ID != "call_0" || calls[0].Arguments != `{"q":"go"}` {
+		t.Errorf("response tool calls = %+v", calls)
+	}
+	if r := got.Response[1]; r.Role != "tool" || r.Name != "lookup" || r.ToolCallID != "call_0" || r.Content != "found" {
+		t.Errorf("tool response = %+v", r)
+	}
+	if r := got.Response[2]; r.Role != "assistant" || r.Content != "It was found." {
+		t.Errorf("final response = %+v", r)
+	}
+	// Results
+	if len(got.ToolCalls) != 1 || got.Reasoning != "Thinking" || got.FinishReason != "stop" || got.Model != "mock/echo" || got.RequestID != "req-1" {
+		t.Errorf("results = %+v", got)
+	}
+	if got.Usage == nil || *got.Usage != *first.Usage {
+		t.Errorf("usage = %+v", got.Usage)
+	}
+	if !got.Started.Equal(first.Started) || got.FirstTokenLatency != first.FirstTokenLatency || got.Latency != first.Latency {
+		t.Errorf("timing = %v %v %v", got.Started, got.FirstTokenLatency, got.Latency)
+	}
+	if got.Error != nil {
+		t.Errorf("unexpected error %v", got.Error)
+	}
+	// Updated and failed turns
+	if r := s.Turns[1].Response; len(r) != 1 || r[0].Content != "Updated A" {
+		t.Errorf("updated responses = %+v", r)
+	}
+	failed := s.Turns[2]
+	if failed.Error == nil || failed.Error.Kind != llm.ErrorKindHTTPStatus || failed.Error.StatusCode != 429 || failed.Error.Body != "slow down" || failed.Error.Err == nil || failed.Error.Err.Error() != "rate limited" {
+		t.Errorf("error = %+v", failed.Error)
+	}
+	if failed.Usage != nil || len(failed.Response) != 0 {
+		t.Errorf("failed turn = %+v", failed)
+	}
+}
@@ -0,0 +1,141 @@
+package project
+
+import (
+	"log"
+	"maps"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// BuiltinVariables are the names of the template variables filled in by the
+// project rather than the user.
+var BuiltinVariables = []string{"date", "time", "project"}
+
+// templateFuncs are the names of the functions predefined by text/template,
+// which are not variables.
+var templateFuncs = map[string]bool{
+	"and": true, "call": true, "html": true, "index": true, "slice": true,
+	"js": true, "len": true, "not": true, "or": true, "print": true,
+	"printf": true, "println": true, "urlquery": true, "eq": true, "ge": true,
+	"gt": true, "le": true, "lt": true, "ne": true,
+}
+
+// TemplateVariables returns the names of the variables of a prompt template
+// in order of first use, not including the builtin variables. Variables are
+// written either as {{name}} or {{.name}}.
+func TemplateVariables(text string) ([]string, error) {
+	t := parse.New("prompt")
+	t.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	if _, err := t.Parse(text, "", "", trees); err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	seen := map[string]bool{}
+	for _, name := range BuiltinVariables {
+		seen[name] = true
+	}
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, c := range n.Args {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.IdentifierNode:
+			if !templateFuncs[n.Ident] && !seen[n.Ident] {
+				seen[n.Ident] = true
+				ret = append(ret, n.Ident)
+			}
+		case *parse.FieldNode:
+			if !seen[n.Ident[0]] {
+				seen[n.Ident[0]] = true
+				ret = append(ret, n.Ident[0])
+			}
+		}
+	}
+	for _, tree := range trees {
+		walk(tree.Root)
+	}
+	return ret, nil
+}
+
+// RenderTemplate executes a prompt template with the variables. The builtin
+// variables are filled in unless given and missing variables are empty.
+func (p *Project) RenderTemplate(text string, vars map[string]string) (string, error) {
+	names, err := TemplateVariables(text)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	values := map[string]string{
+		"date": now.Format("2006-01-02"),
+		"time": now.Format("15:04"),
+		"project": p.name,
+	}
+	maps.Copy(values, vars)
+	funcs := template.FuncMap{}
+	for _, name := range append(names, BuiltinVariables...) {
+		v := values[name]
+		funcs[name] = func() string { return v }
+	}
+	t, err := template.New("prompt").Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, values); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ResolveAgent returns a copy of the agent with its system prompt template
+// rendered with its variables for use in a turn. The template is used as-is
+// if it fails to render.
+func (p *Project) ResolveAgent(agent *llm.Agent) *llm.Agent {
+	ret := *agent
+	system, err := p.RenderTemplate(agent.System.Content, agent.Variables)
+	if err != nil {
+		log.Printf("error rendering system prompt of agent \"%s\": %v\n", agent.Name, err)
+		return &ret
+	}
+	ret.System.Content = system
+	return &ret
+}
@@ -0,0 +1,165 @@
+package project
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// UsageTotal holds the usage totals of a group of turns.
+type UsageTotal struct {
+	// Key is the LLM name or day the totals are grouped by.
+	Key string
+	Turns int
+	llm.Usage
+	// Latency is the average latency of the turns that measured it.
+	Latency time.Duration
+}
+
+// UsageByLLM returns the usage totals of all turns by LLM definition name.
+func (p *Project) UsageByLLM() ([]UsageTotal, error) {
+	return p.usageTotals("IFNULL(llm_name, '')", "SUM(IFNULL(cost, 0)) DESC, 1 ASC")
+}
+
+// UsageByDay returns the usage totals of all turns by the UTC day they were
+// started, most recent first.
+func (p *Project) UsageByDay() ([]UsageTotal, error) {
+	return p.usageTotals("IFNULL(date(created_at), '')", "1 DESC")
+}
+
+// usageTotals returns the usage totals of all turns grouped by the SQL
+// expression group and sorted by the SQL order.
+func (p *Project) usageTotals(group, order string) ([]UsageTotal, error) {
+	rows, err := p.db.Query(fmt.Sprintf(`
+		SELECT
+			%s,
+			COUNT(*),
+			IFNULL(SUM(prompt_tokens), 0),
+			IFNULL(SUM(completion_tokens), 0),
+			IFNULL(SUM(reasoning_tokens), 0),
+			IFNULL(SUM(cached_tokens), 0),
+			IFNULL(SUM(cost), 0),
+			IFNULL(AVG(NULLIF(latency_ms, 0)), 0)
+		FROM Turns
+		GROUP BY 1
+		ORDER BY %s
+		;
+	`, group, order))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ret := []UsageTotal{}
+	for rows.Next() {
+		t := UsageTotal{}
+		var latency float64
+		if err := rows.Scan(
+			&t.Key,
+			&t.Turns,
+			&t.PromptTokens,
+			&t.CompletionTokens,
+			&t.ReasoningTokens,
+			&t.CachedTokens,
+			&t.Cost,
+			&latency,
+		); err != nil {
+			return nil, err
+		}
+		t.Latency = time.Duration(latency * float64(time.Millisecond))
+		ret = append(ret, t)
+	}
+	return ret, rows.Err()
+}
+
+// ExportUsageCSV writes the usage of every turn as CSV with a header row.
+func (p *Project) ExportUsageCSV(w io.Writer) error {
+	rows, err := p.db.Query(`
+		SELECT
+			IFNULL(Turns.created_at, ''),
+			IFNULL(Sessions.name_txt, ''),
+			IFNULL(Turns.llm_name, ''),
+			IFNULL(Turns.api, ''),
+			IFNULL(Turns.model, ''),
+			IFNULL(Turns.response_model, ''),
+			IFNULL(Turns.prompt_tokens, 0),
+			IFNULL(Turns.completion_tokens, 0),
+			IFNULL(Turns.reasoning_tokens, 0),
+			IFNULL(Turns.cached_tokens, 0),
+			IFNULL(Turns.cost, 0),
+			IFNULL(Turns.first_token_ms, 0),
+			IFNULL(Turns.latency_ms, 0),
+			IFNULL(Turns.finish_reason, ''),
+			IFNULL(Turns.error_kind, -1)
+		FROM Turns
+		LEFT JOIN Sessions ON Turns.session = Sessions.id
+		ORDER BY Turns.id ASC
+		;
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"started_utc", "session", "llm", "api", "model", "response_model",
+		"prompt_tokens", "completion_tokens", "reasoning_tokens",
+		"cached_tokens", "cost_usd", "first_token_ms", "latency_ms",
+		"finish_reason", "error",
+	})
+	for rows.Next() {
+		var started, session, llmName, api, model, responseModel, finishReason string
+		var promptTokens, completionTokens, reasoningTokens, cachedTokens int
+		var firstTokenMS, latencyMS int64
+		var cost float64
+		var errorKind int
+		if err := rows.Scan(
+			&started,
+			&session,
+			&llmName,
+			&api,
+			&model,
+			&responseModel,
+			&promptTokens,
+			&completionTokens,
+			&reasoningTokens,
+			&cachedTokens,
+			&cost,
+			&firstTokenMS,
+			&latencyMS,
+			&finishReason,
+			&errorKind,
+		); err != nil {
+			return err
+		}
+		errorText := ""
+		if errorKind >= 0 {
+			errorText = llm.ErrorKind(errorKind).String()
+		}
+		cw.Write([]string{
+			started,
+			session,
+			llmName,
+			api,
+			model,
+			responseModel,
+			strconv.Itoa(promptTokens),
+			strconv.Itoa(completionTokens),
+			strconv.Itoa(reasoningTokens),
+			strconv.Itoa(cachedTokens),
+			strconv.FormatFloat(cost, 'f', -1, 64),
+			strconv.FormatInt(firstTokenMS, 10),
+			strconv.FormatInt(latencyMS, 10),
+			finishReason,
+			errorText,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
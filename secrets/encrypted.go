@@ -0,0 +1,174 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// encryptedPrefix prefixes stored encrypted values.
+const encryptedPrefix string = "enc:v1:"
+
+// PassphraseEnv is the environment variable the passphrase is read from if
+// none has been set.
+const PassphraseEnv string = "GEN_MAGIC_PASSPHRASE"
+
+// Argon2id parameters
+const (
+	saltLength int = 16
+	keyLength uint32 = 32
+	argonTime uint32 = 1
+	argonMemory uint32 = 64 * 1024
+	argonThreads uint8 = 4
+)
+
+// ErrPassphraseRequired is returned when an encrypted secret is resolved or
+// stored before a passphrase has been set.
+var ErrPassphraseRequired = errors.New("a passphrase is required for encrypted secrets")
+
+// ErrWrongPassphrase is returned when an encrypted secret cannot be decrypted
+// with the passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase for encrypted secret")
+
+var encrypted = &encryptedBackend{
+	keys: map[string][]byte{},
+}
+
+func init() {
+	Register(encrypted)
+}
+
+// SetPassphrase sets the passphrase used to encrypt and decrypt secrets. An
+// empty passphrase clears it.
+func SetPassphrase(passphrase string) {
+	encrypted.lock.Lock()
+	defer encrypted.lock.Unlock()
+	encrypted.passphrase = passphrase
+	encrypted.keys = map[string][]byte{}
+}
+
+// HasPassphrase returns true if a passphrase is available either from
+// SetPassphrase or the environment.
+func HasPassphrase() bool {
+	encrypted.lock.Lock()
+	defer encrypted.lock.Unlock()
+	return encrypted.getPassphrase() != ""
+}
+
+// IsEncrypted returns true if the stored value is an encrypted secret.
+func IsEncrypted(stored string) bool {
+	return strings.HasPrefix(stored, encryptedPrefix)
+}
+
+// encryptedBackend stores secrets in the project file encrypted with AES-GCM
+// using a key derived from a passphrase with Argon2id.
+type encryptedBackend struct {
+	lock sync.Mutex
+	passphrase string
+	keys map[string][]byte // Derived keys by salt
+}
+
+// getPassphrase returns the passphrase. The lock must be held.
+func (b *encryptedBackend) getPassphrase() string {
+	if b.passphrase != "" {
+		return b.passphrase
+	}
+	return os.Getenv(PassphraseEnv)
+}
+
+// key returns the key derived from the passphrase and salt.
+func (b *encryptedBackend) key(salt []byte) ([]byte, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	passphrase := b.getPassphrase()
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	if key, found := b.keys[string(salt)]; found {
+		return key, nil
+	}
+	key := argon2.IDKey([]byte(passphrase), salt, argonTime, argonMemory, argonThreads, keyLength)
+	b.keys[string(salt)] = key
+	return key, nil
+}
+
+// ID implements Backend.
+func (b *encryptedBackend) ID() string { return "encrypted" }
+
+// Name implements Backend.
+func (b *encryptedBackend) Name() string { return "Encrypted (Passphrase)" }
+
+// Parse implements Backend.
+func (b *encryptedBackend) Parse(stored string) (string, bool) {
+	return "", IsEncrypted(stored)
+}
+
+// Resolve implements Backend.
+func (b *encryptedBackend) Resolve(stored string) (string, error) {
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(buf) < saltLength {
+		return "", errors.New("encrypted secret is truncated")
+	}
+	key, err := b.key(buf[:saltLength])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	buf = buf[saltLength:]
+	if len(buf) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is truncated")
+	}
+	secret, err := gcm.Open(nil, buf[:gcm.NonceSize()], buf[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return string(secret), nil
+}
+
+// Store implements Backend.
+func (b *encryptedBackend) Store(name, secret string) (string, error) {
+	if secret == "" {
+		return "", nil
+	}
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := b.key(salt)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	buf := append(salt, nonce...)
+	buf = gcm.Seal(buf, nonce, []byte(secret), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// newGCM returns an AES-GCM cipher with the given key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
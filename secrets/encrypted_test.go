@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	SetPassphrase("correct horse")
+	defer SetPassphrase("")
+	stored, err := encrypted.Store("", "sk-secret")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if !IsEncrypted(stored) || strings.Contains(stored, "sk-secret") {
+		t.Fatalf("stored value %q is not encrypted", stored)
+	}
+	if b, _ := Detect(stored); b != encrypted {
+		t.Errorf("detected backend %s", b.ID())
+	}
+	// A new salt is used for every value
+	again, err := encrypted.Store("", "sk-secret")
+	if err != nil || again == stored {
+		t.Errorf("storing again returned %q, %v", again, err)
+	}
+	// Resolve with a fresh key cache
+	SetPassphrase("correct horse")
+	secret, err := Resolve(stored)
+	if err != nil || secret != "sk-secret" {
+		t.Errorf("Resolve = %q, %v", secret, err)
+	}
+}
+
+func TestEncryptedWrongPassphrase(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	SetPassphrase("correct horse")
+	defer SetPassphrase("")
+	stored, err := encrypted.Store("", "sk-secret")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	SetPassphrase("battery staple")
+	secret, err := Resolve(stored)
+	if !errors.Is(err, ErrWrongPassphrase) || secret != "" {
+		t.Errorf("Resolve = %q, %v, want wrong passphrase", secret, err)
+	}
+	// The passphrase may also come from the environment
+	SetPassphrase("")
+	t.Setenv(PassphraseEnv, "correct horse")
+	if secret, err := Resolve(stored); err != nil || secret != "sk-secret" {
+		t.Errorf("Resolve with the environment = %q, %v", secret, err)
+	}
+}
+
+func TestEncryptedWithoutPassphrase(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	SetPassphrase("")
+	if HasPassphrase() {
+		t.Fatalf("unexpected passphrase")
+	}
+	if _, err := encrypted.Store("", "sk-secret"); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Store error = %v", err)
+	}
+	if _, err := Resolve(encryptedPrefix + "AAAA"); err == nil {
+		t.Errorf("resolved a truncated secret")
+	}
+	if stored, err := encrypted.Store("", ""); err != nil || stored != "" {
+		t.Errorf("storing an empty secret = %q, %v", stored, err)
+	}
+}
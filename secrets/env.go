@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register(&envBackend{})
+}
+
+// envRegexp matches environment variable references like ${OPENROUTER_API_KEY}.
+var envRegexp = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// envBackend references secrets held in environment variables. Only the
+// variable reference is stored in the project file.
+type envBackend struct{}
+
+// ID implements Backend.
+func (b *envBackend) ID() string { return "env" }
+
+// Name implements Backend.
+func (b *envBackend) Name() string { return "Environment Variable" }
+
+// Parse implements Backend.
+func (b *envBackend) Parse(stored string) (string, bool) {
+	m := envRegexp.FindStringSubmatch(stored)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// Resolve implements Backend.
+func (b *envBackend) Resolve(stored string) (string, error) {
+	name, ok := b.Parse(stored)
+	if !ok {
+		return "", fmt.Errorf("invalid environment variable reference \"%s\"", stored)
+	}
+	v, found := os.LookupEnv(name)
+	if !found {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return v, nil
+}
+
+// Store implements Backend. The secret is not stored anywhere, the variable
+// must be set in the environment.
+func (b *envBackend) Store(name, secret string) (string, error) {
+	name = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(name), "${"), "}")
+	stored := "${" + name + "}"
+	if !envRegexp.MatchString(stored) {
+		return "", fmt.Errorf("invalid environment variable name \"%s\"", name)
+	}
+	return stored, nil
+}
@@ -0,0 +1,44 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvResolve(t *testing.T) {
+	t.Setenv("GEN_MAGIC_TEST_KEY", "sk-env")
+	if secret, err := Resolve("${GEN_MAGIC_TEST_KEY}"); err != nil || secret != "sk-env" {
+		t.Errorf("Resolve = %q, %v", secret, err)
+	}
+	// Set but empty is not an error
+	t.Setenv("GEN_MAGIC_TEST_KEY", "")
+	if secret, err := Resolve("${GEN_MAGIC_TEST_KEY}"); err != nil || secret != "" {
+		t.Errorf("Resolve of an empty variable = %q, %v", secret, err)
+	}
+}
+
+func TestEnvUnset(t *testing.T) {
+	secret, err := Resolve("${GEN_MAGIC_TEST_UNSET_KEY}")
+	if err == nil || secret != "" || !strings.Contains(err.Error(), "GEN_MAGIC_TEST_UNSET_KEY") {
+		t.Errorf("Resolve = %q, %v, want an error naming the variable", secret, err)
+	}
+}
+
+func TestEnvStore(t *testing.T) {
+	b := Get("env")
+	for _, tc := range []struct {
+		name string
+		want string
+	}{
+		{"OPENAI_API_KEY", "${OPENAI_API_KEY}"},
+		{" ${OPENAI_API_KEY} ", "${OPENAI_API_KEY}"},
+	} {
+		// The secret itself is never stored
+		if stored, err := b.Store(tc.name, "sk-secret"); err != nil || stored != tc.want {
+			t.Errorf("Store(%q) = %q, %v", tc.name, stored, err)
+		}
+	}
+	if _, err := b.Store("not valid", "sk-secret"); err == nil {
+		t.Errorf("stored an invalid variable name")
+	}
+}
@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// keystorePrefix prefixes stored keystore references.
+const keystorePrefix string = "keystore:"
+
+func init() {
+	Register(&keystoreBackend{})
+}
+
+// KeystorePath returns the path of the keystore file shared by all projects
+// of the current user.
+func KeystorePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gen-magic", "keystore.json"), nil
+}
+
+// keystoreBackend stores secrets by name in a file outside of the project, so
+// that shared projects only name the secret. Each user keeps their own
+// keystore with secrets under the same names.
+type keystoreBackend struct {
+	lock sync.Mutex
+}
+
+// ID implements Backend.
+func (b *keystoreBackend) ID() string { return "keystore" }
+
+// Name implements Backend.
+func (b *keystoreBackend) Name() string { return "Keystore File" }
+
+// Parse implements Backend.
+func (b *keystoreBackend) Parse(stored string) (string, bool) {
+	if !strings.HasPrefix(stored, keystorePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(stored, keystorePrefix), true
+}
+
+// load loads the keystore. A missing keystore is empty.
+func (b *keystoreBackend) load() (map[string]string, error) {
+	p, err := KeystorePath()
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]string{}
+	buf, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return ret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &ret); err != nil {
+		return nil, fmt.Errorf("error reading keystore %s: %w", p, err)
+	}
+	return ret, nil
+}
+
+// Resolve implements Backend.
+func (b *keystoreBackend) Resolve(stored string) (string, error) {
+	name, ok := b.Parse(stored)
+	if !ok {
+		return "", fmt.Errorf("invalid keystore reference \"%s\"", stored)
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	keys, err := b.load()
+	if err != nil {
+		return "", err
+	}
+	v, found := keys[name]
+	if !found {
+		return "", fmt.Errorf("secret \"%s\" is not in the keystore", name)
+	}
+	return v, nil
+}
+
+// Store implements Backend.
+func (b *keystoreBackend) Store(name, secret string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("a name is required to store a secret in the keystore")
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	keys, err := b.load()
+	if err != nil {
+		return "", err
+	}
+	if keys[name] != secret {
+		keys[name] = secret
+		p, err := KeystorePath()
+		if err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return "", err
+		}
+		buf, err := json.MarshalIndent(keys, "", "\t")
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(p, buf, 0600); err != nil {
+			return "", err
+		}
+	}
+	return keystorePrefix + name, nil
+}
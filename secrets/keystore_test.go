@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// useTempKeystore points the keystore at a temporary directory.
+func useTempKeystore(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+}
+
+func TestKeystore(t *testing.T) {
+	useTempKeystore(t)
+	b := Get("keystore")
+	stored, err := b.Store(" openai ", "sk-keystore")
+	if err != nil || stored != "keystore:openai" {
+		t.Fatalf("Store = %q, %v", stored, err)
+	}
+	if secret, err := Resolve(stored); err != nil || secret != "sk-keystore" {
+		t.Errorf("Resolve = %q, %v", secret, err)
+	}
+	p, err := KeystorePath()
+	if err != nil {
+		t.Fatalf("KeystorePath: %v", err)
+	}
+	if info, err := os.Stat(p); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0600) {
+		t.Errorf("keystore file %s: %v %v", p, info, err)
+	}
+	if _, err := b.Store("", "sk-keystore"); err == nil {
+		t.Errorf("stored a secret without a name")
+	}
+}
+
+func TestKeystoreMissing(t *testing.T) {
+	useTempKeystore(t)
+	// Without a keystore file
+	if secret, err := Resolve("keystore:openai"); err == nil || secret != "" || !strings.Contains(err.Error(), "openai") {
+		t.Errorf("Resolve = %q, %v, want an error naming the secret", secret, err)
+	}
+	// With a keystore file holding other secrets
+	if _, err := Get("keystore").Store("anthropic", "sk-other"); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if secret, err := Resolve("keystore:openai"); err == nil || secret != "" {
+		t.Errorf("Resolve = %q, %v, want an error", secret, err)
+	}
+}
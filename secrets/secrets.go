@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Backend implements one way of storing the secrets referenced from project
+// files, like API keys.
+type Backend interface {
+	// ID returns the unique ID of the backend, like "env".
+	ID() string
+	// Name returns the human-readable name of the backend.
+	Name() string
+	// Parse returns the name the stored value was stored under and true if
+	// the value belongs to this backend.
+	Parse(stored string) (string, bool)
+	// Resolve returns the secret referenced by the stored value.
+	Resolve(stored string) (string, error)
+	// Store stores the secret under the given name if the backend uses names
+	// and returns the value to save in the project file in its place.
+	Store(name, secret string) (string, error)
+}
+
+var backendsLock sync.RWMutex
+var backends = []Backend{}
+
+// Register registers a backend. Backends are tried in registration order
+// when detecting which backend a stored value belongs to. Registering two
+// backends with the same ID panics.
+func Register(b Backend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+	for _, o := range backends {
+		if o.ID() == b.ID() {
+			panic(fmt.Sprintf("duplicate secret backend \"%s\"", b.ID()))
+		}
+	}
+	backends = append(backends, b)
+}
+
+// Get returns the backend with the given ID or the plain text backend if
+// there is no such backend.
+func Get(id string) Backend {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+	for _, b := range backends {
+		if b.ID() == id {
+			return b
+		}
+	}
+	return plain
+}
+
+// Backends returns all registered backends in registration order.
+func Backends() []Backend {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+	return append([]Backend{}, backends...)
+}
+
+// Detect returns the backend the stored value belongs to and the name it was
+// stored under. Values not claimed by any other backend are plain text.
+func Detect(stored string) (Backend, string) {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+	for _, b := range backends {
+		if b == plain {
+			continue
+		}
+		if name, ok := b.Parse(stored); ok {
+			return b, name
+		}
+	}
+	return plain, ""
+}
+
+// Resolve returns the secret referenced by the stored value.
+func Resolve(stored string) (string, error) {
+	b, _ := Detect(stored)
+	return b.Resolve(stored)
+}
+
+// plain is the plain text backend.
+var plain = &plainBackend{}
+
+func init() {
+	Register(plain)
+}
+
+// plainBackend stores secrets in the project file as plain text.
+type plainBackend struct{}
+
+// ID implements Backend.
+func (b *plainBackend) ID() string { return "plain" }
+
+// Name implements Backend.
+func (b *plainBackend) Name() string { return "Plain Text" }
+
+// Parse implements Backend.
+func (b *plainBackend) Parse(stored string) (string, bool) { return "", true }
+
+// Resolve implements Backend.
+func (b *plainBackend) Resolve(stored string) (string, error) { return stored, nil }
+
+// Store implements Backend.
+func (b *plainBackend) Store(name, secret string) (string, error) { return secret, nil }
@@ -0,0 +1,39 @@
+package secrets
+
+import "testing"
+
+func TestPlainValues(t *testing.T) {
+	for _, stored := range []string{"", "sk-plain", "$OPENAI_API_KEY", "${not valid}", "enc:v2:abc"} {
+		b, name := Detect(stored)
+		if b != plain || name != "" {
+			t.Errorf("%q detected as %s", stored, b.ID())
+			continue
+		}
+		if secret, err := Resolve(stored); err != nil || secret != stored {
+			t.Errorf("%q resolved to %q, %v", stored, secret, err)
+		}
+	}
+	if stored, err := Get("plain").Store("name", "sk-plain"); err != nil || stored != "sk-plain" {
+		t.Errorf("Store = %q, %v", stored, err)
+	}
+	if Get("unknown") != plain {
+		t.Errorf("unknown backends must fall back to plain text")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		stored string
+		backend string
+		name string
+	}{
+		{"${OPENAI_API_KEY}", "env", "OPENAI_API_KEY"},
+		{"keystore:openai", "keystore", "openai"},
+		{encryptedPrefix + "abc", "encrypted", ""},
+	} {
+		b, name := Detect(tc.stored)
+		if b.ID() != tc.backend || name != tc.name {
+			t.Errorf("%q detected as %s %q, want %s %q", tc.stored, b.ID(), name, tc.backend, tc.name)
+		}
+	}
+}
@@ -6,20 +6,22 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/qbradq/gen-magic/llm"
+	"github.com/qbradq/gen-magic/project"
 )
 
 // ShowAgentSettings shows the agent editor dialog.
 func ShowAgentSettings(m *Main) {
 	// Variables
 	var agent *llm.Agent
-	var agents []AgentName
-	var llms []LLMName
+	var agents []project.AgentName
+	var llms []project.LLMName
 	var agentSelect *IndexedSelect
 	var btnDelete *widget.Button
 	var btnNew *widget.Button
 	var nameEntry *widget.Entry
 	var llmSelect *IndexedSelect
 	var sysEntry *widget.Entry
+	var varsEntry *widget.Entry
 	lastEditedAgent := m.p.IntSetting("agent.last-edited", 0)
 	f := widget.NewForm()
 	// Internal functions
@@ -45,7 +47,7 @@ func ShowAgentSettings(m *Main) {
 		idx := -1
 		for i, llmName := range llms {
 			llmStrs = append(llmStrs, llmName.Name)
-			if llmName.ID == agent.ID {
+			if llmName.ID == agent.LLM.ID {
 				idx = i
 			}
 		}
@@ -59,6 +61,8 @@ func ShowAgentSettings(m *Main) {
 		refreshAgentList()
 		refreshLLMList()
 		nameEntry.SetText(agent.Name)
+		sysEntry.SetText(agent.System.Content)
+		varsEntry.SetText(formatHeaders(agent.Variables))
 	}
 	var load = func(id int64) {
 		agent = m.p.GetAgent(id)
@@ -121,6 +125,17 @@ func ShowAgentSettings(m *Main) {
 		agent.System.Content = s
 	}
 	f.Append("System", sysEntry)
+	// Custom variables of the system prompt template, which are written as
+	// "name: value" lines like LLM headers
+	varsEntry = widget.NewEntry()
+	varsEntry.MultiLine = true
+	varsEntry.SetMinRowsVisible(3)
+	varsEntry.SetPlaceHolder("name: value")
+	varsEntry.OnChanged = func(s string) {
+		agent.Variables = parseHeaders(s)
+	}
+	f.Append("Variables", varsEntry)
+	f.Append("", widget.NewLabel("Use {{name}} for variables and {{date}}, {{time}} or {{project}}."))
 	// Load last edited agent
 	agents = m.p.ListAgents()
 	agent = m.p.GetAgent(agents[lastEditedAgent].ID)
@@ -129,7 +144,7 @@ func ShowAgentSettings(m *Main) {
 	dlg := dialog.NewCustom("Agent Settings", "Done", f, m.w)
 	dlg.SetOnClosed(func() {
 		save()
-		// TODO Fire global OnAgentClosed event
+		m.FireOnAgentsUpdated()
 	})
 	dlg.Resize(dlg.MinSize().AddWidthHeight(320, 0))
 	dlg.Show()
@@ -0,0 +1,137 @@
+package ui
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// imageExtensions are the file extensions of images that may be attached to
+// prompts.
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".webp"}
+
+// AttachImage attaches an image to the next prompt.
+func (l *Chat) AttachImage(img *llm.Image) {
+	l.attachments = append(l.attachments, img)
+	l.refreshAttachments()
+}
+
+// AttachURI attaches the image file at the URI to the next prompt.
+func (l *Chat) AttachURI(uri fyne.URI) error {
+	r, err := storage.Reader(uri)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	img, err := llm.NewImageData(data)
+	if err != nil {
+		return err
+	}
+	l.AttachImage(img)
+	return nil
+}
+
+// showAttachDialog shows the file dialog to attach an image.
+func (l *Chat) showAttachDialog() {
+	fileOpen := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			log.Printf("error in attach image file: %v\n", err)
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+		if err := l.AttachURI(reader.URI()); err != nil {
+			dialog.ShowError(err, l.w)
+		}
+	}, l.w)
+	fileOpen.SetConfirmText("Attach")
+	fileOpen.SetDismissText("Cancel")
+	fileOpen.SetFilter(storage.NewExtensionFileFilter(imageExtensions))
+	fileOpen.SetTitleText("Attach Image")
+	fileOpen.Show()
+}
+
+// onDropped attaches the image files dropped on the window.
+func (l *Chat) onDropped(pos fyne.Position, uris []fyne.URI) {
+	for _, uri := range uris {
+		if !slices.Contains(imageExtensions, strings.ToLower(uri.Extension())) {
+			continue
+		}
+		if err := l.AttachURI(uri); err != nil {
+			dialog.ShowError(err, l.w)
+		}
+	}
+}
+
+// onPaste attaches the pasted clipboard content if it is an image data URL or
+// the path of an image file. The clipboard only carries text, so this is how
+// images copied from browsers and file managers arrive.
+func (l *Chat) onPaste(content string) bool {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "data:image/") {
+		img := &llm.Image{}
+		if err := img.SetImageBase64(content); err != nil {
+			dialog.ShowError(err, l.w)
+			return true
+		}
+		l.AttachImage(img)
+		return true
+	}
+	if strings.Contains(content, "\n") {
+		return false
+	}
+	uri, err := storage.ParseURI(content)
+	if err != nil || uri.Scheme() != "file" {
+		if !filepath.IsAbs(content) {
+			return false
+		}
+		uri = storage.NewFileURI(content)
+	}
+	if !slices.Contains(imageExtensions, strings.ToLower(uri.Extension())) {
+		return false
+	}
+	if _, err := os.Stat(uri.Path()); err != nil {
+		return false
+	}
+	if err := l.AttachURI(uri); err != nil {
+		dialog.ShowError(err, l.w)
+	}
+	return true
+}
+
+// refreshAttachments refreshes the thumbnails of the attached images.
+func (l *Chat) refreshAttachments() {
+	l.attachBar.RemoveAll()
+	for i, img := range l.attachments {
+		l.attachBar.Add(container.NewBorder(nil,
+			widget.NewButtonWithIcon("", theme.Icon(theme.IconNameDelete), func() {
+				l.attachments = slices.Delete(l.attachments, i, i+1)
+				l.refreshAttachments()
+			}),
+			nil, nil,
+			newThumbnail(img),
+		))
+	}
+	if len(l.attachments) == 0 {
+		l.attachBar.Hide()
+	} else {
+		l.attachBar.Show()
+	}
+	l.updateGauge()
+}
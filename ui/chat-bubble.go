@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"fmt"
 	"image/color"
 
 	"fyne.io/fyne/v2"
@@ -9,6 +10,7 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/qbradq/gen-magic/llm"
 )
 
 // ChatBubble implements an IM-style chat bubble.
@@ -19,7 +21,23 @@ type ChatBubble struct {
 	BubbleColor color.Color
 	AlignRight bool
 	c *fyne.Container
+	actions *fyne.Container
+	branch *fyne.Container
 	text *widget.RichText
+	images *fyne.Container
+	footer *widget.Label
+}
+
+// thumbnailSize is the size of image thumbnails in chat bubbles and the
+// prompt attachments.
+var thumbnailSize = fyne.NewSize(96, 96)
+
+// newThumbnail returns a thumbnail of the image.
+func newThumbnail(img *llm.Image) *canvas.Image {
+	ret := canvas.NewImageFromImage(img.Image())
+	ret.FillMode = canvas.ImageFillContain
+	ret.SetMinSize(thumbnailSize)
+	return ret
 }
 
 // NewChatBubble returns a new chat bubble with the given data.
@@ -35,9 +53,23 @@ func NewChatBubble(role, text string, bubbleColor color.Color, alignRight bool)
 	bg.CornerRadius = 12
 	bg.StrokeWidth = 2
 	bg.StrokeColor = theme.Current().Color(theme.ColorNameForeground, theme.VariantDark)
+	ret.actions = container.NewHBox(
+		widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+			fyne.CurrentApp().Clipboard().SetContent(ret.Text)
+		}),
+	)
+	ret.branch = container.NewHBox()
+	ret.branch.Hide()
 	ret.text = widget.NewRichTextFromMarkdown(ret.Text)
 	ret.text.Wrapping = fyne.TextWrapWord
 	ret.text.Scroll = fyne.ScrollNone
+	ret.footer = widget.NewLabelWithStyle("", fyne.TextAlignTrailing, fyne.TextStyle{
+		Italic: true,
+	})
+	ret.footer.Importance = widget.LowImportance
+	ret.footer.Hide()
+	ret.images = container.NewHBox()
+	ret.images.Hide()
 	ret.c = container.NewStack(
 		bg,
 		container.NewVBox(
@@ -50,18 +82,28 @@ func NewChatBubble(role, text string, bubbleColor color.Color, alignRight bool)
 					},
 				),
 				layout.NewSpacer(),
-				container.NewPadded(
-					widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-						fyne.CurrentApp().Clipboard().SetContent(ret.Text)
-					}),
-				),
+				ret.branch,
+				container.NewPadded(ret.actions),
 			),
+			ret.images,
 			ret.text,
+			ret.footer,
 		),
 	)
 	return ret
 }
 
+// NewErrorBubble returns a new chat bubble showing a completion error.
+func NewErrorBubble(err *llm.Error) *ChatBubble {
+	r, g, b, _ := theme.Color(theme.ColorNameError).RGBA()
+	return NewChatBubble(
+		err.Kind.String(),
+		err.Error(),
+		color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0x60},
+		false,
+	)
+}
+
 // CreateRenderer returns a new renderer for the widget.
 func (w *ChatBubble) CreateRenderer() fyne.WidgetRenderer {
 	var left, right float32
@@ -91,3 +133,60 @@ func (w *ChatBubble) AppendText(text string) {
 		w.Refresh()
 	})
 }
+
+// AddAction adds an icon button to the bubble's header that calls fn when
+// tapped.
+func (w *ChatBubble) AddAction(icon fyne.Resource, fn func()) {
+	w.actions.Add(widget.NewButtonWithIcon("", icon, fn))
+}
+
+// SetImages sets the images shown as thumbnails above the bubble's text.
+func (w *ChatBubble) SetImages(images []*llm.Image) {
+	w.images.RemoveAll()
+	for _, img := range images {
+		w.images.Add(newThumbnail(img))
+	}
+	if len(images) == 0 {
+		w.images.Hide()
+	} else {
+		w.images.Show()
+	}
+}
+
+// SetFooter sets the small text shown under the bubble's text. An empty string
+// hides the footer.
+func (w *ChatBubble) SetFooter(text string) {
+	w.footer.SetText(text)
+	if text == "" {
+		w.footer.Hide()
+	} else {
+		w.footer.Show()
+	}
+}
+
+// SetBranch shows arrows in the bubble's header to navigate between count
+// sibling branches, the one at index being shown. onSelect is called with the
+// index of the branch to show. Less than two branches hide the arrows.
+func (w *ChatBubble) SetBranch(index, count int, onSelect func(i int)) {
+	w.branch.RemoveAll()
+	if count < 2 {
+		w.branch.Hide()
+		return
+	}
+	prev := widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() {
+		onSelect(index - 1)
+	})
+	next := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {
+		onSelect(index + 1)
+	})
+	if index <= 0 {
+		prev.Disable()
+	}
+	if index >= count-1 {
+		next.Disable()
+	}
+	w.branch.Add(prev)
+	w.branch.Add(widget.NewLabel(fmt.Sprintf("%d / %d", index+1, count)))
+	w.branch.Add(next)
+	w.branch.Show()
+}
@@ -0,0 +1,125 @@
+package ui
+
+import "github.com/qbradq/gen-magic/llm"
+
+// turnNode is one turn in the tree of turns of a chat session. Siblings are
+// alternatives continuing the same turn, such as edited prompts and
+// regenerated responses. The root node has no turn.
+type turnNode struct {
+	turn *llm.Turn
+	parent *turnNode
+	children []*turnNode
+	// active is the index of the child on the active branch.
+	active int
+}
+
+// newTurnTree builds the tree of the turns and returns its root. Turns whose
+// parent is not found start new branches at the root. The active branch is the
+// one leading to the turn with the ID active, or to the last turn if there is
+// no such turn.
+func newTurnTree(turns []*llm.Turn, active int64) *turnNode {
+	root := &turnNode{}
+	nodes := map[int64]*turnNode{}
+	var last *turnNode
+	for _, turn := range turns {
+		parent, ok := nodes[turn.ParentID]
+		if !ok {
+			parent = root
+		}
+		last = parent.add(turn)
+		if turn.ID != 0 {
+			nodes[turn.ID] = last
+		}
+	}
+	if n, ok := nodes[active]; ok {
+		n.activate()
+	} else if last != nil {
+		last.activate()
+	}
+	return root
+}
+
+// add adds the turn as the last child of the node, makes it the active child
+// and returns its node.
+func (n *turnNode) add(turn *llm.Turn) *turnNode {
+	ret := &turnNode{
+		turn: turn,
+		parent: n,
+	}
+	n.children = append(n.children, ret)
+	n.active = len(n.children) - 1
+	return ret
+}
+
+// remove removes the child from the node.
+func (n *turnNode) remove(child *turnNode) {
+	for i, c := range n.children {
+		if c == child {
+			n.children = append(n.children[:i], n.children[i+1:]...)
+			break
+		}
+	}
+	if n.active >= len(n.children) {
+		n.active = len(n.children) - 1
+	}
+	if n.active < 0 {
+		n.active = 0
+	}
+}
+
+// activate puts the node on the active branch.
+func (n *turnNode) activate() {
+	for c := n; c.parent != nil; c = c.parent {
+		c.parent.active = c.index()
+	}
+}
+
+// index returns the index of the node among its siblings.
+func (n *turnNode) index() int {
+	if n.parent == nil {
+		return 0
+	}
+	for i, c := range n.parent.children {
+		if c == n {
+			return i
+		}
+	}
+	return 0
+}
+
+// branch returns the nodes of the active branch below the node.
+func (n *turnNode) branch() []*turnNode {
+	ret := []*turnNode{}
+	for c := n; len(c.children) > 0; {
+		c = c.children[c.active]
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+// leaf returns the last node of the active branch below the node, or the node
+// itself if it has no children.
+func (n *turnNode) leaf() *turnNode {
+	c := n
+	for len(c.children) > 0 {
+		c = c.children[c.active]
+	}
+	return c
+}
+
+// path returns the turns from the first turn down to and including the node's.
+func (n *turnNode) path() []*llm.Turn {
+	ret := []*llm.Turn{}
+	for c := n; c.turn != nil; c = c.parent {
+		ret = append([]*llm.Turn{c.turn}, ret...)
+	}
+	return ret
+}
+
+// turnID returns the ID of the node's turn, zero for the root.
+func (n *turnNode) turnID() int64 {
+	if n.turn == nil {
+		return 0
+	}
+	return n.turn.ID
+}
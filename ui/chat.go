@@ -2,8 +2,11 @@ package ui
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"strconv"
+	"strings"
+	"time"
 	"unicode"
 
 	"fyne.io/fyne/v2"
@@ -14,13 +17,17 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/qbradq/gen-magic/llm"
+	"github.com/qbradq/gen-magic/project"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
-// Max number of messages in history.
+// Max number of previous turns sent as context.
 const maxHistory int = 100
 
+// Max length of a chat session name taken from the first prompt.
+const maxSessionName int = 48
+
 // Chat implements the Chat chat interface.
 type Chat struct {
 	w fyne.Window
@@ -30,13 +37,29 @@ type Chat struct {
 	scroll *container.Scroll
 	chat *fyne.Container
 	prompt *PromptEntry
+	attachments []*llm.Image
+	attachBar *fyne.Container
+	attach *widget.Button
 	progress *widget.ProgressBarInfinite
 	submit *widget.Button
 	stop *widget.Button
 	ctxLengthEntry *widget.Entry
-	llms []LLMName
+	toolsCheck *widget.Check
+	truncationSelect *widget.Select
+	gauge *widget.ProgressBar
+	// contextTokens is the estimated number of tokens of the system prompt and
+	// fitted context of the next turn, and contextDropped the number of turns
+	// dropped to fit it.
+	contextTokens int
+	contextDropped int
+	llms []project.LLMName
 	llmSelect *IndexedSelect
-	history []*llm.Turn
+	agent *llm.Agent
+	agents []project.AgentName
+	agentSelect *IndexedSelect
+	params *ParametersEditor
+	tree *turnNode
+	session int64
 	cancelCompletion func()
 }
 
@@ -45,6 +68,7 @@ func NewChat(m *Main, onClose func()) *Chat {
 	ret := &Chat{
 		w: fyne.CurrentApp().NewWindow("Chat"),
 		m: m,
+		tree: &turnNode{},
 	}
 	ret.w.Canvas().AddShortcut(&desktop.CustomShortcut{
 		KeyName: fyne.KeyEnter,
@@ -64,6 +88,14 @@ func NewChat(m *Main, onClose func()) *Chat {
 	ret.prompt.SetMinRowsVisible(5)
 	ret.prompt.SetPlaceHolder("LLM Chat Prompt")
 	ret.prompt.OnCtrlEnter = ret.Submit
+	ret.prompt.OnPaste = ret.onPaste
+	ret.prompt.OnChanged = func(s string) {
+		ret.updateGauge()
+	}
+	ret.attachBar = container.NewHBox()
+	ret.attachBar.Hide()
+	ret.attach = widget.NewButtonWithIcon("", theme.Icon(theme.IconNameFileImage), ret.showAttachDialog)
+	ret.w.SetOnDropped(ret.onDropped)
 	ret.progress = widget.NewProgressBarInfinite()
 	ret.progress.Hide()
 	ret.submit = widget.NewButtonWithIcon("", theme.Icon(theme.IconNameMediaPlay), ret.Submit)
@@ -84,6 +116,7 @@ func NewChat(m *Main, onClose func()) *Chat {
 			v = int64(maxHistory)
 		}
 		ret.ctxLengthEntry.SetText(strconv.FormatInt(v, 10))
+		ret.refreshTokens()
 	}
 	ret.ctxLengthEntry.Validator = func(s string) error {
 		for _, r := range s {
@@ -94,6 +127,13 @@ func NewChat(m *Main, onClose func()) *Chat {
 		return nil
 	}
 	ret.ctxLengthEntry.SetText("5")
+	ret.toolsCheck = widget.NewCheck("Tools", nil)
+	ret.truncationSelect = widget.NewSelect(llm.TruncationStrategies, func(s string) {
+		ret.m.p.SetStringSetting("chat.truncation", s)
+		ret.refreshTokens()
+	})
+	ret.gauge = widget.NewProgressBar()
+	ret.gauge.TextFormatter = ret.gaugeText
 	ret.stop = widget.NewButtonWithIcon("", theme.Icon(theme.IconNameMediaStop), func() {
 		if ret.cancelCompletion != nil {
 			ret.cancelCompletion()
@@ -103,12 +143,34 @@ func NewChat(m *Main, onClose func()) *Chat {
 	ret.llmSelect = NewIndexedSelect(nil, func(idx int) {
 		llm := ret.llms[idx]
 		ret.def = *ret.m.p.GetLLM(llm.ID)
+		ret.refreshTokens()
+	})
+	ret.agentSelect = NewIndexedSelect(nil, func(idx int) {
+		ret.selectAgent(ret.agents[idx].ID)
 	})
 	ret.OnLLMsUpdated()
+	ret.OnAgentsUpdated()
+	// Per-turn overrides of the sampling parameters of the LLM
+	ret.params = NewParametersEditor(func(params llm.Parameters) {
+		ret.refreshTokens()
+	})
+	paramsAccordion := widget.NewAccordion(widget.NewAccordionItem(
+		"Parameter Overrides",
+		widget.NewForm(ret.params.FormItems()...),
+	))
 	ret.root = container.NewPadded(
 		container.NewBorder(
 			nil,
 			container.NewVBox(
+				paramsAccordion,
+				container.NewHScroll(ret.attachBar),
+				container.NewBorder(nil, nil, nil,
+					container.NewHBox(
+						widget.NewLabel("Truncation"),
+						ret.truncationSelect,
+					),
+					ret.gauge,
+				),
 				container.NewStack(
 					ret.prompt,
 					container.NewCenter(
@@ -123,6 +185,7 @@ func NewChat(m *Main, onClose func()) *Chat {
 				),
 				container.NewBorder(nil, nil, nil,
 					container.NewHBox(
+						ret.toolsCheck,
 						widget.NewLabel("CTX"),
 						container.New(
 							layout.NewGridWrapLayout(fyne.NewSize(
@@ -131,10 +194,24 @@ func NewChat(m *Main, onClose func()) *Chat {
 							)),
 							ret.ctxLengthEntry,
 						),
+						widget.NewButtonWithIcon("", theme.UploadIcon(), func() {
+							ret.exportRequest(ret.tree.leaf(), ret.definition(), ret.systemMessage(), &llm.Message{
+								Role: "user",
+								Content: ret.prompt.Text,
+								Images: ret.attachments,
+							})
+						}),
+						widget.NewButtonWithIcon("", theme.DocumentIcon(), func() {
+							NewPromptLibrary(ret.m, ret.insertPrompt)
+						}),
+						ret.attach,
 						ret.stop,
 						ret.submit,
 					),
-					ret.llmSelect,
+					container.NewGridWithColumns(2,
+						ret.agentSelect,
+						ret.llmSelect,
+					),
 				),
 			),
 			nil,
@@ -142,6 +219,7 @@ func NewChat(m *Main, onClose func()) *Chat {
 			ret.scroll,
 		),
 	)
+	ret.truncationSelect.SetSelected(m.p.StringSetting("chat.truncation", llm.TruncateDropOldest))
 	ret.w.SetContent(ret.root)
 	ret.w.RequestFocus()
 	ret.Focus()
@@ -159,9 +237,50 @@ func (l *Chat) Close() {
 // Submit submits the current prompt if able.
 func (l *Chat) Submit() {
 	promptText := l.prompt.Text
-	if promptText == "" {
+	if promptText == "" && len(l.attachments) == 0 {
+		return
+	}
+	if provider := llm.GetProvider(strings.ToLower(l.def.API)); len(l.attachments) > 0 && provider != nil && !provider.Capabilities().Images {
+		dialog.ShowInformation(
+			"Images Not Supported",
+			provider.Name()+" does not accept image attachments.",
+			l.w,
+		)
 		return
 	}
+	prompt := &llm.Message{
+		Role: "user",
+		Content: promptText,
+		Images: l.attachments,
+	}
+	if !l.startTurn(l.tree.leaf(), prompt, l.definition()) {
+		return
+	}
+	l.prompt.SetText("")
+	l.attachments = nil
+	l.refreshAttachments()
+}
+
+// insertPrompt appends the text to the prompt being written.
+func (l *Chat) insertPrompt(text string) {
+	if l.prompt.Text != "" && !strings.HasSuffix(l.prompt.Text, "\n") {
+		text = "\n" + text
+	}
+	l.prompt.SetText(l.prompt.Text + text)
+	l.w.RequestFocus()
+	l.Focus()
+}
+
+// definition returns the LLM definition for new turns with the parameter
+// overrides applied.
+func (l *Chat) definition() llm.LanguageModel {
+	def := l.def
+	def.Parameters = def.Parameters.Merge(l.params.Parameters())
+	return def
+}
+
+// contextLength returns the number of previous turns sent as context.
+func (l *Chat) contextLength() int {
 	v, err := strconv.ParseInt(l.ctxLengthEntry.Text, 10, 32)
 	if err != nil {
 		log.Printf("error while parsing chat context length: %v", err)
@@ -170,54 +289,219 @@ func (l *Chat) Submit() {
 			l.ctxLengthEntry.SetText("0")
 		})
 	}
-	ctxLength := int(v)
-	promptBubble := l.LogPrompt()
-	l.prompt.SetText("")
-	lh := len(l.history)
-	ctxBegin := lh-(ctxLength+1)
-	if ctxBegin < 0 {
-		ctxBegin = 0
+	return int(v)
+}
+
+// turnContext returns the context of a turn continuing the parent node.
+func (l *Chat) turnContext(parent *turnNode) []*llm.Turn {
+	ret := parent.path()
+	if begin := len(ret) - (l.contextLength() + 1); begin > 0 {
+		ret = ret[begin:]
+	}
+	return ret
+}
+
+// startTurn starts a new turn continuing the parent node, makes it the active
+// branch and shows it. False is returned if the completion could not be
+// started, in which case the turn is discarded.
+func (l *Chat) startTurn(parent *turnNode, prompt *llm.Message, def llm.LanguageModel) bool {
+	if l.cancelCompletion != nil {
+		return false
 	}
-	ctx := l.history[ctxBegin:]
 	turn := &llm.Turn{
-		Definition: l.def,
-		System: &llm.Message{
-			Role: "system",
-			Content: "You are a helpful AI assistant.",
-		},
-		Prompt: &llm.Message{
-			Role: "user",
-			Content: promptText,
-		},
+		ParentID: parent.turnID(),
+		Definition: def,
+		System: l.systemMessage(),
+		Prompt: prompt,
+	}
+	strategy := l.truncationSelect.Selected
+	fit := llm.FitContext(&turn.Definition, turn.System, turn.Prompt, l.turnContext(parent), strategy)
+	active := parent.active
+	node := parent.add(turn)
+	l.render()
+	if strategy == llm.TruncateSummarizeOlder && len(fit.Dropped) > 0 {
+		l.summarize(node, active, fit)
+		return true
+	}
+	return l.launch(node, active, fit.Turns)
+}
+
+// launch starts the completion of the node's new turn with the context and
+// makes it the session's active turn. If the completion could not be started
+// the node is removed and the parent's active child reset to active.
+func (l *Chat) launch(node *turnNode, active int, ctx []*llm.Turn) bool {
+	if !l.complete(node.turn, ctx) {
+		node.parent.remove(node)
+		node.parent.active = active
+		l.render()
+		return false
+	}
+	l.saveActiveTurn()
+	return true
+}
+
+// summarize replaces the turns dropped from the fitted context with their
+// summary and then launches the node's new turn. Summarizing may be stopped,
+// which discards the new turn.
+func (l *Chat) summarize(node *turnNode, active int, fit *llm.ContextFit) {
+	canceled := false
+	l.cancelCompletion = func() {
+		canceled = true
+	}
+	l.stop.Enable()
+	l.submit.Disable()
+	l.prompt.Disable()
+	l.progress.Show()
+	def := node.turn.Definition
+	system := node.turn.System
+	go func() {
+		summary, err := llm.Summarize(&def, system, fit.Dropped)
+		fyne.Do(func() {
+			l.cancelCompletion = nil
+			l.stop.Disable()
+			l.submit.Enable()
+			l.prompt.Enable()
+			l.progress.Hide()
+			if canceled {
+				node.parent.remove(node)
+				node.parent.active = active
+				l.render()
+				return
+			}
+			ctx := fit.Turns
+			if err != nil {
+				log.Printf("error summarizing older chat turns: %v\n", err)
+			} else {
+				ctx = append([]*llm.Turn{summary}, ctx...)
+			}
+			l.launch(node, active, ctx)
+		})
+	}()
+}
+
+// systemMessage returns the system prompt of new turns, rendering the
+// agent's system prompt template.
+func (l *Chat) systemMessage() *llm.Message {
+	system := "You are a helpful AI assistant."
+	if l.agent != nil {
+		system = l.m.p.ResolveAgent(l.agent).System.Content
+	}
+	return &llm.Message{
+		Role: "system",
+		Content: system,
+	}
+}
+
+// refreshTokens counts the tokens of the system prompt and the fitted context
+// of the next turn and updates the token gauge.
+func (l *Chat) refreshTokens() {
+	// Inputs call this while the window is being built
+	if l.params == nil || l.tree == nil {
+		return
+	}
+	def := l.definition()
+	fit := llm.FitContext(&def, l.systemMessage(), nil, l.turnContext(l.tree.leaf()), l.truncationSelect.Selected)
+	l.contextTokens = fit.Tokens
+	l.contextDropped = len(fit.Dropped)
+	l.updateGauge()
+}
+
+// updateGauge updates the token gauge with the tokens of the prompt being
+// written.
+func (l *Chat) updateGauge() {
+	if l.gauge == nil {
+		return
 	}
-	msgs, cancel, err := llm.ChatCompletion(&turn.Definition, turn.System, turn.Prompt, ctx)
+	// Models with unknown context windows only show the token count
+	value := 0.0
+	if window := l.def.ContextWindow(); window > 0 {
+		value = min(1, float64(l.nextTokens())/float64(window))
+	}
+	l.gauge.SetValue(value)
+}
+
+// nextTokens returns the estimated number of tokens of the next turn's request.
+func (l *Chat) nextTokens() int {
+	t := llm.TokenizerFor(&l.def)
+	return l.contextTokens + llm.CountMessage(t, &llm.Message{
+		Content: l.prompt.Text,
+		Images: l.attachments,
+	})
+}
+
+// gaugeText returns the text of the token gauge.
+func (l *Chat) gaugeText() string {
+	ret := fmt.Sprintf("%d tokens", l.nextTokens())
+	if window := l.def.ContextWindow(); window > 0 {
+		ret = fmt.Sprintf("%d / %d tokens", l.nextTokens(), window)
+	}
+	if l.contextDropped > 0 {
+		ret += fmt.Sprintf(" (%d turns truncated)", l.contextDropped)
+	}
+	return ret
+}
+
+// complete starts the chat completion for the turn and streams the response
+// into the chat log. The turn is saved with only its prompt before the stream
+// starts and again when it has finished. The branch is shown again with the
+// finished turn, from whose error bubble the turn may be retried if it failed.
+// False is returned if the completion could not be started at all.
+func (l *Chat) complete(turn *llm.Turn, ctx []*llm.Turn) bool {
+	var tools []*llm.Tool
+	if l.toolsCheck.Checked {
+		tools = llm.Tools()
+	}
+	maxIterations := l.m.p.IntSetting("chat.max-tool-iterations", llm.DefaultMaxToolIterations)
+	turn.Started = time.Now()
+	events, cancel, err := llm.RunTools(&turn.Definition, turn.System, turn.Prompt, ctx, tools, maxIterations)
 	if err != nil {
-		l.chat.Remove(promptBubble)
 		dialog.ShowInformation(
 			"Completion Error",
 			err.Error(),
 			l.w,
 		)
-		return
+		return false
 	}
+	l.cancelCompletion = cancel
+	// Events are only applied to the turn on the UI thread after this, so it
+	// cannot change while being saved here
+	l.saveTurn(turn)
 	go func() {
 		fyne.Do(func() {
-			l.cancelCompletion = cancel
 			l.stop.Enable()
 			l.submit.Disable()
 			l.prompt.Disable()
 			l.progress.Show()
 		})
 		var bubble *ChatBubble
-		for msg := range msgs {
-			if !msg.Delta || bubble == nil {
-				turn.Response = append(turn.Response, msg)
-				bubble = l.LogResponse(msg)
-			} else {
-				turn.Response[len(turn.Response)-1].Content += msg.Content;
-				bubble.AppendText(msg.Content)
-				l.scroll.ScrollToBottom()
-			}
+		var reasoning *ChatBubble
+		for e := range events {
+			// Events are applied on the UI thread along with the chat log
+			// updates so that the turn is never changed while it is shown or
+			// saved
+			fyne.Do(func() {
+				turn.Apply(e)
+				switch e.Type {
+				case llm.EventContent:
+					if bubble == nil {
+						bubble = l.LogResponse(turn.Response[len(turn.Response)-1])
+					} else {
+						bubble.AppendText(e.Text)
+						l.scroll.ScrollToBottom()
+					}
+				case llm.EventToolResult:
+					// Responses after tool results go in a new bubble
+					l.LogToolCall(e.ToolResult.Call, e.ToolResult.Content)
+					bubble = nil
+				case llm.EventReasoning:
+					if reasoning == nil {
+						reasoning = l.LogReasoning(e.Text)
+					} else {
+						reasoning.AppendText(e.Text)
+						l.scroll.ScrollToBottom()
+					}
+				}
+			})
 		}
 		fyne.Do(func() {
 			l.cancelCompletion = nil
@@ -225,23 +509,282 @@ func (l *Chat) Submit() {
 			l.submit.Enable()
 			l.prompt.Enable()
 			l.progress.Hide()
+			l.saveTurn(turn)
+			// Show the finished turn with its actions
+			l.render()
 		})
 	}()
-	l.history = append(l.history, turn)
-	if lh > maxHistory {
-		l.history = l.history[lh - maxHistory:]
+	return true
+}
+
+// saveTurn saves the turn to the chat session, creating the session with the
+// first turn.
+func (l *Chat) saveTurn(turn *llm.Turn) {
+	if l.session == 0 {
+		var agentID int64
+		if l.agent != nil {
+			agentID = l.agent.ID
+		}
+		name := strings.Join(strings.Fields(turn.Prompt.Content), " ")
+		if name == "" {
+			name = "Untitled Chat"
+		}
+		if r := []rune(name); len(r) > maxSessionName {
+			name = string(r[:maxSessionName-3]) + "..."
+		}
+		id, err := l.m.p.NewSession(name, agentID)
+		if err != nil {
+			log.Printf("error creating chat session: %v\n", err)
+			return
+		}
+		l.session = id
+		l.w.SetTitle("Chat - " + name)
+	}
+	if err := l.m.p.SaveTurn(l.session, turn); err != nil {
+		log.Printf("error saving chat turn: %v\n", err)
+	}
+}
+
+// LoadSession replaces the chat log with the saved chat session.
+func (l *Chat) LoadSession(id int64) error {
+	session, err := l.m.p.LoadSession(id)
+	if err != nil {
+		return err
+	}
+	l.session = session.ID
+	l.w.SetTitle("Chat - " + session.Name)
+	l.tree = newTurnTree(session.Turns, session.ActiveTurn)
+	l.render()
+	for i, agent := range l.agents {
+		if agent.ID == session.AgentID {
+			l.agentSelect.SetSelectedIndex(i)
+			break
+		}
+	}
+	return nil
+}
+
+// saveActiveTurn saves the last turn of the active branch of the session.
+func (l *Chat) saveActiveTurn() {
+	id := l.tree.leaf().turnID()
+	if l.session == 0 || id == 0 {
+		return
+	}
+	if err := l.m.p.SetActiveTurn(l.session, id); err != nil {
+		log.Printf("error saving active chat turn: %v\n", err)
+	}
+}
+
+// render replaces the chat log with the turns of the active branch.
+func (l *Chat) render() {
+	l.chat.RemoveAll()
+	for _, node := range l.tree.branch() {
+		l.logNode(node)
+	}
+	l.scroll.ScrollToBottom()
+	l.refreshTokens()
+}
+
+// exportRequest shows the request a turn continuing the parent node would send
+// with the definition, system prompt and prompt. Turns dropped from the
+// context are left out even if they would be summarized.
+func (l *Chat) exportRequest(parent *turnNode, def llm.LanguageModel, system, prompt *llm.Message) {
+	fit := llm.FitContext(&def, system, prompt, l.turnContext(parent), l.truncationSelect.Selected)
+	var tools []*llm.ToolDefinition
+	if l.toolsCheck.Checked {
+		tools = llm.ToolDefinitions(llm.Tools())
+	}
+	r, err := llm.BuildRequest(&def, system, prompt, fit.Turns, tools)
+	if err != nil {
+		dialog.ShowInformation(
+			"Export Error",
+			err.Error(),
+			l.w,
+		)
+		return
+	}
+	NewRequestExport(l.m, r, def.APIKey)
+}
+
+// turnDefinition returns the definition the turn was completed with. The
+// endpoint and credentials of saved turns are taken from the project's LLM
+// definition as they are not saved with the turn.
+func (l *Chat) turnDefinition(turn *llm.Turn) llm.LanguageModel {
+	def := turn.Definition
+	for _, n := range l.llms {
+		if n.ID != def.ID {
+			continue
+		}
+		ret := *l.m.p.GetLLM(n.ID)
+		ret.API = def.API
+		ret.Model = def.Model
+		ret.Parameters = def.Parameters
+		return ret
+	}
+	return def
+}
+
+// logNode adds the bubbles of the node's turn to the chat log along with the
+// actions to edit the prompt and export its request, regenerate and inspect
+// the response, retry a failed turn and navigate between sibling branches.
+func (l *Chat) logNode(node *turnNode) {
+	prompt, response, errBubble := l.logTurn(node.turn)
+	if prompt != nil {
+		prompt.AddAction(theme.DocumentCreateIcon(), func() {
+			l.showEditPrompt(node)
+		})
+		prompt.AddAction(theme.UploadIcon(), func() {
+			l.exportRequest(node.parent, l.turnDefinition(node.turn), node.turn.System, node.turn.Prompt)
+		})
+		prompt.SetBranch(node.index(), len(node.parent.children), func(i int) {
+			l.selectBranch(node.parent, i)
+		})
+	}
+	if response != nil {
+		response.AddAction(theme.ViewRefreshIcon(), func() {
+			l.showRegenerate(node)
+		})
+		response.AddAction(theme.InfoIcon(), func() {
+			NewInspector(l.m, node.turn)
+		})
+	}
+	if errBubble != nil {
+		errBubble.AddAction(theme.ViewRefreshIcon(), func() {
+			l.retry(node)
+		})
+		errBubble.AddAction(theme.InfoIcon(), func() {
+			NewInspector(l.m, node.turn)
+		})
+	}
+}
+
+// selectBranch makes the child at index i of the parent node the active
+// branch and shows it.
+func (l *Chat) selectBranch(parent *turnNode, i int) {
+	if l.cancelCompletion != nil || i < 0 || i >= len(parent.children) {
+		return
+	}
+	parent.active = i
+	l.saveActiveTurn()
+	l.render()
+}
+
+// retry runs the node's failed turn again in place with the endpoint and
+// credentials of the project's LLM definition, as regenerate does.
+func (l *Chat) retry(node *turnNode) {
+	if l.cancelCompletion != nil {
+		return
+	}
+	node.turn.Definition = l.turnDefinition(node.turn)
+	node.turn.Reset()
+	l.render()
+	l.complete(node.turn, l.turnContext(node.parent))
+}
+
+// showEditPrompt shows a dialog to edit the node's prompt and submit it as a
+// new branch.
+func (l *Chat) showEditPrompt(node *turnNode) {
+	if l.cancelCompletion != nil {
+		return
+	}
+	entry := widget.NewEntry()
+	entry.MultiLine = true
+	entry.Wrapping = fyne.TextWrapWord
+	entry.SetMinRowsVisible(8)
+	entry.SetText(node.turn.Prompt.Content)
+	dlg := dialog.NewForm("Edit Prompt", "Submit", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Prompt", entry),
+	}, func(ok bool) {
+		if !ok || (entry.Text == "" && len(node.turn.Prompt.Images) == 0) {
+			return
+		}
+		prompt := &llm.Message{
+			Role: "user",
+			Content: entry.Text,
+			Images: node.turn.Prompt.Images,
+		}
+		l.startTurn(node.parent, prompt, l.definition())
+	}, l.w)
+	dlg.Resize(fyne.NewSize(640, 360))
+	dlg.Show()
+}
+
+// showRegenerate shows a dialog to choose the LLM with which to regenerate the
+// node's response as a new branch.
+func (l *Chat) showRegenerate(node *turnNode) {
+	if l.cancelCompletion != nil || len(l.llms) == 0 {
+		return
+	}
+	names := []string{}
+	idx := l.llmSelect.SelectedIndex()
+	for i, n := range l.llms {
+		names = append(names, n.Name)
+		if n.ID == node.turn.Definition.ID {
+			idx = i
+		}
+	}
+	llmSelect := NewIndexedSelect(names, nil)
+	llmSelect.rawSetSelectedIndex(idx)
+	dialog.ShowForm("Regenerate Response", "Regenerate", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("LLM", llmSelect),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		def := *l.m.p.GetLLM(l.llms[llmSelect.SelectedIndex()].ID)
+		def.Parameters = def.Parameters.Merge(l.params.Parameters())
+		prompt := *node.turn.Prompt
+		l.startTurn(node.parent, &prompt, def)
+	}, l.w)
+}
+
+// logTurn adds all of the bubbles of a turn to the chat log and returns the
+// bubbles of the prompt, the last response and the error, any of which may be
+// nil.
+func (l *Chat) logTurn(turn *llm.Turn) (prompt, response, errBubble *ChatBubble) {
+	if turn.Prompt != nil {
+		prompt = l.LogPrompt(turn.Prompt)
+	}
+	if turn.Reasoning != "" {
+		l.LogReasoning(turn.Reasoning)
+	}
+	calls := map[string]*llm.ToolCall{}
+	for _, msg := range turn.Response {
+		for _, call := range msg.ToolCalls {
+			calls[call.ID] = call
+		}
+		switch {
+		case msg.Role == "tool":
+			call := calls[msg.ToolCallID]
+			if call == nil {
+				call = &llm.ToolCall{
+					ID: msg.ToolCallID,
+					Name: msg.Name,
+				}
+			}
+			l.LogToolCall(call, msg.Content)
+		case msg.Content != "":
+			response = l.LogResponse(msg)
+		}
+	}
+	if response != nil {
+		response.SetFooter(turnSummary(turn))
 	}
+	if turn.Error != nil {
+		errBubble = l.LogError(turn.Error)
+	}
+	return prompt, response, errBubble
 }
 
-// LogPrompt adds the prompt to the chat log.
-func (l *Chat) LogPrompt() *ChatBubble {
-	s := l.prompt.Text
+// LogPrompt adds the prompt message to the chat log.
+func (l *Chat) LogPrompt(msg *llm.Message) *ChatBubble {
 	bubble := NewChatBubble(
 		cases.Title(language.AmericanEnglish).String("user"),
-		s,
+		msg.Content,
 		theme.Color(theme.ColorNameInputBackground),
 		true,
 	)
+	bubble.SetImages(msg.Images)
 	l.chat.Add(bubble)
 	l.scroll.ScrollToBottom()
 	return bubble
@@ -260,6 +803,40 @@ func (l *Chat) LogResponse(msg *llm.Message) *ChatBubble {
 	return bubble
 }
 
+// LogReasoning adds the reasoning text of a response to the chat log.
+func (l *Chat) LogReasoning(text string) *ChatBubble {
+	bubble := NewChatBubble(
+		"Reasoning",
+		text,
+		theme.Color(theme.ColorNameBackground),
+		false,
+	)
+	l.chat.Add(bubble)
+	l.scroll.ScrollToBottom()
+	return bubble
+}
+
+// LogToolCall adds a tool call and its result to the chat log.
+func (l *Chat) LogToolCall(call *llm.ToolCall, result string) *ChatBubble {
+	bubble := NewChatBubble(
+		"Tool: "+call.Name,
+		"```json\n"+call.Arguments+"\n```\n\n"+result,
+		theme.Color(theme.ColorNameDisabledButton),
+		false,
+	)
+	l.chat.Add(bubble)
+	l.scroll.ScrollToBottom()
+	return bubble
+}
+
+// LogError adds a completion error to the chat log.
+func (l *Chat) LogError(err *llm.Error) *ChatBubble {
+	bubble := NewErrorBubble(err)
+	l.chat.Add(bubble)
+	l.scroll.ScrollToBottom()
+	return bubble
+}
+
 // Root returns the root container.
 func (l *Chat) Root() *fyne.Container {
 	return l.root
@@ -285,3 +862,68 @@ func (l *Chat) OnLLMsUpdated() {
 		l.llmSelect.SetSelectedIndex(0)
 	}
 }
+
+// turnSummary returns a one-line summary of the model, finish reason, token
+// usage, cost and latency of the turn.
+func turnSummary(turn *llm.Turn) string {
+	parts := []string{}
+	if turn.Model != "" {
+		parts = append(parts, turn.Model)
+	}
+	if turn.FinishReason != "" {
+		parts = append(parts, turn.FinishReason)
+	}
+	if u := turn.Usage; u != nil {
+		parts = append(parts, fmt.Sprintf("%d prompt / %d completion tokens",
+			u.PromptTokens, u.CompletionTokens))
+		if u.ReasoningTokens > 0 {
+			parts = append(parts, fmt.Sprintf("%d reasoning", u.ReasoningTokens))
+		}
+		if u.CachedTokens > 0 {
+			parts = append(parts, fmt.Sprintf("%d cached", u.CachedTokens))
+		}
+		if u.Cost > 0 {
+			parts = append(parts, formatCost(u.Cost))
+		}
+	}
+	if turn.Latency > 0 {
+		s := fmt.Sprintf("%.2fs", turn.Latency.Seconds())
+		if turn.FirstTokenLatency > 0 {
+			s += fmt.Sprintf(" (first token %.2fs)", turn.FirstTokenLatency.Seconds())
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// selectAgent loads the agent by ID and selects its LLM.
+func (l *Chat) selectAgent(id int64) {
+	l.agent = l.m.p.GetAgent(id)
+	l.def = *l.agent.LLM
+	for i, name := range l.llms {
+		if name.ID == l.agent.LLM.ID {
+			l.llmSelect.rawSetSelectedIndex(i)
+			break
+		}
+	}
+	l.refreshTokens()
+}
+
+// OnAgentsUpdated is called when the agent list is updated.
+func (l *Chat) OnAgentsUpdated() {
+	list := []string{}
+	idx := 0
+	l.agents = l.m.p.ListAgents()
+	for i, agent := range l.agents {
+		list = append(list, agent.Name)
+		if l.agent != nil && agent.ID == l.agent.ID {
+			idx = i
+		}
+	}
+	l.agentSelect.SetOptions(list)
+	if len(l.agents) == 0 {
+		l.agent = nil
+		return
+	}
+	l.agentSelect.SetSelectedIndex(idx)
+}
@@ -0,0 +1,395 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/qbradq/gen-magic/llm"
+	"github.com/qbradq/gen-magic/project"
+)
+
+// Min and max number of columns in the Compare window.
+const (
+	minCompareColumns int = 2
+	maxCompareColumns int = 4
+)
+
+// Compare implements the side-by-side model comparison window. One prompt is
+// sent to several LLM definitions concurrently with the same system prompt and
+// context, and each answer is streamed into its own column.
+type Compare struct {
+	w fyne.Window
+	m *Main
+	llms []project.LLMName
+	sessions []project.SessionName
+	system *widget.Entry
+	contextSelect *IndexedSelect
+	countSelect *widget.Select
+	grid *fyne.Container
+	columns []*compareColumn
+	prompt *PromptEntry
+	progress *widget.ProgressBarInfinite
+	submit *widget.Button
+	stop *widget.Button
+	running int
+}
+
+// compareColumn is one column of the Compare window.
+type compareColumn struct {
+	llmSelect *IndexedSelect
+	def *llm.LanguageModel
+	content *fyne.Container
+	bubble *ChatBubble
+	diff *widget.RichText
+	tabs *container.AppTabs
+	turn *llm.Turn
+	cancel func()
+	start time.Time
+	firstToken time.Duration
+	total time.Duration
+}
+
+// NewCompare returns a new Compare window.
+func NewCompare(m *Main) *Compare {
+	ret := &Compare{
+		w: fyne.CurrentApp().NewWindow("Compare"),
+		m: m,
+	}
+	ret.w.Canvas().AddShortcut(&desktop.CustomShortcut{
+		KeyName: fyne.KeyEnter,
+		Modifier: fyne.KeyModifierControl,
+	}, nil)
+	ret.w.SetOnClosed(func() {
+		ret.Close()
+	})
+	ret.system = widget.NewEntry()
+	ret.system.MultiLine = true
+	ret.system.SetMinRowsVisible(2)
+	ret.system.SetPlaceHolder("Shared System Prompt")
+	ret.system.SetText("You are a helpful AI assistant.")
+	ret.contextSelect = NewIndexedSelect(nil, nil)
+	ret.countSelect = widget.NewSelect(nil, func(s string) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return
+		}
+		ret.setColumnCount(n)
+	})
+	for i := minCompareColumns; i <= maxCompareColumns; i++ {
+		ret.countSelect.Options = append(ret.countSelect.Options, strconv.Itoa(i))
+	}
+	ret.grid = container.NewGridWithColumns(minCompareColumns)
+	ret.prompt = NewPromptEntry()
+	ret.prompt.MultiLine = true
+	ret.prompt.SetMinRowsVisible(4)
+	ret.prompt.SetPlaceHolder("Prompt sent to every LLM")
+	ret.prompt.OnCtrlEnter = ret.Submit
+	ret.progress = widget.NewProgressBarInfinite()
+	ret.progress.Hide()
+	ret.submit = widget.NewButtonWithIcon("", theme.Icon(theme.IconNameMediaPlay), ret.Submit)
+	ret.stop = widget.NewButtonWithIcon("", theme.Icon(theme.IconNameMediaStop), func() {
+		for _, c := range ret.columns {
+			if c.cancel != nil {
+				c.cancel()
+			}
+		}
+	})
+	ret.stop.Disable()
+	ret.OnLLMsUpdated()
+	ret.refreshSessions()
+	ret.countSelect.SetSelected(strconv.Itoa(minCompareColumns))
+	ret.w.SetContent(container.NewPadded(
+		container.NewBorder(
+			container.NewVBox(
+				ret.system,
+				container.NewBorder(nil, nil,
+					widget.NewLabel("Context"),
+					container.NewHBox(
+						widget.NewLabel("Columns"),
+						ret.countSelect,
+					),
+					ret.contextSelect,
+				),
+			),
+			container.NewBorder(nil, nil, nil,
+				container.NewVBox(ret.stop, ret.submit),
+				container.NewStack(
+					ret.prompt,
+					container.NewCenter(
+						container.NewGridWrap(
+							fyne.NewSize(
+								240,
+								ret.progress.MinSize().Height,
+							),
+							ret.progress,
+						),
+					),
+				),
+			),
+			nil,
+			nil,
+			ret.grid,
+		),
+	))
+	ret.w.Resize(fyne.NewSize(1200, 720))
+	ret.w.Show()
+	ret.m.AddChild(ret)
+	return ret
+}
+
+// Close closes the window.
+func (c *Compare) Close() {
+	for _, col := range c.columns {
+		if col.cancel != nil {
+			col.cancel()
+		}
+	}
+	c.w.Close()
+	c.m.RemoveChild(c)
+}
+
+// llmNames returns the names of all LLM definitions.
+func (c *Compare) llmNames() []string {
+	ret := []string{}
+	for _, n := range c.llms {
+		ret = append(ret, n.Name)
+	}
+	return ret
+}
+
+// newColumn returns a new, empty column that uses the LLM definition at index
+// idx.
+func (c *Compare) newColumn(idx int) *compareColumn {
+	col := &compareColumn{}
+	col.llmSelect = NewIndexedSelect(c.llmNames(), func(i int) {
+		col.def = c.m.p.GetLLM(c.llms[i].ID)
+	})
+	col.content = container.NewVBox()
+	col.diff = widget.NewRichText()
+	col.diff.Wrapping = fyne.TextWrapWord
+	col.tabs = container.NewAppTabs(
+		container.NewTabItem("Response", container.NewVScroll(col.content)),
+		container.NewTabItem("Diff", container.NewVScroll(col.diff)),
+	)
+	if len(c.llms) > 0 {
+		col.llmSelect.SetSelectedIndex(idx % len(c.llms))
+	}
+	return col
+}
+
+// setColumnCount adds or removes columns so there are n of them.
+func (c *Compare) setColumnCount(n int) {
+	if c.running > 0 {
+		c.countSelect.SetSelected(strconv.Itoa(len(c.columns)))
+		return
+	}
+	for len(c.columns) < n {
+		c.columns = append(c.columns, c.newColumn(len(c.columns)))
+	}
+	c.columns = c.columns[:n]
+	objects := []fyne.CanvasObject{}
+	for _, col := range c.columns {
+		objects = append(objects, container.NewBorder(col.llmSelect, nil, nil, nil, col.tabs))
+	}
+	c.grid.Layout = container.NewGridWithColumns(n).Layout
+	c.grid.Objects = objects
+	c.grid.Refresh()
+}
+
+// refreshSessions refreshes the list of chat sessions offered as context.
+func (c *Compare) refreshSessions() {
+	c.sessions = c.m.p.ListSessions()
+	names := []string{"No Context"}
+	for _, s := range c.sessions {
+		names = append(names, s.Name)
+	}
+	c.contextSelect.SetOptions(names)
+	c.contextSelect.rawSetSelectedIndex(0)
+}
+
+// context returns the turns of the active branch of the chat session
+// selected as context.
+func (c *Compare) context() []*llm.Turn {
+	idx := c.contextSelect.SelectedIndex() - 1
+	if idx < 0 || idx >= len(c.sessions) {
+		return nil
+	}
+	session, err := c.m.p.LoadSession(c.sessions[idx].ID)
+	if err != nil {
+		log.Printf("error loading compare context: %v\n", err)
+		return nil
+	}
+	return session.Branch()
+}
+
+// Submit sends the prompt to the LLM of every column.
+func (c *Compare) Submit() {
+	if c.running > 0 || c.prompt.Text == "" {
+		return
+	}
+	chatContext := c.context()
+	system := &llm.Message{
+		Role: "system",
+		Content: c.system.Text,
+	}
+	prompt := &llm.Message{
+		Role: "user",
+		Content: c.prompt.Text,
+	}
+	c.running = len(c.columns)
+	c.stop.Enable()
+	c.submit.Disable()
+	c.prompt.Disable()
+	c.progress.Show()
+	for _, col := range c.columns {
+		col.content.RemoveAll()
+		col.diff.Segments = nil
+		col.diff.Refresh()
+		col.tabs.SelectIndex(0)
+		col.bubble = nil
+		col.firstToken = 0
+		col.total = 0
+		col.turn = &llm.Turn{
+			System: system,
+			Prompt: prompt,
+		}
+		if col.def == nil {
+			col.turn.Error = &llm.Error{
+				Kind: llm.ErrorKindRequest,
+				Err: errors.New("no LLM selected"),
+			}
+			c.finish(col)
+			continue
+		}
+		col.turn.Definition = *col.def
+		c.complete(col, chatContext)
+	}
+}
+
+// complete streams the completion of one column.
+func (c *Compare) complete(col *compareColumn, chatContext []*llm.Turn) {
+	col.start = time.Now()
+	events, cancel, err := llm.ChatCompletion(&col.turn.Definition, col.turn.System, col.turn.Prompt, chatContext, nil)
+	if err != nil {
+		col.turn.Error = &llm.Error{
+			Kind: llm.ErrorKindRequest,
+			Err: err,
+		}
+		c.finish(col)
+		return
+	}
+	col.cancel = cancel
+	go func() {
+		for e := range events {
+			col.turn.Apply(e)
+			if e.Type != llm.EventContent {
+				continue
+			}
+			text := e.Text
+			fyne.Do(func() {
+				if col.bubble == nil {
+					col.firstToken = time.Since(col.start)
+					col.bubble = NewChatBubble(
+						col.turn.Definition.Name,
+						text,
+						theme.Color(theme.ColorNameBackground),
+						false,
+					)
+					col.content.Add(col.bubble)
+				} else {
+					col.bubble.AppendText(text)
+				}
+			})
+		}
+		fyne.Do(func() {
+			col.total = time.Since(col.start)
+			col.cancel = nil
+			c.finish(col)
+		})
+	}()
+}
+
+// finish shows the result of a finished column and computes the diffs once
+// all columns are finished.
+func (c *Compare) finish(col *compareColumn) {
+	if col.bubble == nil {
+		col.bubble = NewChatBubble(
+			col.turn.Definition.Name,
+			"",
+			theme.Color(theme.ColorNameBackground),
+			false,
+		)
+		col.content.Add(col.bubble)
+	}
+	col.bubble.SetFooter(compareSummary(col))
+	turn := col.turn
+	col.bubble.AddAction(theme.InfoIcon(), func() {
+		NewInspector(c.m, turn)
+	})
+	if col.turn.Error != nil {
+		col.content.Add(NewErrorBubble(col.turn.Error))
+	}
+	c.running--
+	if c.running > 0 {
+		return
+	}
+	c.stop.Disable()
+	c.submit.Enable()
+	c.prompt.Enable()
+	c.progress.Hide()
+	// Diff every column against the first, and the first against the second
+	for i, col := range c.columns {
+		other := c.columns[0]
+		if i == 0 {
+			other = c.columns[1]
+		}
+		col.diff.Segments = diffSegments(wordDiff(other.bubble.Text, col.bubble.Text))
+		col.diff.Refresh()
+		col.tabs.Items[1].Text = "Diff vs " + other.turn.Definition.Name
+		col.tabs.Refresh()
+	}
+}
+
+// compareSummary returns a one-line summary of the latency and usage of a
+// column.
+func compareSummary(col *compareColumn) string {
+	parts := []string{}
+	if col.firstToken > 0 {
+		parts = append(parts, fmt.Sprintf("first token %.2fs", col.firstToken.Seconds()))
+	}
+	if col.total > 0 {
+		parts = append(parts, fmt.Sprintf("total %.2fs", col.total.Seconds()))
+	}
+	if s := turnSummary(col.turn); s != "" {
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// OnLLMsUpdated is called when the LLM list is updated.
+func (c *Compare) OnLLMsUpdated() {
+	c.llms = c.m.p.ListLLMs()
+	names := c.llmNames()
+	for i, col := range c.columns {
+		idx := col.llmSelect.SelectedIndex()
+		col.llmSelect.SetOptions(names)
+		if len(c.llms) == 0 {
+			col.def = nil
+			continue
+		}
+		if col.llmSelect.Selected == "" {
+			idx = i
+		}
+		// Reload the definition as it may have been edited
+		col.llmSelect.SetSelectedIndex(idx % len(c.llms))
+	}
+}
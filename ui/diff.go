@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// maxDiffCells limits the size of the table used to diff two texts. Texts
+// with more word pairs than this are shown as entirely replaced.
+const maxDiffCells int = 4000000
+
+// diffOp is the kind of a diffWord.
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// diffWord is one word of a word-level diff.
+type diffWord struct {
+	Op diffOp
+	Word string
+}
+
+// wordDiff returns the word-level difference between the texts a and b as the
+// longest common subsequence of words with the deletions from a and
+// insertions from b in between.
+func wordDiff(a, b string) []diffWord {
+	aw := strings.Fields(a)
+	bw := strings.Fields(b)
+	n, m := len(aw), len(bw)
+	ret := []diffWord{}
+	if (n+1)*(m+1) > maxDiffCells {
+		for _, w := range aw {
+			ret = append(ret, diffWord{Op: diffDelete, Word: w})
+		}
+		for _, w := range bw {
+			ret = append(ret, diffWord{Op: diffInsert, Word: w})
+		}
+		return ret
+	}
+	// lcs[i][j] is the length of the longest common subsequence of aw[i:]
+	// and bw[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n-1; i >= 0; i-- {
+		for j := m-1; j >= 0; j-- {
+			if aw[i] == bw[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case aw[i] == bw[j]:
+			ret = append(ret, diffWord{Op: diffEqual, Word: aw[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ret = append(ret, diffWord{Op: diffDelete, Word: aw[i]})
+			i++
+		default:
+			ret = append(ret, diffWord{Op: diffInsert, Word: bw[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ret = append(ret, diffWord{Op: diffDelete, Word: aw[i]})
+	}
+	for ; j < m; j++ {
+		ret = append(ret, diffWord{Op: diffInsert, Word: bw[j]})
+	}
+	return ret
+}
+
+// diffSegments returns rich text segments showing a word-level diff. Deleted
+// words are shown in the error color and inserted words in the success color.
+func diffSegments(diff []diffWord) []widget.RichTextSegment {
+	ret := []widget.RichTextSegment{}
+	for i, w := range diff {
+		text := w.Word
+		if i < len(diff)-1 {
+			text += " "
+		}
+		style := widget.RichTextStyleInline
+		switch w.Op {
+		case diffDelete:
+			style.ColorName = theme.ColorNameError
+			style.TextStyle = fyne.TextStyle{Italic: true}
+		case diffInsert:
+			style.ColorName = theme.ColorNameSuccess
+			style.TextStyle = fyne.TextStyle{Bold: true}
+		}
+		ret = append(ret, &widget.TextSegment{
+			Text: text,
+			Style: style,
+		})
+	}
+	return ret
+}
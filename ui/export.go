@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// snippetFiles are the default file names and extensions of saved snippets by
+// format.
+var snippetFiles = map[llm.SnippetFormat][2]string{
+	llm.SnippetCurl: {"request.sh", ".sh"},
+	llm.SnippetGo: {"main.go", ".go"},
+	llm.SnippetPython: {"request.py", ".py"},
+}
+
+// RequestExport implements the window showing an API request as code that
+// can be copied or saved.
+type RequestExport struct {
+	w fyne.Window
+	m *Main
+	r *llm.Request
+	apiKey string
+	format llm.SnippetFormat
+	code *widget.RichText
+}
+
+// NewRequestExport returns a new RequestExport window for the request. The
+// API key is replaced by a placeholder in the code.
+func NewRequestExport(m *Main, r *llm.Request, apiKey string) *RequestExport {
+	ret := &RequestExport{
+		w: fyne.CurrentApp().NewWindow("Export Request"),
+		m: m,
+		r: r,
+		apiKey: apiKey,
+		code: newCodeView(),
+	}
+	ret.w.SetOnClosed(func() {
+		ret.Close()
+	})
+	names := []string{}
+	selected := 0
+	last := m.p.StringSetting("export.format", string(llm.SnippetCurl))
+	for i, f := range llm.SnippetFormats {
+		names = append(names, string(f))
+		if string(f) == last {
+			selected = i
+		}
+	}
+	formatSelect := NewIndexedSelect(names, func(i int) {
+		ret.format = llm.SnippetFormats[i]
+		ret.m.p.SetStringSetting("export.format", string(ret.format))
+		ret.refresh()
+	})
+	ret.w.SetContent(container.NewPadded(container.NewBorder(
+		container.NewBorder(nil, nil, widget.NewLabel("Format"), nil, formatSelect),
+		container.NewBorder(nil, nil,
+			widget.NewLabel("The API key is read from the "+llm.SnippetKeyVariable+" environment variable."),
+			container.NewHBox(
+				widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+					ret.w.Clipboard().SetContent(ret.code.String())
+				}),
+				widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), ret.showSave),
+			),
+		),
+		nil,
+		nil,
+		container.NewScroll(ret.code),
+	)))
+	formatSelect.SetSelectedIndex(selected)
+	ret.w.Resize(fyne.NewSize(800, 640))
+	ret.w.Show()
+	ret.m.AddChild(ret)
+	return ret
+}
+
+// Close closes the window.
+func (n *RequestExport) Close() {
+	n.w.Close()
+	n.m.RemoveChild(n)
+}
+
+// refresh renders the request in the selected format.
+func (n *RequestExport) refresh() {
+	code, err := n.r.Snippet(n.format, n.apiKey)
+	if err != nil {
+		log.Printf("error exporting request: %v\n", err)
+		code = "Error: " + err.Error()
+	}
+	setCode(n.code, code)
+}
+
+// showSave shows a dialog to save the code to a file.
+func (n *RequestExport) showSave() {
+	fileSave := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			log.Printf("error in request export file: %v\n", err)
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+		if _, err := writer.Write([]byte(n.code.String())); err != nil {
+			dialog.ShowError(err, n.w)
+		}
+	}, n.w)
+	file := snippetFiles[n.format]
+	fileSave.SetFileName(file[0])
+	fileSave.SetFilter(storage.NewExtensionFileFilter([]string{file[1]}))
+	fileSave.SetTitleText("Save Request")
+	fileSave.Show()
+}
@@ -0,0 +1,240 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// Inspector implements the window showing the raw HTTP requests and responses
+// of a turn.
+type Inspector struct {
+	w fyne.Window
+	m *Main
+	exchanges []*llm.Exchange
+	request *widget.RichText
+	response *widget.RichText
+	chunks *widget.List
+	chunk *widget.RichText
+	timing *widget.Form
+	x *llm.Exchange
+}
+
+// NewInspector returns a new Inspector window for the turn. The exchanges of
+// saved turns are loaded from the project if the turn does not hold them.
+func NewInspector(m *Main, turn *llm.Turn) *Inspector {
+	ret := &Inspector{
+		w: fyne.CurrentApp().NewWindow("Inspector"),
+		m: m,
+		exchanges: turn.Exchanges,
+	}
+	ret.w.SetOnClosed(func() {
+		ret.Close()
+	})
+	if len(ret.exchanges) == 0 && turn.ID != 0 {
+		var err error
+		if ret.exchanges, err = m.p.LoadExchanges(turn.ID); err != nil {
+			log.Printf("error loading turn exchanges: %v\n", err)
+		}
+	}
+	ret.w.Resize(fyne.NewSize(960, 640))
+	ret.w.Show()
+	ret.m.AddChild(ret)
+	if len(ret.exchanges) == 0 {
+		ret.w.SetContent(container.NewCenter(widget.NewLabel(
+			"No HTTP requests were captured for this turn.",
+		)))
+		return ret
+	}
+	ret.request = newCodeView()
+	ret.response = newCodeView()
+	ret.chunk = newCodeView()
+	ret.chunks = widget.NewList(
+		func() int {
+			if ret.x == nil {
+				return 0
+			}
+			return len(ret.x.Chunks)
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			c := ret.x.Chunks[id]
+			o.(*widget.Label).SetText(fmt.Sprintf("+%s  %s",
+				formatDuration(c.At), strings.Join(strings.Fields(c.Data), " ")))
+		},
+	)
+	ret.chunks.OnSelected = func(id widget.ListItemID) {
+		setCode(ret.chunk, prettyChunk(ret.x.Chunks[id].Data))
+	}
+	ret.timing = widget.NewForm()
+	names := []string{}
+	for i, x := range ret.exchanges {
+		names = append(names, fmt.Sprintf("%d. %s %s → %d", i+1, x.Method, x.URL, x.Status))
+	}
+	exchangeSelect := NewIndexedSelect(names, ret.show)
+	chunkSplit := container.NewHSplit(
+		ret.chunks,
+		container.NewScroll(ret.chunk),
+	)
+	chunkSplit.Offset = 0.4
+	ret.w.SetContent(container.NewPadded(container.NewBorder(
+		exchangeSelect,
+		nil,
+		nil,
+		nil,
+		container.NewAppTabs(
+			container.NewTabItem("Request", ret.codeTab(ret.request)),
+			container.NewTabItem("Response", ret.codeTab(ret.response)),
+			container.NewTabItem("Chunks", chunkSplit),
+			container.NewTabItem("Timing", container.NewVScroll(ret.timing)),
+		),
+	)))
+	exchangeSelect.SetSelectedIndex(len(ret.exchanges) - 1)
+	return ret
+}
+
+// Close closes the window.
+func (n *Inspector) Close() {
+	n.w.Close()
+	n.m.RemoveChild(n)
+}
+
+// codeTab returns the content of a tab showing the code view with a button to
+// copy its text.
+func (n *Inspector) codeTab(code *widget.RichText) fyne.CanvasObject {
+	return container.NewBorder(
+		nil,
+		container.NewHBox(widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+			n.w.Clipboard().SetContent(code.String())
+		})),
+		nil,
+		nil,
+		container.NewScroll(code),
+	)
+}
+
+// show shows the exchange at index i.
+func (n *Inspector) show(i int) {
+	if i < 0 || i >= len(n.exchanges) {
+		return
+	}
+	x := n.exchanges[i]
+	n.x = x
+	request := fmt.Sprintf("%s %s\n\n%s", x.Method, x.URL, formatHTTPHeaders(x.RequestHeaders))
+	if x.RequestBody != "" {
+		request += "\n" + prettyJSON(x.RequestBody)
+	}
+	setCode(n.request, request)
+	response := ""
+	if x.Status != 0 {
+		response = fmt.Sprintf("%d %s\n\n%s", x.Status, http.StatusText(x.Status), formatHTTPHeaders(x.ResponseHeaders))
+		if len(x.Chunks) > 0 {
+			response += "\n" + x.ResponseBody
+		} else {
+			response += "\n" + prettyJSON(x.ResponseBody)
+		}
+	}
+	if x.Error != "" {
+		response += "\nError: " + x.Error
+	}
+	if x.Truncated {
+		response += "\n[Truncated]"
+	}
+	setCode(n.response, response)
+	n.chunks.UnselectAll()
+	n.chunks.Refresh()
+	setCode(n.chunk, "")
+	t := x.Timing
+	wait := t.FirstByte - t.DNS - t.Connect - t.TLS
+	n.timing.Items = nil
+	for _, item := range []struct {
+		name string
+		value string
+	}{
+		{"Started", t.Start.Local().Format("2006-01-02 15:04:05.000")},
+		{"DNS Lookup", formatDuration(t.DNS)},
+		{"TCP Connect", formatDuration(t.Connect)},
+		{"TLS Handshake", formatDuration(t.TLS)},
+		{"Server Wait", formatDuration(max(wait, 0))},
+		{"First Byte", formatDuration(t.FirstByte)},
+		{"Download", formatDuration(max(t.Total-t.FirstByte, 0))},
+		{"Total", formatDuration(t.Total)},
+		{"Chunks", fmt.Sprint(len(x.Chunks))},
+		{"Request Size", fmt.Sprintf("%d bytes", len(x.RequestBody))},
+		{"Response Size", fmt.Sprintf("%d bytes", len(x.ResponseBody))},
+	} {
+		n.timing.Append(item.name, widget.NewLabel(item.value))
+	}
+	n.timing.Refresh()
+}
+
+// newCodeView returns a new rich text widget showing code.
+func newCodeView() *widget.RichText {
+	ret := widget.NewRichText()
+	ret.Wrapping = fyne.TextWrapBreak
+	return ret
+}
+
+// setCode sets the text of a code view.
+func setCode(code *widget.RichText, text string) {
+	code.Segments = []widget.RichTextSegment{&widget.TextSegment{
+		Style: widget.RichTextStyleCodeBlock,
+		Text: text,
+	}}
+	code.Refresh()
+}
+
+// formatHTTPHeaders returns the headers one per line sorted by name.
+func formatHTTPHeaders(h http.Header) string {
+	ret := ""
+	for _, name := range slices.Sorted(maps.Keys(h)) {
+		for _, v := range h[name] {
+			ret += name + ": " + v + "\n"
+		}
+	}
+	return ret
+}
+
+// prettyJSON returns the text indented if it is JSON and unchanged otherwise.
+func prettyJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+// prettyChunk returns the chunk of a streamed response with the JSON data of
+// server-sent events indented.
+func prettyChunk(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			lines[i] = "data: " + prettyJSON(strings.TrimSpace(data))
+		} else {
+			lines[i] = prettyJSON(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatDuration formats a duration in milliseconds.
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1f ms", float64(d)/float64(time.Millisecond))
+}
@@ -1,13 +1,21 @@
 package ui
 
 import (
+	"fmt"
 	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 
+	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/qbradq/gen-magic/llm"
+	"github.com/qbradq/gen-magic/project"
+	"github.com/qbradq/gen-magic/secrets"
 )
 
 // ShowLLMSettings creates and shows a new LLMSettings dialog.
@@ -19,10 +27,18 @@ func ShowLLMSettings(m *Main) {
 	var llmNameEntry *widget.Entry
 	var apiSelect *IndexedSelect
 	var urlEntry *widget.Entry
-	var modelEntry *widget.Entry
+	var modelEntry *widget.SelectEntry
+	var contextLengthEntry *widget.Entry
+	var inputPriceEntry *widget.Entry
+	var outputPriceEntry *widget.Entry
 	var apiKeyEntry *widget.Entry
-	var llms []LLMName
-	var apis []LLMApi
+	var keyStorageSelect *IndexedSelect
+	var keyNameEntry *widget.Entry
+	var headersEntry *widget.Entry
+	var paramsEditor *ParametersEditor
+	var llms []project.LLMName
+	var apis []project.LLMApi
+	backends := secrets.Backends()
 	lastEditedLLM := m.p.IntSetting("llm.last-edited", 0)
 	// Internal functions
 	var refreshLLMList = func() {
@@ -34,6 +50,53 @@ func ShowLLMSettings(m *Main) {
 		llmSelect.SetOptions(llmStrs)
 		llmSelect.rawSetSelectedIndex(lastEditedLLM)
 	}
+	var refreshModels = func() {
+		// Offer the models the API reports, if it is able to
+		if def == nil {
+			return
+		}
+		provider := llm.GetProvider(def.API)
+		if provider == nil || !provider.Capabilities().ListModels {
+			modelEntry.SetOptions(nil)
+			return
+		}
+		d := *def
+		go func() {
+			models, err := llm.ListModels(&d)
+			if err != nil {
+				log.Printf("error listing models: %v\n", err)
+			}
+			fyne.Do(func() {
+				modelEntry.SetOptions(models)
+			})
+		}()
+	}
+	var updateKeyInputs = func() {
+		// Only some backends store keys by name, and environment variables
+		// are never entered here
+		switch def.KeyStorage {
+		case "env":
+			keyNameEntry.SetPlaceHolder("OPENROUTER_API_KEY")
+			keyNameEntry.Enable()
+			apiKeyEntry.Disable()
+		case "keystore":
+			keyNameEntry.SetPlaceHolder("Keystore Entry Name")
+			keyNameEntry.Enable()
+			apiKeyEntry.Enable()
+		default:
+			keyNameEntry.SetPlaceHolder("")
+			keyNameEntry.Disable()
+			apiKeyEntry.Enable()
+		}
+	}
+	var updateContextLength = func() {
+		// Show the known context length of the model when none is set
+		if n := llm.KnownContextLength(def.Model); n > 0 {
+			contextLengthEntry.SetPlaceHolder(fmt.Sprintf("%d (known for model)", n))
+		} else {
+			contextLengthEntry.SetPlaceHolder("Unknown")
+		}
+	}
 	var updateUI = func() {
 		// Set the value of all inputs
 		refreshLLMList()
@@ -48,12 +111,33 @@ func ShowLLMSettings(m *Main) {
 		apiSelect.SetSelectedIndex(apiIdx)
 		urlEntry.SetText(def.APIEndpoint)
 		modelEntry.SetText(def.Model)
+		if def.ContextLength > 0 {
+			contextLengthEntry.SetText(strconv.Itoa(def.ContextLength))
+		} else {
+			contextLengthEntry.SetText("")
+		}
+		updateContextLength()
+		inputPriceEntry.SetText(formatPrice(def.InputPrice))
+		outputPriceEntry.SetText(formatPrice(def.OutputPrice))
 		apiKeyEntry.SetText(def.APIKey)
+		keyIdx := 0
+		for i, b := range backends {
+			if b.ID() == def.KeyStorage {
+				keyIdx = i
+				break
+			}
+		}
+		keyStorageSelect.rawSetSelectedIndex(keyIdx)
+		keyNameEntry.SetText(def.KeyName)
+		updateKeyInputs()
+		headersEntry.SetText(formatHeaders(def.Headers))
+		paramsEditor.SetParameters(def.Parameters)
 	}
 	var save = func() {
 		if def != nil {
 			if err := m.p.SetLLM(def); err != nil {
 				log.Printf("error saving LLM: %v\n", err)
+				dialog.ShowError(err, m.w)
 			}
 		}
 	}
@@ -73,7 +157,11 @@ func ShowLLMSettings(m *Main) {
 	})
 	f.Append("LLM", container.NewBorder(nil, nil, nil, container.NewHBox(
 				widget.NewButtonWithIcon("", theme.Icon(theme.IconNameDelete), func() {
-					m.p.DeleteLLM(def)
+					if err := m.p.DeleteLLM(def); err != nil {
+						log.Printf("error deleting LLM definition: %v\n", err)
+						dialog.ShowError(err, m.w)
+						return
+					}
 					def = nil
 					temp := m.p.ListLLMs()
 					if len(temp) == 0 {
@@ -84,7 +172,7 @@ func ShowLLMSettings(m *Main) {
 						if lastEditedLLM < 0 {
 							lastEditedLLM = 0
 						}
-						def = m.p.GetLLM(llms[lastEditedLLM].ID)
+						def = m.p.GetLLM(temp[lastEditedLLM].ID)
 					}
 					m.p.SetIntSetting("llm.last-edited", lastEditedLLM)
 					updateUI()
@@ -119,13 +207,13 @@ func ShowLLMSettings(m *Main) {
 	for _, api := range apis {
 		apiNames = append(apiNames, api.Name)
 	}
-	apiSelect = NewIndexedSelect(apiNames, nil)
-	apiSelect.OnChanged = func(s string) {
+	apiSelect = NewIndexedSelect(apiNames, func(idx int) {
 		if def == nil {
 			return
 		}
-		def.API = apis[apiSelect.SelectedIndex()].ID
-	}
+		def.API = apis[idx].ID
+		refreshModels()
+	})
 	f.Append("API Type", apiSelect)
 	// URL entry
 	urlEntry = widget.NewEntry()
@@ -134,12 +222,42 @@ func ShowLLMSettings(m *Main) {
 		def.APIEndpoint = s
 	}
 	f.Append("API Endpoint", urlEntry)
-	// Model entry
-	modelEntry = widget.NewEntry()
+	// Model entry with the models reported by the API if available
+	modelEntry = widget.NewSelectEntry(nil)
 	modelEntry.OnChanged = func(s string) {
 		def.Model = s
+		updateContextLength()
+	}
+	f.Append("Model", container.NewBorder(nil, nil, nil,
+			widget.NewButtonWithIcon("", theme.Icon(theme.IconNameViewRefresh), func() {
+				refreshModels()
+			}),
+			modelEntry,
+		),
+	)
+	// Context length in tokens
+	contextLengthEntry = widget.NewEntry()
+	contextLengthEntry.OnChanged = func(s string) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			n = 0
+		}
+		def.ContextLength = n
+	}
+	f.Append("Context Length", contextLengthEntry)
+	// Prices used to estimate costs the API does not report
+	inputPriceEntry = widget.NewEntry()
+	inputPriceEntry.SetPlaceHolder("USD per million prompt tokens")
+	inputPriceEntry.OnChanged = func(s string) {
+		def.InputPrice = parsePrice(s)
 	}
-	f.Append("Model", modelEntry)
+	f.Append("Input Price", inputPriceEntry)
+	outputPriceEntry = widget.NewEntry()
+	outputPriceEntry.SetPlaceHolder("USD per million completion tokens")
+	outputPriceEntry.OnChanged = func(s string) {
+		def.OutputPrice = parsePrice(s)
+	}
+	f.Append("Output Price", outputPriceEntry)
 	// API key
 	apiKeyEntry = widget.NewEntry()
 	apiKeyEntry.Password = true
@@ -147,10 +265,48 @@ func ShowLLMSettings(m *Main) {
 		def.APIKey = s
 	}
 	f.Append("API Key", apiKeyEntry)
+	// Key storage
+	backendNames := []string{}
+	for _, b := range backends {
+		backendNames = append(backendNames, b.Name())
+	}
+	keyStorageSelect = NewIndexedSelect(backendNames, func(idx int) {
+		if def == nil {
+			return
+		}
+		def.KeyStorage = backends[idx].ID()
+		updateKeyInputs()
+		if def.KeyStorage == "encrypted" && !secrets.HasPassphrase() {
+			ShowPassphrase(m)
+		}
+	})
+	f.Append("Key Storage", keyStorageSelect)
+	keyNameEntry = widget.NewEntry()
+	keyNameEntry.OnChanged = func(s string) {
+		def.KeyName = s
+	}
+	f.Append("Key Name", keyNameEntry)
+	// Custom HTTP headers
+	headersEntry = widget.NewEntry()
+	headersEntry.MultiLine = true
+	headersEntry.SetMinRowsVisible(3)
+	headersEntry.SetPlaceHolder("Header-Name: value")
+	headersEntry.OnChanged = func(s string) {
+		def.Headers = parseHeaders(s)
+	}
+	f.Append("Headers", headersEntry)
+	// Sampling parameters
+	paramsEditor = NewParametersEditor(func(params llm.Parameters) {
+		def.Parameters = params
+	})
+	paramsAccordion := widget.NewAccordion(widget.NewAccordionItem(
+		"Sampling Parameters",
+		widget.NewForm(paramsEditor.FormItems()...),
+	))
 	// Load the last edited LLM
 	load(llms[llmSelect.SelectedIndex()].ID)
 	// Show the dialog
-	dlg := dialog.NewCustom("LLM Definitions", "Done", f, m.w)
+	dlg := dialog.NewCustom("LLM Definitions", "Done", container.NewVBox(f, paramsAccordion), m.w)
 	dlg.SetOnClosed(func() {
 		save()
 		m.FireOnLLMsUpdated()
@@ -158,3 +314,45 @@ func ShowLLMSettings(m *Main) {
 	dlg.Resize(dlg.MinSize().AddWidthHeight(240, 0))
 	dlg.Show()
 }
+
+// parseHeaders parses HTTP headers from text with one "Name: value" pair per
+// line. Blank and malformed lines are ignored.
+func parseHeaders(s string) map[string]string {
+	ret := map[string]string{}
+	for _, line := range strings.Split(s, "\n") {
+		k, v, found := strings.Cut(line, ":")
+		k = strings.TrimSpace(k)
+		if !found || k == "" {
+			continue
+		}
+		ret[k] = strings.TrimSpace(v)
+	}
+	return ret
+}
+
+// formatHeaders formats HTTP headers as text with one "Name: value" pair per
+// line, sorted by name.
+func formatHeaders(headers map[string]string) string {
+	lines := []string{}
+	for _, k := range slices.Sorted(maps.Keys(headers)) {
+		lines = append(lines, k+": "+headers[k])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parsePrice parses a price entry, invalid and negative prices being zero.
+func parsePrice(s string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(s), "$"), 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
+
+// formatPrice formats a price for its entry, zero being empty.
+func formatPrice(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
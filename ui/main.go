@@ -13,6 +13,7 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/storage"
 	"github.com/qbradq/gen-magic/data"
+	"github.com/qbradq/gen-magic/project"
 )
 
 // Closer implementations offer a Close() method.
@@ -24,7 +25,7 @@ type Closer interface {
 type Main struct {
 	app fyne.App
 	w fyne.Window
-	p Project
+	p project.Project
 	children map[Closer]struct{}
 }
 
@@ -77,7 +78,9 @@ func (m *Main) mainMenu() *fyne.MainMenu {
 					if writer == nil {
 						return
 					}
-					m.LoadProject(writer.URI().Path())
+					if err := m.LoadProject(writer.URI().Path()); err != nil {
+						dialog.ShowError(err, m.w)
+					}
 				}, m.w)
 				fileSave.SetConfirmText("Create Project")
 				fileSave.SetDismissText("Cancel")
@@ -95,7 +98,9 @@ func (m *Main) mainMenu() *fyne.MainMenu {
 					if reader == nil {
 						return
 					}
-					m.LoadProject(reader.URI().Path())
+					if err := m.LoadProject(reader.URI().Path()); err != nil {
+						dialog.ShowError(err, m.w)
+					}
 				}, m.w)
 				fileOpen.SetConfirmText("Open Project")
 				fileOpen.SetDismissText("Cancel")
@@ -117,6 +122,9 @@ func (m *Main) mainMenu() *fyne.MainMenu {
 			fyne.NewMenuItem("Agents", func() {
 				ShowAgentSettings(m)
 			}),
+			fyne.NewMenuItem("Prompts", func() {
+				NewPromptLibrary(m, nil)
+			}),
 		),
 		fyne.NewMenu("Start",
 			fyne.NewMenuItem("Chat", func() {
@@ -126,6 +134,19 @@ func (m *Main) mainMenu() *fyne.MainMenu {
 				})
 				m.children[chat] = struct{}{}
 			}),
+			fyne.NewMenuItem("Open Chat", func() {
+				ShowOpenChat(m)
+			}),
+			fyne.NewMenuItem("Compare", func() {
+				NewCompare(m)
+			}),
+			fyne.NewMenuItem("Orchestrate", func() {
+				NewOrchestrate(m)
+			}),
+			fyne.NewMenuItemSeparator(),
+			fyne.NewMenuItem("Usage", func() {
+				NewUsage(m)
+			}),
 		),
 	)
 }
@@ -154,13 +175,20 @@ func (m *Main) CloseChildren() {
 
 // LoadProject loads a project by filename.
 func (m *Main) LoadProject(p string) error {
-	m.CloseChildren()
-	m.p.Close()
-	if err := m.p.Load("sqlite", p); err != nil {
+	// Load the new project first so the current one stays open on error
+	var next project.Project
+	if err := next.Load("sqlite", p); err != nil {
 		return err
 	}
+	m.CloseChildren()
+	m.p.Close()
+	m.p = next
 	m.w.SetTitle(fmt.Sprintf("Gen Magic \"%s\"", p))
 	m.app.Preferences().SetString("last-open-project", p)
+	// Ask for the passphrase of encrypted API keys if needed
+	if m.p.HasEncryptedKeys() && m.p.CheckPassphrase() != nil {
+		ShowPassphrase(m)
+	}
 	return nil
 }
 
@@ -175,3 +203,15 @@ func (m *Main) FireOnLLMsUpdated() {
 		iChild.OnLLMsUpdated()
 	}
 }
+
+// FireOnAgentsUpdated fires the OnAgentsUpdated method on all open windows
+// that implement it.
+func (m *Main) FireOnAgentsUpdated() {
+	for child := range maps.Keys(m.children) {
+		iChild, ok := child.(interface{OnAgentsUpdated()})
+		if !ok {
+			continue
+		}
+		iChild.OnAgentsUpdated()
+	}
+}
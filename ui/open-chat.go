@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// ShowOpenChat shows the dialog used to open, rename and delete saved chat
+// sessions.
+func ShowOpenChat(m *Main) {
+	var dlg *dialog.CustomDialog
+	sessions := m.p.ListSessions()
+	selected := -1
+	list := widget.NewList(
+		func() int {
+			return len(sessions)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			s := sessions[id]
+			o.(*widget.Label).SetText(fmt.Sprintf("%s (%s)", s.Name, s.Updated))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+	list.OnUnselected = func(id widget.ListItemID) {
+		selected = -1
+	}
+	var refresh = func() {
+		sessions = m.p.ListSessions()
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+	}
+	btnOpen := widget.NewButtonWithIcon("Open", theme.Icon(theme.IconNameFolderOpen), func() {
+		if selected < 0 {
+			return
+		}
+		chat := NewChat(m, nil)
+		if err := chat.LoadSession(sessions[selected].ID); err != nil {
+			log.Printf("error loading chat session: %v\n", err)
+			dialog.ShowError(err, chat.w)
+		}
+		dlg.Hide()
+	})
+	btnRename := widget.NewButtonWithIcon("Rename", theme.Icon(theme.IconNameDocumentCreate), func() {
+		if selected < 0 {
+			return
+		}
+		s := sessions[selected]
+		nameEntry := widget.NewEntry()
+		nameEntry.SetText(s.Name)
+		dialog.ShowForm("Rename Chat", "Rename", "Cancel", []*widget.FormItem{
+			widget.NewFormItem("Name", nameEntry),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := m.p.RenameSession(s.ID, nameEntry.Text); err != nil {
+				log.Printf("error renaming chat session: %v\n", err)
+			}
+			refresh()
+		}, m.w)
+	})
+	btnDelete := widget.NewButtonWithIcon("Delete", theme.Icon(theme.IconNameDelete), func() {
+		if selected < 0 {
+			return
+		}
+		s := sessions[selected]
+		dialog.ShowConfirm("Delete Chat", fmt.Sprintf("Delete chat \"%s\"?", s.Name), func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := m.p.DeleteSession(s.ID); err != nil {
+				log.Printf("error deleting chat session: %v\n", err)
+			}
+			refresh()
+		}, m.w)
+	})
+	content := container.NewBorder(nil,
+		container.NewHBox(btnOpen, btnRename, btnDelete),
+		nil, nil,
+		list,
+	)
+	dlg = dialog.NewCustom("Open Chat", "Cancel", content, m.w)
+	dlg.Resize(fyne.NewSize(640, 420))
+	dlg.Show()
+}
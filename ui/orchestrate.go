@@ -0,0 +1,461 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/qbradq/gen-magic/data"
+	"github.com/qbradq/gen-magic/llm"
+	"github.com/qbradq/gen-magic/project"
+)
+
+// Orchestrate implements the multi-agent orchestration window. A coordinator
+// using the selected LLM definition answers the prompt by asking the checked
+// agents, and every agent call is shown in the trace tree.
+type Orchestrate struct {
+	w fyne.Window
+	m *Main
+	llms []project.LLMName
+	agents []project.AgentName
+	llmSelect *IndexedSelect
+	system *widget.Entry
+	agentChecks *widget.CheckGroup
+	toolsCheck *widget.Check
+	budget *widget.Form
+	budgetEntries map[string]*widget.Entry
+	tree *widget.Tree
+	detail *fyne.Container
+	detailScroll *container.Scroll
+	status *widget.Label
+	prompt *PromptEntry
+	progress *widget.ProgressBarInfinite
+	submit *widget.Button
+	stop *widget.Button
+	cancel context.CancelFunc
+	// nodes holds the display data of the trace nodes of the last run by ID.
+	// It is only accessed from the UI goroutine.
+	nodes map[string]*orchestrateNode
+	selected string
+}
+
+// orchestrateNode is the display data of one trace node.
+type orchestrateNode struct {
+	id string
+	agent string
+	prompt string
+	text string
+	tools []string
+	summary string
+	err *llm.Error
+	children []string
+	running bool
+	// turn is the finished turn of the node, nil while running.
+	turn *llm.Turn
+}
+
+// Budget settings with their form labels.
+var orchestrateBudgetSettings = []struct {
+	key string
+	label string
+}{
+	{"orchestrate.max-calls", "Max Agent Calls"},
+	{"orchestrate.max-depth", "Max Depth"},
+	{"orchestrate.max-tokens", "Max Tokens"},
+	{"orchestrate.max-iterations", "Max Iterations"},
+}
+
+// NewOrchestrate returns a new Orchestrate window.
+func NewOrchestrate(m *Main) *Orchestrate {
+	ret := &Orchestrate{
+		w: fyne.CurrentApp().NewWindow("Orchestrate"),
+		m: m,
+		budgetEntries: map[string]*widget.Entry{},
+		nodes: map[string]*orchestrateNode{},
+	}
+	ret.w.Canvas().AddShortcut(&desktop.CustomShortcut{
+		KeyName: fyne.KeyEnter,
+		Modifier: fyne.KeyModifierControl,
+	}, nil)
+	ret.w.SetOnClosed(func() {
+		ret.Close()
+	})
+	ret.llmSelect = NewIndexedSelect(nil, nil)
+	ret.system = widget.NewEntry()
+	ret.system.MultiLine = true
+	ret.system.SetMinRowsVisible(6)
+	ret.system.Wrapping = fyne.TextWrapWord
+	ret.system.SetText(data.DefaultSystem)
+	ret.agentChecks = widget.NewCheckGroup(nil, nil)
+	ret.toolsCheck = widget.NewCheck("Offer registered tools to all agents", nil)
+	// Budget entries, zero is unlimited
+	ret.budget = widget.NewForm()
+	for _, s := range orchestrateBudgetSettings {
+		key := s.key
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder("Unlimited")
+		if v := m.p.IntSetting(key, 0); v > 0 {
+			entry.SetText(strconv.Itoa(v))
+		}
+		entry.OnChanged = func(s string) {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 0 {
+				v = 0
+			}
+			m.p.SetIntSetting(key, v)
+		}
+		ret.budgetEntries[key] = entry
+		ret.budget.Append(s.label, entry)
+	}
+	// Trace tree and details of the selected node
+	ret.tree = widget.NewTree(
+		func(id widget.TreeNodeID) []widget.TreeNodeID {
+			if id == "" {
+				if _, ok := ret.nodes["1"]; ok {
+					return []widget.TreeNodeID{"1"}
+				}
+				return nil
+			}
+			if n, ok := ret.nodes[id]; ok {
+				return n.children
+			}
+			return nil
+		},
+		func(id widget.TreeNodeID) bool {
+			if id == "" {
+				return true
+			}
+			n, ok := ret.nodes[id]
+			return ok && len(n.children) > 0
+		},
+		func(branch bool) fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TreeNodeID, branch bool, o fyne.CanvasObject) {
+			if n, ok := ret.nodes[id]; ok {
+				o.(*widget.Label).SetText(n.label())
+			}
+		},
+	)
+	ret.tree.OnSelected = func(id widget.TreeNodeID) {
+		ret.selected = id
+		ret.showDetail()
+	}
+	ret.detail = container.NewVBox()
+	ret.detailScroll = container.NewVScroll(ret.detail)
+	ret.status = widget.NewLabel("")
+	ret.prompt = NewPromptEntry()
+	ret.prompt.MultiLine = true
+	ret.prompt.SetMinRowsVisible(4)
+	ret.prompt.SetPlaceHolder("Task for the coordinator")
+	ret.prompt.OnCtrlEnter = ret.Submit
+	ret.progress = widget.NewProgressBarInfinite()
+	ret.progress.Hide()
+	ret.submit = widget.NewButtonWithIcon("", theme.Icon(theme.IconNameMediaPlay), ret.Submit)
+	ret.stop = widget.NewButtonWithIcon("", theme.Icon(theme.IconNameMediaStop), func() {
+		if ret.cancel != nil {
+			ret.cancel()
+		}
+	})
+	ret.stop.Disable()
+	ret.OnLLMsUpdated()
+	ret.OnAgentsUpdated()
+	settings := container.NewVBox(
+		widget.NewForm(widget.NewFormItem("Coordinator LLM", ret.llmSelect)),
+		widget.NewAccordion(
+			widget.NewAccordionItem("Coordinator System Prompt", ret.system),
+			widget.NewAccordionItem("Agents", container.NewVBox(ret.agentChecks, ret.toolsCheck)),
+			widget.NewAccordionItem("Budget", ret.budget),
+		),
+	)
+	split := container.NewHSplit(
+		container.NewBorder(nil, ret.status, nil, nil, ret.tree),
+		ret.detailScroll,
+	)
+	split.SetOffset(0.3)
+	ret.w.SetContent(container.NewPadded(
+		container.NewBorder(
+			settings,
+			container.NewBorder(nil, nil, nil,
+				container.NewVBox(ret.stop, ret.submit),
+				container.NewStack(
+					ret.prompt,
+					container.NewCenter(
+						container.NewGridWrap(
+							fyne.NewSize(
+								240,
+								ret.progress.MinSize().Height,
+							),
+							ret.progress,
+						),
+					),
+				),
+			),
+			nil,
+			nil,
+			split,
+		),
+	))
+	ret.w.Resize(fyne.NewSize(1200, 800))
+	ret.w.Show()
+	ret.m.AddChild(ret)
+	return ret
+}
+
+// Close closes the window.
+func (o *Orchestrate) Close() {
+	if o.cancel != nil {
+		o.cancel()
+	}
+	o.w.Close()
+	o.m.RemoveChild(o)
+}
+
+// label returns the tree label of the node.
+func (n *orchestrateNode) label() string {
+	switch {
+	case n.running:
+		return n.id + " " + n.agent + " …"
+	case n.err != nil:
+		return n.id + " " + n.agent + " ✗"
+	default:
+		return n.id + " " + n.agent
+	}
+}
+
+// budgetValue returns the value of a budget entry.
+func (o *Orchestrate) budgetValue(key string) int {
+	v, err := strconv.Atoi(o.budgetEntries[key].Text)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
+
+// Submit starts an orchestration run with the prompt.
+func (o *Orchestrate) Submit() {
+	if o.cancel != nil || o.prompt.Text == "" {
+		return
+	}
+	idx := o.llmSelect.SelectedIndex()
+	if idx >= len(o.llms) {
+		o.status.SetText("No coordinator LLM selected")
+		return
+	}
+	orch := &llm.Orchestrator{
+		Coordinator: &llm.Agent{
+			Name: "Coordinator",
+			LLM: o.m.p.GetLLM(o.llms[idx].ID),
+			System: llm.Message{
+				Role: "system",
+				Content: o.system.Text,
+			},
+		},
+		Budget: llm.Budget{
+			MaxCalls: o.budgetValue("orchestrate.max-calls"),
+			MaxDepth: o.budgetValue("orchestrate.max-depth"),
+			MaxTokens: o.budgetValue("orchestrate.max-tokens"),
+			MaxIterations: o.budgetValue("orchestrate.max-iterations"),
+		},
+	}
+	for _, a := range o.agents {
+		for _, checked := range o.agentChecks.Selected {
+			if a.Name == checked {
+				orch.Agents = append(orch.Agents, o.m.p.ResolveAgent(o.m.p.GetAgent(a.ID)))
+				break
+			}
+		}
+	}
+	if o.toolsCheck.Checked {
+		orch.Tools = llm.Tools()
+	}
+	// The callbacks run on the goroutines of the run, so they only pass
+	// copies of the node data to the UI goroutine
+	orch.OnStart = func(node, parent *llm.TraceNode) {
+		n := &orchestrateNode{
+			id: node.ID,
+			agent: node.Agent,
+			prompt: node.Prompt,
+			running: true,
+		}
+		parentID := ""
+		if parent != nil {
+			parentID = parent.ID
+		}
+		fyne.Do(func() {
+			o.nodes[n.id] = n
+			if p, ok := o.nodes[parentID]; ok {
+				p.children = append(p.children, n.id)
+			}
+			o.tree.Refresh()
+			o.tree.OpenBranch(parentID)
+			if n.id == "1" {
+				o.tree.Select(n.id)
+			}
+		})
+	}
+	orch.OnEvent = func(node *llm.TraceNode, e *llm.Event) {
+		id := node.ID
+		switch {
+		case e.Type == llm.EventContent:
+			text := e.Text
+			fyne.Do(func() {
+				o.nodes[id].text += text
+				if o.selected == id {
+					o.showDetail()
+				}
+			})
+		case e.Type == llm.EventToolResult && e.ToolResult != nil:
+			r := e.ToolResult
+			tool := fmt.Sprintf("**%s** `%s`\n\n%s", r.Call.Name, r.Call.Arguments, r.Content)
+			fyne.Do(func() {
+				n := o.nodes[id]
+				n.tools = append(n.tools, tool)
+				// Responses after tool calls replace the text before them
+				n.text = ""
+				if o.selected == id {
+					o.showDetail()
+				}
+			})
+		}
+	}
+	orch.OnEnd = func(node *llm.TraceNode) {
+		id := node.ID
+		summary := fmt.Sprintf("%s · %.2fs", turnSummary(node.Turn), node.End.Sub(node.Start).Seconds())
+		text := node.Answer()
+		var lErr *llm.Error
+		if node.Error != nil && !errors.As(node.Error, &lErr) {
+			lErr = &llm.Error{
+				Kind: llm.ErrorKindRequest,
+				Err: node.Error,
+			}
+		}
+		fyne.Do(func() {
+			n := o.nodes[id]
+			n.running = false
+			n.text = text
+			n.summary = strings.TrimPrefix(summary, " · ")
+			n.err = lErr
+			n.turn = node.Turn
+			o.tree.RefreshItem(id)
+			if o.selected == id {
+				o.showDetail()
+			}
+		})
+	}
+	// Start the run
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+	o.nodes = map[string]*orchestrateNode{}
+	o.selected = ""
+	o.tree.UnselectAll()
+	o.tree.Refresh()
+	o.detail.RemoveAll()
+	o.status.SetText("Running")
+	o.stop.Enable()
+	o.submit.Disable()
+	o.prompt.Disable()
+	o.progress.Show()
+	prompt := o.prompt.Text
+	start := time.Now()
+	go func() {
+		root, err := orch.Run(ctx, prompt)
+		calls := 0
+		var count func(n *llm.TraceNode)
+		count = func(n *llm.TraceNode) {
+			calls++
+			for _, c := range n.Children() {
+				count(c)
+			}
+		}
+		count(root)
+		status := fmt.Sprintf("%d agent calls in %.2fs", calls, time.Since(start).Seconds())
+		if err != nil {
+			status += ": " + err.Error()
+		}
+		fyne.Do(func() {
+			cancel()
+			o.cancel = nil
+			o.status.SetText(status)
+			o.stop.Disable()
+			o.submit.Enable()
+			o.prompt.Enable()
+			o.progress.Hide()
+		})
+	}()
+}
+
+// showDetail shows the prompt, tool calls and answer of the selected node.
+func (o *Orchestrate) showDetail() {
+	o.detail.RemoveAll()
+	n, ok := o.nodes[o.selected]
+	if !ok {
+		return
+	}
+	o.detail.Add(NewChatBubble(
+		"Prompt",
+		n.prompt,
+		theme.Color(theme.ColorNameInputBackground),
+		true,
+	))
+	for _, tool := range n.tools {
+		o.detail.Add(NewChatBubble(
+			"Tool Call",
+			tool,
+			theme.Color(theme.ColorNameDisabledButton),
+			false,
+		))
+	}
+	if n.text != "" || !n.running {
+		bubble := NewChatBubble(
+			n.agent,
+			n.text,
+			theme.Color(theme.ColorNameBackground),
+			false,
+		)
+		bubble.SetFooter(n.summary)
+		if n.turn != nil {
+			bubble.AddAction(theme.InfoIcon(), func() {
+				NewInspector(o.m, n.turn)
+			})
+		}
+		o.detail.Add(bubble)
+	}
+	if n.err != nil {
+		o.detail.Add(NewErrorBubble(n.err))
+	}
+}
+
+// OnLLMsUpdated is called when the LLM list is updated.
+func (o *Orchestrate) OnLLMsUpdated() {
+	o.llms = o.m.p.ListLLMs()
+	names := []string{}
+	for _, n := range o.llms {
+		names = append(names, n.Name)
+	}
+	idx := o.llmSelect.SelectedIndex()
+	o.llmSelect.SetOptions(names)
+	o.llmSelect.rawSetSelectedIndex(idx)
+}
+
+// OnAgentsUpdated is called when the agent list is updated.
+func (o *Orchestrate) OnAgentsUpdated() {
+	o.agents = o.m.p.ListAgents()
+	names := []string{}
+	for _, a := range o.agents {
+		names = append(names, a.Name)
+	}
+	o.agentChecks.Options = names
+	if o.agentChecks.Selected == nil {
+		o.agentChecks.Selected = names
+	}
+	o.agentChecks.Refresh()
+}
@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2/widget"
+	"github.com/qbradq/gen-magic/llm"
+)
+
+// stopEscaper and stopUnescaper escape and unescape the control characters commonly used in
+// stop sequences so they can be edited one per line.
+var stopEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\t", "\\t")
+var stopUnescaper = strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\t", "\t")
+
+// ParametersEditor edits a set of sampling parameters. Empty inputs leave the
+// parameter unset.
+type ParametersEditor struct {
+	// OnChanged is called with the new parameters whenever an input changes.
+	OnChanged func(llm.Parameters)
+	params llm.Parameters
+	setting bool
+	temperature *widget.Entry
+	topP *widget.Entry
+	topK *widget.Entry
+	maxTokens *widget.Entry
+	frequencyPenalty *widget.Entry
+	presencePenalty *widget.Entry
+	stop *widget.Entry
+	seed *widget.Entry
+}
+
+// NewParametersEditor returns a new ParametersEditor.
+func NewParametersEditor(onChanged func(llm.Parameters)) *ParametersEditor {
+	ret := &ParametersEditor{
+		OnChanged: onChanged,
+	}
+	ret.temperature = ret.newEntry("Default", floatValidator)
+	ret.topP = ret.newEntry("Default", floatValidator)
+	ret.topK = ret.newEntry("Default", intValidator)
+	ret.maxTokens = ret.newEntry("Default", intValidator)
+	ret.frequencyPenalty = ret.newEntry("Default", floatValidator)
+	ret.presencePenalty = ret.newEntry("Default", floatValidator)
+	ret.seed = ret.newEntry("Random", intValidator)
+	ret.stop = ret.newEntry("One stop sequence per line, \\n for newline", nil)
+	ret.stop.MultiLine = true
+	ret.stop.SetMinRowsVisible(2)
+	return ret
+}
+
+// newEntry returns a new entry that updates the parameters when changed.
+func (e *ParametersEditor) newEntry(placeHolder string, validator func(string) error) *widget.Entry {
+	ret := widget.NewEntry()
+	ret.SetPlaceHolder(placeHolder)
+	ret.Validator = validator
+	ret.OnChanged = func(s string) {
+		if e.setting {
+			return
+		}
+		e.update()
+	}
+	return ret
+}
+
+// update parses all inputs and calls OnChanged. Invalid inputs are left
+// unset.
+func (e *ParametersEditor) update() {
+	p := llm.Parameters{}
+	p.Temperature, _ = llm.ParseFloatParameter(e.temperature.Text)
+	p.TopP, _ = llm.ParseFloatParameter(e.topP.Text)
+	p.TopK, _ = llm.ParseIntParameter(e.topK.Text)
+	p.MaxTokens, _ = llm.ParseIntParameter(e.maxTokens.Text)
+	p.FrequencyPenalty, _ = llm.ParseFloatParameter(e.frequencyPenalty.Text)
+	p.PresencePenalty, _ = llm.ParseFloatParameter(e.presencePenalty.Text)
+	p.Seed, _ = llm.ParseIntParameter(e.seed.Text)
+	for _, line := range strings.Split(e.stop.Text, "\n") {
+		if line == "" {
+			continue
+		}
+		p.Stop = append(p.Stop, stopUnescaper.Replace(line))
+	}
+	e.params = p
+	if e.OnChanged != nil {
+		e.OnChanged(p)
+	}
+}
+
+// SetParameters sets the value of all inputs without calling OnChanged.
+func (e *ParametersEditor) SetParameters(p llm.Parameters) {
+	e.setting = true
+	defer func() {
+		e.setting = false
+	}()
+	e.params = p
+	e.temperature.SetText(formatFloatParameter(p.Temperature))
+	e.topP.SetText(formatFloatParameter(p.TopP))
+	e.topK.SetText(formatIntParameter(p.TopK))
+	e.maxTokens.SetText(formatIntParameter(p.MaxTokens))
+	e.frequencyPenalty.SetText(formatFloatParameter(p.FrequencyPenalty))
+	e.presencePenalty.SetText(formatFloatParameter(p.PresencePenalty))
+	e.seed.SetText(formatIntParameter(p.Seed))
+	lines := []string{}
+	for _, s := range p.Stop {
+		lines = append(lines, stopEscaper.Replace(s))
+	}
+	e.stop.SetText(strings.Join(lines, "\n"))
+}
+
+// Parameters returns the parameters as currently edited.
+func (e *ParametersEditor) Parameters() llm.Parameters {
+	return e.params
+}
+
+// FormItems returns the form items of all inputs.
+func (e *ParametersEditor) FormItems() []*widget.FormItem {
+	return []*widget.FormItem{
+		widget.NewFormItem("Temperature", e.temperature),
+		widget.NewFormItem("Top P", e.topP),
+		widget.NewFormItem("Top K", e.topK),
+		widget.NewFormItem("Max Tokens", e.maxTokens),
+		widget.NewFormItem("Frequency Penalty", e.frequencyPenalty),
+		widget.NewFormItem("Presence Penalty", e.presencePenalty),
+		widget.NewFormItem("Stop Sequences", e.stop),
+		widget.NewFormItem("Seed", e.seed),
+	}
+}
+
+// floatValidator accepts empty strings and floating point numbers.
+func floatValidator(s string) error {
+	if _, err := llm.ParseFloatParameter(s); err != nil {
+		return errors.New("must be a number")
+	}
+	return nil
+}
+
+// intValidator accepts empty strings and integers.
+func intValidator(s string) error {
+	if _, err := llm.ParseIntParameter(s); err != nil {
+		return errors.New("must be a whole number")
+	}
+	return nil
+}
+
+// formatFloatParameter formats an optional floating point parameter.
+func formatFloatParameter(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// formatIntParameter formats an optional integer parameter.
+func formatIntParameter(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
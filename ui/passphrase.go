@@ -0,0 +1,33 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/qbradq/gen-magic/secrets"
+)
+
+// ShowPassphrase shows the dialog asking for the passphrase used to encrypt
+// and decrypt the API keys of the project. The passphrase is checked against
+// the encrypted keys of the project and asked for again if wrong.
+func ShowPassphrase(m *Main) {
+	entry := widget.NewPasswordEntry()
+	entry.SetPlaceHolder("Passphrase")
+	dlg := dialog.NewForm("Passphrase", "Unlock", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Passphrase", entry),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		secrets.SetPassphrase(entry.Text)
+		if err := m.p.CheckPassphrase(); err != nil {
+			secrets.SetPassphrase("")
+			d := dialog.NewError(err, m.w)
+			d.SetOnClosed(func() {
+				ShowPassphrase(m)
+			})
+			d.Show()
+		}
+	}, m.w)
+	dlg.Resize(dlg.MinSize().AddWidthHeight(160, 0))
+	dlg.Show()
+}
@@ -10,6 +10,9 @@ import (
 type PromptEntry struct {
 	widget.Entry
 	OnCtrlEnter func()
+	// OnPaste, if not nil, is called with the clipboard content before it is
+	// pasted. Returning true consumes the content instead of pasting it.
+	OnPaste func(content string) bool
 }
 
 // NewPromptEntry returns a new PromptEntry.
@@ -21,6 +24,11 @@ func NewPromptEntry() *PromptEntry {
 
 // TypedShortcut handles keyboard shortcuts.
 func (e *PromptEntry) TypedShortcut(s fyne.Shortcut) {
+	if ps, ok := s.(*fyne.ShortcutPaste); ok && e.OnPaste != nil && ps.Clipboard != nil {
+		if e.OnPaste(ps.Clipboard.Content()) {
+			return
+		}
+	}
 	cs, ok := s.(*desktop.CustomShortcut)
 	if ok && cs.Modifier == fyne.KeyModifierControl {
 		switch cs.KeyName {
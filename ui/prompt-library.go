@@ -0,0 +1,248 @@
+package ui
+
+import (
+	"log"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/qbradq/gen-magic/project"
+)
+
+// PromptLibrary implements the window where reusable prompts are stored and
+// inserted into chats.
+type PromptLibrary struct {
+	w fyne.Window
+	m *Main
+	onInsert func(text string)
+	prompts []*project.Prompt
+	// shown are the prompts matching the search query.
+	shown []*project.Prompt
+	prompt *project.Prompt
+	search *widget.Entry
+	list *widget.List
+	nameEntry *widget.Entry
+	descEntry *widget.Entry
+	tagsEntry *widget.Entry
+	bodyEntry *widget.Entry
+}
+
+// NewPromptLibrary returns a new PromptLibrary window. If onInsert is not nil
+// the window offers to insert the selected prompt, which is called with the
+// prompt rendered with the variables the user is asked for.
+func NewPromptLibrary(m *Main, onInsert func(text string)) *PromptLibrary {
+	ret := &PromptLibrary{
+		w: fyne.CurrentApp().NewWindow("Prompt Library"),
+		m: m,
+		onInsert: onInsert,
+	}
+	ret.w.SetOnClosed(func() {
+		ret.Close()
+	})
+	ret.search = widget.NewEntry()
+	ret.search.SetPlaceHolder("Search names, descriptions and tags")
+	ret.search.OnChanged = func(string) {
+		ret.filter()
+	}
+	ret.list = widget.NewList(
+		func() int {
+			return len(ret.shown)
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			r := ret.shown[id]
+			text := r.Name
+			if len(r.Tags) > 0 {
+				text += " [" + strings.Join(r.Tags, ", ") + "]"
+			}
+			o.(*widget.Label).SetText(text)
+		},
+	)
+	ret.list.OnSelected = func(id widget.ListItemID) {
+		ret.show(ret.shown[id])
+	}
+	ret.nameEntry = widget.NewEntry()
+	ret.descEntry = widget.NewEntry()
+	ret.tagsEntry = widget.NewEntry()
+	ret.tagsEntry.SetPlaceHolder("tag, tag")
+	ret.bodyEntry = widget.NewEntry()
+	ret.bodyEntry.MultiLine = true
+	ret.bodyEntry.Wrapping = fyne.TextWrapWord
+	ret.bodyEntry.SetPlaceHolder("Use {{name}} for variables and {{date}}, {{time}} or {{project}}.")
+	buttons := container.NewHBox(
+		widget.NewButtonWithIcon("New", theme.ContentAddIcon(), func() {
+			ret.list.UnselectAll()
+			ret.show(&project.Prompt{
+				Name: "Unnamed Prompt",
+			})
+		}),
+		widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), ret.delete),
+		widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), ret.save),
+	)
+	if onInsert != nil {
+		buttons.Add(widget.NewButtonWithIcon("Insert", theme.MailForwardIcon(), ret.insert))
+	}
+	split := container.NewHSplit(
+		container.NewBorder(ret.search, nil, nil, nil, ret.list),
+		container.NewBorder(
+			widget.NewForm(
+				widget.NewFormItem("Name", ret.nameEntry),
+				widget.NewFormItem("Description", ret.descEntry),
+				widget.NewFormItem("Tags", ret.tagsEntry),
+			),
+			container.NewBorder(nil, nil, nil, buttons),
+			nil,
+			nil,
+			ret.bodyEntry,
+		),
+	)
+	split.Offset = 0.3
+	ret.w.SetContent(container.NewPadded(split))
+	ret.load()
+	if len(ret.shown) > 0 {
+		ret.list.Select(0)
+	} else {
+		ret.show(&project.Prompt{
+			Name: "Unnamed Prompt",
+		})
+	}
+	ret.w.Resize(fyne.NewSize(960, 560))
+	ret.w.Show()
+	ret.m.AddChild(ret)
+	return ret
+}
+
+// Close closes the window.
+func (n *PromptLibrary) Close() {
+	n.w.Close()
+	n.m.RemoveChild(n)
+}
+
+// load loads the prompts from the project.
+func (n *PromptLibrary) load() {
+	var err error
+	if n.prompts, err = n.m.p.ListPrompts(); err != nil {
+		log.Printf("error loading prompts: %v\n", err)
+	}
+	n.filter()
+}
+
+// filter shows the prompts matching the search query.
+func (n *PromptLibrary) filter() {
+	n.shown = nil
+	for _, r := range n.prompts {
+		if r.Matches(n.search.Text) {
+			n.shown = append(n.shown, r)
+		}
+	}
+	n.list.UnselectAll()
+	n.list.Refresh()
+}
+
+// show shows the prompt in the editor.
+func (n *PromptLibrary) show(r *project.Prompt) {
+	n.prompt = r
+	n.nameEntry.SetText(r.Name)
+	n.descEntry.SetText(r.Description)
+	n.tagsEntry.SetText(strings.Join(r.Tags, ", "))
+	n.bodyEntry.SetText(r.Body)
+}
+
+// save stores the prompt being edited and selects it in the list.
+func (n *PromptLibrary) save() {
+	r := n.prompt
+	r.Name = n.nameEntry.Text
+	r.Description = n.descEntry.Text
+	r.Tags = project.ParseTags(n.tagsEntry.Text)
+	r.Body = n.bodyEntry.Text
+	if _, err := project.TemplateVariables(r.Body); err != nil {
+		dialog.ShowError(err, n.w)
+		return
+	}
+	if err := n.m.p.SavePrompt(r); err != nil {
+		log.Printf("error saving prompt: %v\n", err)
+		dialog.ShowError(err, n.w)
+		return
+	}
+	n.search.SetText("")
+	n.load()
+	for i, p := range n.shown {
+		if p.ID == r.ID {
+			n.list.Select(i)
+		}
+	}
+}
+
+// delete deletes the prompt being edited after confirmation.
+func (n *PromptLibrary) delete() {
+	r := n.prompt
+	if r.ID == 0 {
+		return
+	}
+	dialog.ShowConfirm("Delete Prompt", "Delete the prompt \""+r.Name+"\"?", func(ok bool) {
+		if !ok {
+			return
+		}
+		if err := n.m.p.DeletePrompt(r.ID); err != nil {
+			log.Printf("error deleting prompt: %v\n", err)
+			dialog.ShowError(err, n.w)
+			return
+		}
+		n.load()
+		n.show(&project.Prompt{
+			Name: "Unnamed Prompt",
+		})
+	}, n.w)
+}
+
+// insert asks for the values of the variables of the prompt being edited and
+// inserts the rendered prompt.
+func (n *PromptLibrary) insert() {
+	body := n.bodyEntry.Text
+	names, err := project.TemplateVariables(body)
+	if err != nil {
+		dialog.ShowError(err, n.w)
+		return
+	}
+	render := func(vars map[string]string) {
+		text, err := n.m.p.RenderTemplate(body, vars)
+		if err != nil {
+			dialog.ShowError(err, n.w)
+			return
+		}
+		n.onInsert(text)
+		n.Close()
+	}
+	if len(names) == 0 {
+		render(nil)
+		return
+	}
+	entries := map[string]*widget.Entry{}
+	items := []*widget.FormItem{}
+	for _, name := range names {
+		e := widget.NewEntry()
+		e.MultiLine = true
+		e.Wrapping = fyne.TextWrapWord
+		entries[name] = e
+		items = append(items, widget.NewFormItem(name, e))
+	}
+	dlg := dialog.NewForm("Prompt Variables", "Insert", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		vars := map[string]string{}
+		for name, e := range entries {
+			vars[name] = e.Text
+		}
+		render(vars)
+	}, n.w)
+	dlg.Resize(fyne.NewSize(640, dlg.MinSize().Height))
+	dlg.Show()
+}
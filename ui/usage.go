@@ -0,0 +1,184 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/qbradq/gen-magic/project"
+)
+
+// usageColumns are the column headers of the usage tables.
+var usageColumns = []string{
+	"",
+	"Turns",
+	"Prompt",
+	"Completion",
+	"Reasoning",
+	"Cached",
+	"Cost",
+	"Avg Latency",
+}
+
+// Usage implements the usage and cost window, which shows the totals of all
+// turns of the project by LLM and by day.
+type Usage struct {
+	w fyne.Window
+	m *Main
+	byLLM []project.UsageTotal
+	byDay []project.UsageTotal
+	total *widget.Label
+	llmTable *widget.Table
+	dayTable *widget.Table
+}
+
+// NewUsage returns a new Usage window.
+func NewUsage(m *Main) *Usage {
+	ret := &Usage{
+		w: fyne.CurrentApp().NewWindow("Usage"),
+		m: m,
+	}
+	ret.w.SetOnClosed(func() {
+		ret.Close()
+	})
+	ret.total = widget.NewLabel("")
+	ret.llmTable = ret.newTable("LLM", &ret.byLLM)
+	ret.dayTable = ret.newTable("Day (UTC)", &ret.byDay)
+	ret.Refresh()
+	ret.w.SetContent(container.NewPadded(
+		container.NewBorder(
+			nil,
+			container.NewBorder(nil, nil, nil,
+				container.NewHBox(
+					widget.NewButtonWithIcon("Refresh", theme.Icon(theme.IconNameViewRefresh), ret.Refresh),
+					widget.NewButtonWithIcon("Export CSV", theme.Icon(theme.IconNameDocumentSave), ret.showExport),
+				),
+				ret.total,
+			),
+			nil,
+			nil,
+			container.NewAppTabs(
+				container.NewTabItem("By LLM", ret.llmTable),
+				container.NewTabItem("By Day", ret.dayTable),
+			),
+		),
+	))
+	ret.w.Resize(fyne.NewSize(960, 540))
+	ret.w.Show()
+	ret.m.AddChild(ret)
+	return ret
+}
+
+// Close closes the window.
+func (u *Usage) Close() {
+	u.w.Close()
+	u.m.RemoveChild(u)
+}
+
+// newTable returns a new table of the usage totals with a header row, the
+// first column being titled key.
+func (u *Usage) newTable(key string, totals *[]project.UsageTotal) *widget.Table {
+	ret := widget.NewTable(
+		func() (int, int) {
+			return len(*totals) + 1, len(usageColumns)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			l := o.(*widget.Label)
+			if id.Row == 0 {
+				l.TextStyle = fyne.TextStyle{Bold: true}
+				l.SetText(usageColumns[id.Col])
+				if id.Col == 0 {
+					l.SetText(key)
+				}
+				return
+			}
+			l.TextStyle = fyne.TextStyle{}
+			l.SetText(usageCell((*totals)[id.Row-1], id.Col))
+		},
+	)
+	ret.SetColumnWidth(0, 280)
+	for i := 1; i < len(usageColumns); i++ {
+		ret.SetColumnWidth(i, 96)
+	}
+	return ret
+}
+
+// usageCell returns the text of a column of the usage totals.
+func usageCell(t project.UsageTotal, col int) string {
+	switch col {
+	case 0:
+		return t.Key
+	case 1:
+		return strconv.Itoa(t.Turns)
+	case 2:
+		return strconv.Itoa(t.PromptTokens)
+	case 3:
+		return strconv.Itoa(t.CompletionTokens)
+	case 4:
+		return strconv.Itoa(t.ReasoningTokens)
+	case 5:
+		return strconv.Itoa(t.CachedTokens)
+	case 6:
+		return formatCost(t.Cost)
+	default:
+		if t.Latency == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.2fs", t.Latency.Seconds())
+	}
+}
+
+// formatCost formats a cost in USD.
+func formatCost(cost float64) string {
+	return fmt.Sprintf("$%.4f", cost)
+}
+
+// Refresh reloads the usage totals.
+func (u *Usage) Refresh() {
+	var err error
+	if u.byLLM, err = u.m.p.UsageByLLM(); err != nil {
+		log.Printf("error loading usage by LLM: %v\n", err)
+	}
+	if u.byDay, err = u.m.p.UsageByDay(); err != nil {
+		log.Printf("error loading usage by day: %v\n", err)
+	}
+	total := project.UsageTotal{}
+	for _, t := range u.byLLM {
+		total.Turns += t.Turns
+		total.Usage = total.Usage.Add(t.Usage)
+	}
+	u.total.SetText(fmt.Sprintf("%d turns · %d prompt / %d completion tokens · %s",
+		total.Turns, total.PromptTokens, total.CompletionTokens, formatCost(total.Cost)))
+	u.llmTable.Refresh()
+	u.dayTable.Refresh()
+}
+
+// showExport shows a dialog to export the usage of every turn as CSV.
+func (u *Usage) showExport() {
+	fileSave := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			log.Printf("error in usage export file: %v\n", err)
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+		if err := u.m.p.ExportUsageCSV(writer); err != nil {
+			dialog.ShowError(err, u.w)
+		}
+	}, u.w)
+	fileSave.SetFileName("usage.csv")
+	fileSave.SetFilter(storage.NewExtensionFileFilter([]string{".csv"}))
+	fileSave.SetTitleText("Export Usage")
+	fileSave.Show()
+}